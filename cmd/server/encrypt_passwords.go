@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log/slog"
+
+	"wifi-go-backend/config"
+	"wifi-go-backend/internal/db"
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/secrets"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// runEncryptPasswords is a one-shot migration that encrypts WiFi passwords
+// stored in plaintext before encryption at rest was introduced. It is safe
+// to re-run; already encrypted records are skipped.
+func runEncryptPasswords(cfg *config.Config, args []string) error {
+	fs := flag.NewFlagSet("encrypt-passwords", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "report how many records would be encrypted without writing")
+	fs.Parse(args)
+
+	if cfg.PasswordKeyFile == "" {
+		return errors.New("WIFI_PASSWORD_KEY_FILE is required")
+	}
+	keys, err := secrets.LoadLocalKeyFile(cfg.PasswordKeyFile, cfg.PasswordRetiredKeyFiles...)
+	if err != nil {
+		return err
+	}
+	coll, err := db.GetWiFiCollection()
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+	filter := bson.M{
+		"password":     bson.M{"$exists": true, "$ne": ""},
+		"password_enc": bson.M{"$exists": false},
+	}
+	cur, err := coll.Find(ctx, filter)
+	if err != nil {
+		return err
+	}
+	defer cur.Close(ctx)
+
+	var encrypted, skipped int
+	for cur.Next(ctx) {
+		var wifi models.WiFi
+		if err := cur.Decode(&wifi); err != nil {
+			return err
+		}
+		if *dryRun {
+			encrypted++
+			continue
+		}
+		plaintext := wifi.Password
+		if err := secrets.EncryptWiFiPassword(ctx, keys, &wifi); err != nil {
+			return err
+		}
+		// Only touch the record if the password is still the one we read
+		res, err := coll.UpdateOne(ctx,
+			bson.M{"_id": wifi.ID, "password": plaintext},
+			bson.M{
+				"$set":   bson.M{"password_enc": wifi.PasswordEnc},
+				"$unset": bson.M{"password": ""},
+			},
+		)
+		if err != nil {
+			return err
+		}
+		if res.ModifiedCount == 0 {
+			skipped++
+			continue
+		}
+		encrypted++
+	}
+	if err := cur.Err(); err != nil {
+		return err
+	}
+
+	if *dryRun {
+		slog.Info("dry run: plaintext passwords would be encrypted", "count", encrypted)
+	} else {
+		slog.Info("encrypted passwords", "count", encrypted, "skipped_concurrent_changes", skipped)
+	}
+	return nil
+}
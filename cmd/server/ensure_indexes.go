@@ -0,0 +1,21 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"wifi-go-backend/internal/db"
+)
+
+// runEnsureIndexes creates the MongoDB indexes without starting the server,
+// for deployments that manage schema separately from rollout.
+func runEnsureIndexes() error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	if err := db.EnsureIndexes(ctx); err != nil {
+		return err
+	}
+	slog.Info("MongoDB indexes are up to date")
+	return nil
+}
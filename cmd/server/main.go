@@ -1,9 +1,19 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"log"
+	"log/slog"
 	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
 	"wifi-go-backend/config"
+	"wifi-go-backend/internal/db"
+	"wifi-go-backend/internal/logging"
 	"wifi-go-backend/internal/routes"
 
 	"github.com/joho/godotenv"
@@ -11,12 +21,155 @@ import (
 
 func main() {
 	// Load .env file
-	if err := godotenv.Load(); err != nil {
-		log.Println("No .env file found or failed to load")
+	envErr := godotenv.Load()
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Subcommands only need the database settings
+	if len(os.Args) == 1 {
+		if err := cfg.Validate(); err != nil {
+			log.Fatal(err)
+		}
+	}
+	logger, err := logging.New(os.Stderr, cfg.LogFormat, cfg.LogLevel)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// Route the standard log package, used by libraries, through slog too
+	slog.SetDefault(logger)
+	if envErr != nil {
+		logger.Debug("no .env file loaded", "err", envErr)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.MongoConnectTimeout)
+	err = db.Connect(ctx, cfg)
+	cancel()
+	if err != nil {
+		fatal("failed to connect to MongoDB", "err", err, "uri", logging.RedactURI(cfg.MongoURI))
+	}
+	logger.Info("connected to MongoDB", "uri", logging.RedactURI(cfg.MongoURI), "database", cfg.MongoDatabase)
+
+	// Subcommands: server <command> [flags]
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "encrypt-passwords":
+			err = runEncryptPasswords(cfg, os.Args[2:])
+		case "ensure-indexes":
+			err = runEnsureIndexes()
+		case "migrate":
+			err = runMigrate(os.Args[2:])
+		default:
+			err = fmt.Errorf("unknown command %q", os.Args[1])
+		}
+		disconnect()
+		if err != nil {
+			fatal("command failed", "command", os.Args[1], "err", err)
+		}
+		return
 	}
 
-	cfg := config.Load()
-	router := routes.SetupRouter(cfg)
-	log.Println("Starting server on port 8080...")
-	log.Fatal(http.ListenAndServe(":8080", router))
+	h, err := routes.NewHandlers(cfg, logger)
+	if err != nil {
+		disconnect()
+		fatal("failed to initialise handlers", "err", err)
+	}
+	go ensureIndexes(h.Ready)
+	err = serve(cfg, routes.SetupRouter(h), h.Ready.SetShuttingDown)
+	h.Close()
+	disconnect()
+	if err != nil {
+		fatal("server failed", "err", err)
+	}
+	logger.Info("server stopped")
+}
+
+// fatal logs msg at error level and exits.
+func fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
+
+// ensureIndexes runs the idempotent index bootstrap, retrying until it
+// succeeds; /readyz fails until then. Duplicate networks need an operator
+// to run the migrations, so they only hold back the unique index: the
+// server becomes ready and retries less often until they are merged.
+func ensureIndexes(ready *routes.Readiness) {
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := db.EnsureIndexes(ctx)
+		cancel()
+		if err == nil {
+			ready.SetIndexesReady()
+			return
+		}
+		if errors.Is(err, db.ErrDuplicateNetworks) {
+			ready.SetIndexesReady()
+			slog.Error("unique SSID + address index not built, retrying in 10m", "err", err)
+			time.Sleep(10 * time.Minute)
+			continue
+		}
+		slog.Error("failed to ensure MongoDB indexes, retrying in 30s", "err", err)
+		time.Sleep(30 * time.Second)
+	}
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM. It then calls
+// onShutdown, keeps serving for cfg.ShutdownDelay so load balancers see
+// readiness fail, stops accepting connections and waits up to
+// cfg.ShutdownTimeout for in-flight requests.
+func serve(cfg *config.Config, handler http.Handler, onShutdown func()) error {
+	srv := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           handler,
+		ReadTimeout:       cfg.HTTPReadTimeout,
+		ReadHeaderTimeout: cfg.HTTPReadHeaderTimeout,
+		WriteTimeout:      cfg.HTTPWriteTimeout,
+		IdleTimeout:       cfg.HTTPIdleTimeout,
+		MaxHeaderBytes:    cfg.HTTPMaxHeaderBytes,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("starting server", "port", cfg.Port)
+		errCh <- srv.ListenAndServe()
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+	}
+	// A second signal during the drain kills the process as usual
+	stop()
+	onShutdown()
+	if cfg.ShutdownDelay > 0 {
+		slog.Info("shutting down, waiting for load balancers", "delay", cfg.ShutdownDelay)
+		time.Sleep(cfg.ShutdownDelay)
+	}
+	slog.Info("shutting down, draining in-flight requests", "timeout", cfg.ShutdownTimeout)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		return fmt.Errorf("graceful shutdown: %w", err)
+	}
+	if err := <-errCh; !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// disconnect closes the MongoDB client, giving in-flight operations a few
+// seconds to finish.
+func disconnect() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := db.Disconnect(ctx); err != nil {
+		slog.Error("failed to disconnect from MongoDB", "err", err)
+	}
 }
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+
+	"wifi-go-backend/internal/db"
+	"wifi-go-backend/internal/migrations"
+)
+
+// runMigrate applies or reverts schema migrations:
+//
+//	server migrate [-dry-run] [up [-to N] | down [-steps N] | status]
+func runMigrate(args []string) error {
+	fs := flag.NewFlagSet("migrate", flag.ExitOnError)
+	dryRun := fs.Bool("dry-run", false, "print the migrations that would run without applying them")
+	to := fs.Int("to", 0, "up: stop after this version (default: latest)")
+	steps := fs.Int("steps", 1, "down: number of migrations to revert")
+	fs.Parse(args)
+
+	action := "up"
+	if fs.NArg() > 0 {
+		action = fs.Arg(0)
+		// Flags may also follow the action
+		fs.Parse(fs.Args()[1:])
+	}
+
+	database, err := db.GetDatabase()
+	if err != nil {
+		return err
+	}
+	m := migrations.NewMigrator(database)
+	m.DryRun = *dryRun
+	m.Logf = func(format string, args ...interface{}) {
+		slog.Info(fmt.Sprintf(format, args...))
+	}
+
+	ctx := context.Background()
+	switch action {
+	case "up":
+		return m.Up(ctx, *to)
+	case "down":
+		return m.Down(ctx, *steps)
+	case "status":
+		applied, err := m.Applied(ctx)
+		if err != nil {
+			return err
+		}
+		for _, a := range applied {
+			fmt.Printf("applied  %04d %s (%s)\n", a.Version, a.Name, a.AppliedAt.Format("2006-01-02 15:04:05"))
+		}
+		pending, err := m.Pending(ctx)
+		if err != nil {
+			return err
+		}
+		for _, p := range pending {
+			fmt.Printf("pending  %04d %s\n", p.Version, p.Name)
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown migrate action %q (want up, down or status)", action)
+	}
+}
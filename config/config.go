@@ -1,19 +1,200 @@
 package config
 
-import (
-	"os"
-)
+import "time"
 
 type Config struct {
-	MongoURI    string
+	// HTTP server
+	Port                  string
+	HTTPReadTimeout       time.Duration
+	HTTPReadHeaderTimeout time.Duration
+	HTTPWriteTimeout      time.Duration
+	HTTPIdleTimeout       time.Duration
+	HTTPMaxHeaderBytes    int
+	ShutdownTimeout       time.Duration // how long in-flight requests may drain
+	ShutdownDelay         time.Duration // /readyz fails this long before the listener closes
+	// Proxies whose X-Forwarded-For or Forwarded header names the client:
+	// by address, or the nearest TrustedProxyHops whatever their address
+	TrustedProxies   []string
+	TrustedProxyHops int
+
+	// Logging
+	LogFormat string // json or text
+	LogLevel  string // debug, info, warn or error
+
+	// MongoDB client
+	MongoURI              string
+	MongoDatabase         string
+	MongoConnectTimeout   time.Duration // connecting, server selection and the startup ping
+	MongoOperationTimeout time.Duration // per operation; 0 leaves it to the request context
+	MongoMaxPoolSize      int
+	MongoMinPoolSize      int
+	MongoMaxConnIdleTime  time.Duration
+	MongoReadPreference   string // primary, primaryPreferred, secondary, ...
+	MongoWriteConcern     string // "majority" or a number of nodes
+
+	// Civic OAuth client
 	OAuthClientID     string
-	OAuthClientSecret string
+	OAuthClientSecret string // optional with PKCE
+	OAuthRedirectURL  string // must match the Civic app config
+	CivicAuthURL      string
+	CivicTokenURL     string
+
+	// Civic id_token validation
+	CivicIssuer   string
+	CivicJWKSURL  string
+	CivicJWKSFile string // takes precedence over CivicJWKSURL when set
+	AuthClockSkew time.Duration
+
+	// Civic OAuth flow
+	AuthSessionTTL       time.Duration
+	AuthAllowedRedirects []string // URLs a client may ask to be sent back to, or below
+	CivicUpgradeScopes   []string // requested by POST /api/auth/upgrade
+
+	// First-party session tokens
+	TokenIssuer   string
+	TokenAudience string
+	SigningKeys   map[string]string // key ID -> PEM file path
+	ActiveKeyID   string
+	// EphemeralSigningKey allows running without SigningKeys on a key
+	// generated at startup; every restart logs everyone out (development only)
+	EphemeralSigningKey bool
+	AccessTokenTTL      time.Duration
+	RefreshTokenTTL     time.Duration
+
+	// Minimum verification levels (see models.VerificationLevel names)
+	ScanMinLevel    string
+	ConnectMinLevel string
+
+	// Networks a Civic-ID-verified user must add to become a trusted
+	// contributor; 0 turns promotion off
+	TrustedContributorMinNetworks int
+
+	// Master keys for WiFi password encryption (base64, 32 bytes)
+	PasswordKeyFile         string
+	PasswordRetiredKeyFiles []string
+
+	// WiFi connect
+	ConnectTicketTTL time.Duration
+	ConnectPresence  string // location, either (location or scan list) or both
+
+	// Location plausibility checks on WiFi connect; a score threshold of 0
+	// turns that action off
+	RiskEnabled       bool
+	RiskMaxSpeedKmh   int
+	RiskMaxAccuracyM  int
+	RiskHistory       time.Duration
+	RiskNetworkWindow time.Duration
+	RiskMaxNetworks   int
+	RiskDenyScore     int
+	RiskStepUpScore   int
+	RiskStepUpLevel   string
+
+	// Rate limits are token buckets written "count/interval" (e.g. "30/1h");
+	// "off" disables one
+	RateLimitEnabled     bool
+	RateLimitStore       string // memory (per process) or mongo (shared by replicas)
+	RateLimitIP          string // every /api route, per client IP
+	RateLimitConnectUser string // password reveals and tickets
+	RateLimitConnectIP   string
+	RateLimitGeminiUser  string // AI recommendations; anonymous callers count by IP
+	RateLimitGeminiIP    string
+
+	// Gemini route recommendations
+	GeminiEnabled bool
+	GeminiAPIKey  string
+	GeminiModel   string
 }
 
-func Load() *Config {
-	return &Config{
-		MongoURI:    os.Getenv("MONGO_URI"),
-		OAuthClientID:     os.Getenv("OAUTH_CLIENT_ID"),
-		OAuthClientSecret: os.Getenv("OAUTH_CLIENT_SECRET"),
+// Load reads the configuration from the environment, falling back to the
+// YAML or TOML file named by CONFIG_FILE. Any setting may instead be given
+// as NAME_FILE, the path of a file holding the value (for mounted secrets).
+// The returned error lists every value that could not be parsed; call
+// Validate for the settings the server needs.
+func Load() (*Config, error) {
+	src, err := newSource(lookupEnv("CONFIG_FILE"))
+	if err != nil {
+		return nil, err
+	}
+	cfg := &Config{
+		Port:                  src.get("PORT", "8080"),
+		HTTPReadTimeout:       src.duration("HTTP_READ_TIMEOUT", 15*time.Second),
+		HTTPReadHeaderTimeout: src.duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+		HTTPWriteTimeout:      src.duration("HTTP_WRITE_TIMEOUT", 60*time.Second),
+		HTTPIdleTimeout:       src.duration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+		HTTPMaxHeaderBytes:    src.int("HTTP_MAX_HEADER_BYTES", 1<<20),
+		ShutdownTimeout:       src.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+		ShutdownDelay:         src.duration("SHUTDOWN_DELAY", 0),
+		TrustedProxies:        src.list("TRUSTED_PROXIES", nil),
+		TrustedProxyHops:      src.int("TRUSTED_PROXY_HOPS", 0),
+
+		LogFormat: src.get("LOG_FORMAT", "json"),
+		LogLevel:  src.get("LOG_LEVEL", "info"),
+
+		MongoURI:              src.get("MONGO_URI", ""),
+		MongoDatabase:         src.get("MONGO_DATABASE", "wifi_db"),
+		MongoConnectTimeout:   src.duration("MONGO_CONNECT_TIMEOUT", 10*time.Second),
+		MongoOperationTimeout: src.duration("MONGO_OPERATION_TIMEOUT", 10*time.Second),
+		MongoMaxPoolSize:      src.int("MONGO_MAX_POOL_SIZE", 100),
+		MongoMinPoolSize:      src.int("MONGO_MIN_POOL_SIZE", 0),
+		MongoMaxConnIdleTime:  src.duration("MONGO_MAX_CONN_IDLE_TIME", 5*time.Minute),
+		MongoReadPreference:   src.get("MONGO_READ_PREFERENCE", "primary"),
+		MongoWriteConcern:     src.get("MONGO_WRITE_CONCERN", "majority"),
+
+		OAuthClientID:     src.get("OAUTH_CLIENT_ID", ""),
+		OAuthClientSecret: src.get("OAUTH_CLIENT_SECRET", ""),
+		OAuthRedirectURL:  src.get("OAUTH_REDIRECT_URL", ""),
+		CivicAuthURL:      src.get("CIVIC_AUTH_URL", "https://auth.civic.com/oauth"),
+		CivicTokenURL:     src.get("CIVIC_TOKEN_URL", "https://auth.civic.com/oauth/token"),
+
+		CivicIssuer:   src.get("CIVIC_ISSUER", "https://auth.civic.com/oauth"),
+		CivicJWKSURL:  src.get("CIVIC_JWKS_URL", "https://auth.civic.com/oauth/jwks"),
+		CivicJWKSFile: src.get("CIVIC_JWKS_FILE", ""),
+		AuthClockSkew: src.duration("AUTH_CLOCK_SKEW", time.Minute),
+
+		AuthSessionTTL:       src.duration("AUTH_SESSION_TTL", 10*time.Minute),
+		AuthAllowedRedirects: src.list("AUTH_ALLOWED_REDIRECTS", nil),
+		CivicUpgradeScopes:   src.list("CIVIC_UPGRADE_SCOPES", []string{"openid", "profile", "email", "verification"}),
+
+		TokenIssuer:         src.get("AUTH_TOKEN_ISSUER", "wifi-go-backend"),
+		TokenAudience:       src.get("AUTH_TOKEN_AUDIENCE", "wifi-go-backend"),
+		SigningKeys:         src.stringMap("AUTH_SIGNING_KEYS"),
+		ActiveKeyID:         src.get("AUTH_ACTIVE_KEY_ID", ""),
+		EphemeralSigningKey: src.bool("AUTH_EPHEMERAL_SIGNING_KEY", false),
+		AccessTokenTTL:      src.duration("AUTH_ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:     src.duration("AUTH_REFRESH_TOKEN_TTL", 30*24*time.Hour),
+
+		ScanMinLevel:    src.get("WIFI_SCAN_MIN_LEVEL", "email_verified"),
+		ConnectMinLevel: src.get("WIFI_CONNECT_MIN_LEVEL", "anonymous"),
+
+		TrustedContributorMinNetworks: src.int("TRUSTED_CONTRIBUTOR_MIN_NETWORKS", 10),
+
+		PasswordKeyFile:         src.get("WIFI_PASSWORD_KEY_FILE", ""),
+		PasswordRetiredKeyFiles: src.list("WIFI_PASSWORD_RETIRED_KEY_FILES", nil),
+
+		ConnectTicketTTL: src.duration("WIFI_CONNECT_TICKET_TTL", 2*time.Minute),
+		ConnectPresence:  src.get("WIFI_CONNECT_PRESENCE", "location"),
+
+		RiskEnabled:       src.bool("RISK_ENABLED", true),
+		RiskMaxSpeedKmh:   src.int("RISK_MAX_SPEED_KMH", 900),
+		RiskMaxAccuracyM:  src.int("RISK_MAX_ACCURACY_M", 100),
+		RiskHistory:       src.duration("RISK_HISTORY", 24*time.Hour),
+		RiskNetworkWindow: src.duration("RISK_NETWORK_WINDOW", time.Hour),
+		RiskMaxNetworks:   src.int("RISK_MAX_NETWORKS", 10),
+		RiskDenyScore:     src.int("RISK_DENY_SCORE", 80),
+		RiskStepUpScore:   src.int("RISK_STEP_UP_SCORE", 50),
+		RiskStepUpLevel:   src.get("RISK_STEP_UP_LEVEL", "civic_id_verified"),
+
+		RateLimitEnabled:     src.bool("RATE_LIMIT_ENABLED", true),
+		RateLimitStore:       src.get("RATE_LIMIT_STORE", "memory"),
+		RateLimitIP:          src.get("RATE_LIMIT_IP", "300/1m"),
+		RateLimitConnectUser: src.get("RATE_LIMIT_CONNECT_USER", "30/1h"),
+		RateLimitConnectIP:   src.get("RATE_LIMIT_CONNECT_IP", "120/1h"),
+		RateLimitGeminiUser:  src.get("RATE_LIMIT_GEMINI_USER", "20/24h"),
+		RateLimitGeminiIP:    src.get("RATE_LIMIT_GEMINI_IP", "100/24h"),
+
+		GeminiEnabled: src.bool("FEATURE_GEMINI", true),
+		GeminiAPIKey:  src.get("GEMINI_API_KEY", ""),
+		GeminiModel:   src.get("GEMINI_MODEL", "gemini-1.5-flash"),
 	}
+	return cfg, src.err()
 }
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// source resolves settings by environment variable name. Lookup order:
+// NAME in the environment, the file named by NAME_FILE, then the config
+// file. Problems are collected rather than returned one at a time.
+type source struct {
+	file     map[string]string
+	problems []string
+}
+
+func newSource(path string) (*source, error) {
+	s := &source{file: map[string]string{}}
+	if path == "" {
+		return s, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("config file: %w", err)
+	}
+	var raw map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &raw)
+	case ".toml":
+		err = toml.Unmarshal(data, &raw)
+	default:
+		return nil, fmt.Errorf("config file %s: want .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	flatten("", raw, s.file)
+	return s, nil
+}
+
+// flatten maps nested tables onto environment variable names, so
+// "mongo: {uri: ...}" and "mongo_uri: ..." both set MONGO_URI. Lists become
+// comma-separated values.
+func flatten(prefix string, in map[string]interface{}, out map[string]string) {
+	for k, v := range in {
+		key := strings.ToUpper(k)
+		if prefix != "" {
+			key = prefix + "_" + key
+		}
+		switch v := v.(type) {
+		case map[string]interface{}:
+			if mapSettings[key] {
+				out[key] = joinMap(v)
+			} else {
+				flatten(key, v, out)
+			}
+		case []interface{}:
+			parts := make([]string, len(v))
+			for i, item := range v {
+				parts[i] = fmt.Sprint(item)
+			}
+			out[key] = strings.Join(parts, ",")
+		default:
+			out[key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// mapSettings are read with stringMap; in a config file they are written as
+// a table instead of "k1=v1,k2=v2".
+var mapSettings = map[string]bool{"AUTH_SIGNING_KEYS": true}
+
+func joinMap(m map[string]interface{}) string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + fmt.Sprint(m[k])
+	}
+	return strings.Join(pairs, ",")
+}
+
+func lookupEnv(key string) string {
+	return strings.TrimSpace(os.Getenv(key))
+}
+
+func (s *source) lookup(key string) string {
+	if v := lookupEnv(key); v != "" {
+		return v
+	}
+	path := lookupEnv(key + "_FILE")
+	if path == "" {
+		path = s.file[key+"_FILE"]
+	}
+	if path != "" {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			s.problems = append(s.problems, fmt.Sprintf("%s_FILE: %v", key, err))
+			return ""
+		}
+		return strings.TrimSpace(string(data))
+	}
+	return s.file[key]
+}
+
+func (s *source) get(key, fallback string) string {
+	if v := s.lookup(key); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (s *source) duration(key string, fallback time.Duration) time.Duration {
+	v := s.lookup(key)
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		s.problems = append(s.problems, fmt.Sprintf("%s: invalid duration %q", key, v))
+		return fallback
+	}
+	return d
+}
+
+func (s *source) int(key string, fallback int) int {
+	v := s.lookup(key)
+	if v == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		s.problems = append(s.problems, fmt.Sprintf("%s: invalid integer %q", key, v))
+		return fallback
+	}
+	return n
+}
+
+func (s *source) bool(key string, fallback bool) bool {
+	v := s.lookup(key)
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		s.problems = append(s.problems, fmt.Sprintf("%s: invalid boolean %q", key, v))
+		return fallback
+	}
+	return b
+}
+
+func (s *source) list(key string, fallback []string) []string {
+	var out []string
+	for _, v := range strings.Split(s.lookup(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	if len(out) == 0 {
+		return fallback
+	}
+	return out
+}
+
+// stringMap parses "k1=v1,k2=v2".
+func (s *source) stringMap(key string) map[string]string {
+	out := map[string]string{}
+	for _, pair := range s.list(key, nil) {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok {
+			s.problems = append(s.problems, fmt.Sprintf("%s: %q is not key=value", key, pair))
+			continue
+		}
+		out[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return out
+}
+
+func (s *source) err() error {
+	if len(s.problems) == 0 {
+		return nil
+	}
+	return &Error{Problems: s.problems}
+}
+
+// Error lists every invalid or missing setting found.
+type Error struct {
+	Problems []string
+}
+
+func (e *Error) Error() string {
+	return "invalid configuration:\n  - " + strings.Join(e.Problems, "\n  - ")
+}
@@ -0,0 +1,188 @@
+package config
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+// writeFile writes content to name in a temporary directory and returns
+// its path.
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// loadFile loads with CONFIG_FILE set to a file holding content, and env
+// applied on top. Settings the test reads are cleared from the environment
+// first so the file shows through.
+func loadFile(t *testing.T, name, content string, env map[string]string) (*Config, error) {
+	t.Helper()
+	for _, k := range []string{"MONGO_URI", "MONGO_DATABASE", "MONGO_MAX_POOL_SIZE", "LOG_LEVEL", "AUTH_SIGNING_KEYS",
+		"AUTH_ALLOWED_REDIRECTS", "OAUTH_CLIENT_SECRET", "OAUTH_CLIENT_SECRET_FILE", "RATE_LIMIT_ENABLED", "HTTP_READ_TIMEOUT"} {
+		t.Setenv(k, "")
+	}
+	t.Setenv("CONFIG_FILE", "")
+	if name != "" {
+		t.Setenv("CONFIG_FILE", writeFile(t, name, content))
+	}
+	for k, v := range env {
+		t.Setenv(k, v)
+	}
+	return Load()
+}
+
+const yamlConfig = `
+mongo:
+  uri: mongodb://file:27017
+  max_pool_size: 50
+mongo_database: from_file
+log_level: debug
+http_read_timeout: 5s
+rate_limit_enabled: false
+auth:
+  signing_keys:
+    k2: /keys/k2.pem
+    k1: /keys/k1.pem
+  allowed_redirects:
+    - https://app.example.com/auth
+    - tap2wifi://auth
+`
+
+func TestLoadYAML(t *testing.T) {
+	for _, name := range []string{"config.yaml", "config.yml"} {
+		cfg, err := loadFile(t, name, yamlConfig, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if cfg.MongoURI != "mongodb://file:27017" || cfg.MongoDatabase != "from_file" || cfg.MongoMaxPoolSize != 50 {
+			t.Errorf("%s: nested and flat keys: %q %q %d", name, cfg.MongoURI, cfg.MongoDatabase, cfg.MongoMaxPoolSize)
+		}
+		if cfg.LogLevel != "debug" || cfg.HTTPReadTimeout != 5*time.Second || cfg.RateLimitEnabled {
+			t.Errorf("%s: scalars: %q %v %v", name, cfg.LogLevel, cfg.HTTPReadTimeout, cfg.RateLimitEnabled)
+		}
+		if want := map[string]string{"k1": "/keys/k1.pem", "k2": "/keys/k2.pem"}; !reflect.DeepEqual(cfg.SigningKeys, want) {
+			t.Errorf("%s: signing keys = %v, want %v", name, cfg.SigningKeys, want)
+		}
+		if want := []string{"https://app.example.com/auth", "tap2wifi://auth"}; !reflect.DeepEqual(cfg.AuthAllowedRedirects, want) {
+			t.Errorf("%s: redirects = %v, want %v", name, cfg.AuthAllowedRedirects, want)
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	cfg, err := loadFile(t, "config.toml", `
+mongo_database = "from_toml"
+auth_allowed_redirects = ["https://app.example.com/auth"]
+
+[mongo]
+uri = "mongodb://toml:27017"
+max_pool_size = 20
+
+[auth.signing_keys]
+k1 = "/keys/k1.pem"
+`, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MongoURI != "mongodb://toml:27017" || cfg.MongoDatabase != "from_toml" || cfg.MongoMaxPoolSize != 20 {
+		t.Errorf("mongo = %q %q %d", cfg.MongoURI, cfg.MongoDatabase, cfg.MongoMaxPoolSize)
+	}
+	if cfg.SigningKeys["k1"] != "/keys/k1.pem" || len(cfg.AuthAllowedRedirects) != 1 {
+		t.Errorf("signing keys = %v, redirects = %v", cfg.SigningKeys, cfg.AuthAllowedRedirects)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	secret := writeFile(t, "civic-secret", "from-secret-file\n")
+	// The environment beats the file, and NAME beats NAME_FILE
+	cfg, err := loadFile(t, "config.yaml", yamlConfig+"oauth_client_secret: from-config\n", map[string]string{
+		"MONGO_URI":                "mongodb://env:27017",
+		"OAUTH_CLIENT_SECRET":      "from-env",
+		"OAUTH_CLIENT_SECRET_FILE": secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MongoURI != "mongodb://env:27017" || cfg.MongoDatabase != "from_file" {
+		t.Errorf("MONGO_URI = %q, MONGO_DATABASE = %q; want env, then file", cfg.MongoURI, cfg.MongoDatabase)
+	}
+	if cfg.OAuthClientSecret != "from-env" {
+		t.Errorf("OAUTH_CLIENT_SECRET = %q, want the environment's", cfg.OAuthClientSecret)
+	}
+
+	// NAME_FILE from the environment beats NAME in the config file, and the
+	// value is trimmed
+	cfg, err = loadFile(t, "config.yaml", yamlConfig+"oauth_client_secret: from-config\n", map[string]string{
+		"OAUTH_CLIENT_SECRET_FILE": secret,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OAuthClientSecret != "from-secret-file" {
+		t.Errorf("OAUTH_CLIENT_SECRET = %q, want the secret file's", cfg.OAuthClientSecret)
+	}
+
+	// NAME_FILE may also be set in the config file
+	cfg, err = loadFile(t, "config.yaml", "oauth_client_secret_file: "+secret+"\n", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OAuthClientSecret != "from-secret-file" {
+		t.Errorf("OAUTH_CLIENT_SECRET = %q via the config file's _FILE, want the secret file's", cfg.OAuthClientSecret)
+	}
+
+	// Without either, defaults apply
+	cfg, err = loadFile(t, "", "", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.MongoDatabase != "wifi_db" || cfg.MongoMaxPoolSize != 100 {
+		t.Errorf("defaults = %q %d", cfg.MongoDatabase, cfg.MongoMaxPoolSize)
+	}
+}
+
+func TestLoadCollectsProblems(t *testing.T) {
+	_, err := loadFile(t, "config.yaml", "mongo_max_pool_size: lots\nhttp_read_timeout: soon\nauth_signing_keys: k1\n", map[string]string{
+		"OAUTH_CLIENT_SECRET_FILE": filepath.Join(t.TempDir(), "missing"),
+		"RATE_LIMIT_ENABLED":       "maybe",
+	})
+	var cfgErr *Error
+	if !errors.As(err, &cfgErr) {
+		t.Fatalf("Load() = %v, want *Error", err)
+	}
+	for _, name := range []string{"MONGO_MAX_POOL_SIZE", "HTTP_READ_TIMEOUT", "AUTH_SIGNING_KEYS", "OAUTH_CLIENT_SECRET_FILE", "RATE_LIMIT_ENABLED"} {
+		found := false
+		for _, p := range cfgErr.Problems {
+			found = found || strings.HasPrefix(p, name+":")
+		}
+		if !found {
+			t.Errorf("no problem reported for %s in %q", name, cfgErr.Problems)
+		}
+	}
+}
+
+func TestLoadRejectsBadConfigFiles(t *testing.T) {
+	tests := map[string]string{
+		"config.json": `{"mongo_uri": "x"}`,
+		"config.yaml": "mongo: [unclosed",
+		"config.toml": "mongo_uri = ",
+	}
+	for name, content := range tests {
+		if _, err := loadFile(t, name, content, nil); err == nil {
+			t.Errorf("%s: Load() succeeded", name)
+		}
+	}
+	t.Setenv("CONFIG_FILE", filepath.Join(t.TempDir(), "missing.yaml"))
+	if _, err := Load(); err == nil {
+		t.Error("Load() with a missing CONFIG_FILE succeeded")
+	}
+}
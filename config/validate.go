@@ -0,0 +1,230 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/utils"
+)
+
+// Validate checks the settings the HTTP server needs. It reports every
+// problem at once so a misconfigured deployment can be fixed in one pass.
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if port, err := strconv.Atoi(c.Port); err != nil || port < 1 || port > 65535 {
+		add("PORT: invalid port %q", c.Port)
+	}
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"HTTP_READ_TIMEOUT", c.HTTPReadTimeout},
+		{"HTTP_READ_HEADER_TIMEOUT", c.HTTPReadHeaderTimeout},
+		{"HTTP_WRITE_TIMEOUT", c.HTTPWriteTimeout},
+		{"HTTP_IDLE_TIMEOUT", c.HTTPIdleTimeout},
+		{"SHUTDOWN_TIMEOUT", c.ShutdownTimeout},
+		{"MONGO_CONNECT_TIMEOUT", c.MongoConnectTimeout},
+		{"AUTH_SESSION_TTL", c.AuthSessionTTL},
+		{"AUTH_ACCESS_TOKEN_TTL", c.AccessTokenTTL},
+		{"AUTH_REFRESH_TOKEN_TTL", c.RefreshTokenTTL},
+		{"WIFI_CONNECT_TICKET_TTL", c.ConnectTicketTTL},
+		{"RISK_HISTORY", c.RiskHistory},
+		{"RISK_NETWORK_WINDOW", c.RiskNetworkWindow},
+	} {
+		if d.value <= 0 {
+			add("%s: must be positive", d.name)
+		}
+	}
+	if c.ShutdownDelay < 0 {
+		add("SHUTDOWN_DELAY: must not be negative")
+	}
+	if c.HTTPMaxHeaderBytes <= 0 {
+		add("HTTP_MAX_HEADER_BYTES: must be positive")
+	}
+	if c.TrustedProxyHops < 0 {
+		add("TRUSTED_PROXY_HOPS: must not be negative")
+	}
+	for _, p := range c.TrustedProxies {
+		if _, err := utils.ParseTrustedProxies([]string{p}, 0); err != nil {
+			add("TRUSTED_PROXIES: %v", err)
+		}
+	}
+
+	if c.LogFormat != "json" && c.LogFormat != "text" {
+		add("LOG_FORMAT: want json or text, got %q", c.LogFormat)
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("LOG_LEVEL: want debug, info, warn or error, got %q", c.LogLevel)
+	}
+
+	if c.MongoURI == "" {
+		add("MONGO_URI: required")
+	}
+	if c.MongoDatabase == "" {
+		add("MONGO_DATABASE: required")
+	}
+	if c.MongoMaxPoolSize < 0 || c.MongoMinPoolSize < 0 {
+		add("MONGO_MIN_POOL_SIZE, MONGO_MAX_POOL_SIZE: must not be negative")
+	} else if c.MongoMaxPoolSize > 0 && c.MongoMinPoolSize > c.MongoMaxPoolSize {
+		add("MONGO_MIN_POOL_SIZE: exceeds MONGO_MAX_POOL_SIZE")
+	}
+	switch c.MongoReadPreference {
+	case "primary", "primaryPreferred", "secondary", "secondaryPreferred", "nearest":
+	default:
+		add("MONGO_READ_PREFERENCE: unknown mode %q", c.MongoReadPreference)
+	}
+	// Unacknowledged writes (0) cannot report duplicate keys, which the
+	// unique indexes rely on to refuse duplicates
+	if c.MongoWriteConcern != "majority" {
+		if n, err := strconv.Atoi(c.MongoWriteConcern); err != nil || n < 1 {
+			add("MONGO_WRITE_CONCERN: want \"majority\" or a node count of at least 1, got %q", c.MongoWriteConcern)
+		}
+	}
+
+	if c.OAuthClientID == "" {
+		add("OAUTH_CLIENT_ID: required")
+	}
+	if c.OAuthRedirectURL == "" {
+		add("OAUTH_REDIRECT_URL: required")
+	} else if !absoluteURL(c.OAuthRedirectURL) {
+		add("OAUTH_REDIRECT_URL: %q is not an absolute URL", c.OAuthRedirectURL)
+	}
+	if !absoluteURL(c.CivicAuthURL) {
+		add("CIVIC_AUTH_URL: %q is not an absolute URL", c.CivicAuthURL)
+	}
+	if !absoluteURL(c.CivicTokenURL) {
+		add("CIVIC_TOKEN_URL: %q is not an absolute URL", c.CivicTokenURL)
+	}
+	if c.CivicJWKSFile == "" && !absoluteURL(c.CivicJWKSURL) {
+		add("CIVIC_JWKS_URL: %q is not an absolute URL", c.CivicJWKSURL)
+	}
+
+	for _, target := range c.AuthAllowedRedirects {
+		if !absoluteURL(target) {
+			add("AUTH_ALLOWED_REDIRECTS: %q is not an absolute URL", target)
+		}
+	}
+
+	if len(c.SigningKeys) == 0 && !c.EphemeralSigningKey {
+		add("AUTH_SIGNING_KEYS: required (AUTH_EPHEMERAL_SIGNING_KEY=true runs on a throwaway key in development)")
+	} else if len(c.SigningKeys) > 1 && c.ActiveKeyID == "" {
+		add("AUTH_ACTIVE_KEY_ID: required when AUTH_SIGNING_KEYS has more than one key")
+	} else if _, ok := c.SigningKeys[c.ActiveKeyID]; c.ActiveKeyID != "" && !ok {
+		add("AUTH_ACTIVE_KEY_ID: %q is not in AUTH_SIGNING_KEYS", c.ActiveKeyID)
+	}
+
+	for _, l := range []struct {
+		name  string
+		value string
+	}{
+		{"WIFI_SCAN_MIN_LEVEL", c.ScanMinLevel},
+		{"WIFI_CONNECT_MIN_LEVEL", c.ConnectMinLevel},
+		{"RISK_STEP_UP_LEVEL", c.RiskStepUpLevel},
+	} {
+		if _, err := models.ParseVerificationLevel(l.value); err != nil {
+			add("%s: %v", l.name, err)
+		}
+	}
+
+	if c.PasswordKeyFile == "" {
+		add("WIFI_PASSWORD_KEY_FILE: required")
+	}
+	switch c.ConnectPresence {
+	case "location", "either", "both":
+	default:
+		add("WIFI_CONNECT_PRESENCE: want location, either or both, got %q", c.ConnectPresence)
+	}
+
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"RISK_MAX_SPEED_KMH", c.RiskMaxSpeedKmh},
+		{"RISK_MAX_ACCURACY_M", c.RiskMaxAccuracyM},
+		{"RISK_MAX_NETWORKS", c.RiskMaxNetworks},
+		{"TRUSTED_CONTRIBUTOR_MIN_NETWORKS", c.TrustedContributorMinNetworks},
+	} {
+		if n.value < 0 {
+			add("%s: must not be negative", n.name)
+		}
+	}
+	for _, n := range []struct {
+		name  string
+		value int
+	}{
+		{"RISK_DENY_SCORE", c.RiskDenyScore},
+		{"RISK_STEP_UP_SCORE", c.RiskStepUpScore},
+	} {
+		if n.value < 0 || n.value > 100 {
+			add("%s: must be between 0 and 100", n.name)
+		}
+	}
+
+	if c.RateLimitStore != "mongo" && c.RateLimitStore != "memory" {
+		add("RATE_LIMIT_STORE: %q is not mongo or memory", c.RateLimitStore)
+	}
+	for _, r := range []struct {
+		name string
+		spec string
+	}{
+		{"RATE_LIMIT_IP", c.RateLimitIP},
+		{"RATE_LIMIT_CONNECT_USER", c.RateLimitConnectUser},
+		{"RATE_LIMIT_CONNECT_IP", c.RateLimitConnectIP},
+		{"RATE_LIMIT_GEMINI_USER", c.RateLimitGeminiUser},
+		{"RATE_LIMIT_GEMINI_IP", c.RateLimitGeminiIP},
+	} {
+		if _, _, err := ParseRate(r.spec); err != nil {
+			add("%s: %v", r.name, err)
+		}
+	}
+
+	if c.GeminiEnabled && c.GeminiAPIKey == "" {
+		add("GEMINI_API_KEY: required unless FEATURE_GEMINI=false")
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return &Error{Problems: problems}
+}
+
+// ParseRate reads a rate limit spec as "count/interval", e.g. "30/1h" or
+// "20/24h"; a bare unit such as "30/h" means one of it. "" and "off" return
+// a zero limit, which disables the policy.
+func ParseRate(spec string) (limit int, interval time.Duration, err error) {
+	spec = strings.TrimSpace(spec)
+	if spec == "" || spec == "off" {
+		return 0, 0, nil
+	}
+	count, per, ok := strings.Cut(spec, "/")
+	if !ok {
+		return 0, 0, fmt.Errorf("%q: want count/interval, e.g. 30/1h", spec)
+	}
+	limit, err = strconv.Atoi(count)
+	if err != nil || limit < 1 {
+		return 0, 0, fmt.Errorf("%q: count must be a positive integer", spec)
+	}
+	if per != "" && !strings.ContainsAny(per[:1], "0123456789") {
+		per = "1" + per
+	}
+	interval, err = time.ParseDuration(per)
+	if err != nil || interval < time.Second {
+		return 0, 0, fmt.Errorf("%q: interval must be a duration of at least 1s", spec)
+	}
+	return limit, interval, nil
+}
+
+func absoluteURL(raw string) bool {
+	u, err := url.Parse(raw)
+	return err == nil && u.Scheme != "" && u.Host != ""
+}
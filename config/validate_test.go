@@ -0,0 +1,111 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+// loadValid loads a configuration that passes Validate, with env applied
+// on top.
+func loadValid(t *testing.T, env map[string]string) *Config {
+	t.Helper()
+	base := map[string]string{
+		"MONGO_URI":                  "mongodb://localhost:27017",
+		"OAUTH_CLIENT_ID":            "client",
+		"OAUTH_REDIRECT_URL":         "https://api.example.com/api/auth/civic/callback",
+		"AUTH_EPHEMERAL_SIGNING_KEY": "true",
+		"WIFI_PASSWORD_KEY_FILE":     "/run/secrets/wifi.key",
+		"FEATURE_GEMINI":             "false",
+	}
+	for k, v := range env {
+		base[k] = v
+	}
+	for k, v := range base {
+		t.Setenv(k, v)
+	}
+	cfg, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestValidateAcceptsDefaults(t *testing.T) {
+	cfg := loadValid(t, nil)
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	// A scan list is client-supplied; only the distance proves presence
+	// unless an operator opts in
+	if cfg.ConnectPresence != "location" {
+		t.Errorf("ConnectPresence = %q, want location", cfg.ConnectPresence)
+	}
+}
+
+func TestValidateCollectsLevelAndRateLimitProblems(t *testing.T) {
+	cfg := loadValid(t, map[string]string{
+		"WIFI_SCAN_MIN_LEVEL":     "admin",
+		"WIFI_CONNECT_MIN_LEVEL":  "verified",
+		"RISK_STEP_UP_LEVEL":      "civic",
+		"RATE_LIMIT_IP":           "lots",
+		"RATE_LIMIT_CONNECT_USER": "0/1h",
+		"RATE_LIMIT_GEMINI_IP":    "10/1ms",
+		"RATE_LIMIT_GEMINI_USER":  "off",
+	})
+	var cfgErr *Error
+	if err := cfg.Validate(); !errors.As(err, &cfgErr) {
+		t.Fatalf("Validate() = %v, want *Error", err)
+	}
+	want := []string{
+		"WIFI_SCAN_MIN_LEVEL", "WIFI_CONNECT_MIN_LEVEL", "RISK_STEP_UP_LEVEL",
+		"RATE_LIMIT_IP", "RATE_LIMIT_CONNECT_USER", "RATE_LIMIT_GEMINI_IP",
+	}
+	if len(cfgErr.Problems) != len(want) {
+		t.Errorf("problems = %q, want one for each of %v", cfgErr.Problems, want)
+	}
+	for _, name := range want {
+		found := false
+		for _, p := range cfgErr.Problems {
+			found = found || strings.HasPrefix(p, name+":")
+		}
+		if !found {
+			t.Errorf("no problem reported for %s", name)
+		}
+	}
+}
+
+func TestParseRate(t *testing.T) {
+	tests := []struct {
+		spec     string
+		limit    int
+		interval time.Duration
+		ok       bool
+	}{
+		{"30/1h", 30, time.Hour, true},
+		{"20/h", 20, time.Hour, true},
+		{" 5/30s ", 5, 30 * time.Second, true},
+		{"off", 0, 0, true},
+		{"", 0, 0, true},
+		{"30", 0, 0, false},
+		{"-1/1h", 0, 0, false},
+		{"3/fortnight", 0, 0, false},
+		{"3/500ms", 0, 0, false},
+	}
+	for _, tt := range tests {
+		limit, interval, err := ParseRate(tt.spec)
+		if (err == nil) != tt.ok || limit != tt.limit || interval != tt.interval {
+			t.Errorf("ParseRate(%q) = %d, %v, %v; want %d, %v, ok=%v", tt.spec, limit, interval, err, tt.limit, tt.interval, tt.ok)
+		}
+	}
+}
+
+func TestValidateRequiresAcknowledgedWrites(t *testing.T) {
+	for concern, ok := range map[string]bool{"majority": true, "1": true, "3": true, "0": false, "-1": false, "all": false} {
+		err := loadValid(t, map[string]string{"MONGO_WRITE_CONCERN": concern}).Validate()
+		if (err == nil) != ok {
+			t.Errorf("MONGO_WRITE_CONCERN=%s: Validate() = %v, want ok=%v", concern, err, ok)
+		}
+	}
+}
@@ -1,17 +1,25 @@
 package auth
 
 import (
+	"wifi-go-backend/config"
+
 	"golang.org/x/oauth2"
 )
 
-// Replace these values with your Civic app details or load from env/config in production.
-var CivicOauthConfig = &oauth2.Config{
-	ClientID:     "YOUR_CIVIC_CLIENT_ID",
-	ClientSecret: "YOUR_CIVIC_CLIENT_SECRET",                        // May not be needed if using PKCE
-	RedirectURL:  "https://yourbackend.com/api/auth/civic/callback", // Must match your Civic app config
-	Scopes:       []string{"openid"},
-	Endpoint: oauth2.Endpoint{
-		AuthURL:  "https://auth.civic.com/oauth",
-		TokenURL: "https://auth.civic.com/oauth/token",
-	},
+// CivicScopes are requested on a regular Civic sign-in.
+var CivicScopes = []string{"openid", "profile", "email"}
+
+// NewCivicOauthConfig builds the OAuth client for Civic from cfg. The client
+// secret may be empty since the flow uses PKCE.
+func NewCivicOauthConfig(cfg *config.Config) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     cfg.OAuthClientID,
+		ClientSecret: cfg.OAuthClientSecret,
+		RedirectURL:  cfg.OAuthRedirectURL,
+		Scopes:       CivicScopes,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  cfg.CivicAuthURL,
+			TokenURL: cfg.CivicTokenURL,
+		},
+	}
 }
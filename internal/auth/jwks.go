@@ -0,0 +1,242 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+var ErrUnknownKey = errors.New("no matching signing key")
+
+// KeySet resolves the public key used to verify a token by its key ID.
+type KeySet interface {
+	Key(ctx context.Context, kid string) (crypto.PublicKey, error)
+}
+
+// StaticKeySet is a fixed set of keys, e.g. loaded from a local JWKS file or
+// taken from an in-process Signer.
+type StaticKeySet map[string]crypto.PublicKey
+
+func (s StaticKeySet) Key(_ context.Context, kid string) (crypto.PublicKey, error) {
+	if key, ok := s[kid]; ok {
+		return key, nil
+	}
+	// Tokens without a kid are only accepted when there is no ambiguity.
+	if kid == "" && len(s) == 1 {
+		for _, key := range s {
+			return key, nil
+		}
+	}
+	return nil, ErrUnknownKey
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	Y   string `json:"y,omitempty"`
+}
+
+// ParseJWKS decodes a JSON Web Key Set document. Keys that are not meant for
+// signatures or use an unsupported key type are skipped.
+func ParseJWKS(data []byte) (StaticKeySet, error) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to parse JWKS: %w", err)
+	}
+	keys := StaticKeySet{}
+	for _, k := range doc.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+		pub, err := k.publicKey()
+		if err != nil {
+			continue
+		}
+		keys[k.Kid] = pub
+	}
+	if len(keys) == 0 {
+		return nil, errors.New("JWKS contains no usable signing keys")
+	}
+	return keys, nil
+}
+
+// LoadJWKSFile reads a JWKS document from disk, for running without network.
+func LoadJWKSFile(path string) (StaticKeySet, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS file: %w", err)
+	}
+	return ParseJWKS(data)
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{
+			Curve: curve,
+			X:     new(big.Int).SetBytes(x),
+			Y:     new(big.Int).SetBytes(y),
+		}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+// RemoteKeySet fetches and caches a JWKS over HTTP. An unknown kid or an
+// expired cache triggers a refresh (at most once per minRefresh) so provider
+// key rotation is picked up. Concurrent refreshes share one fetch, which runs
+// without holding the cache lock, and a failed refresh keeps the last good
+// keys in use. Before the first successful fetch, failures are retried no
+// more often either; callers in between get the last fetch error.
+type RemoteKeySet struct {
+	url        string
+	client     *http.Client
+	maxAge     time.Duration
+	minRefresh time.Duration
+
+	group singleflight.Group
+
+	mu          sync.Mutex
+	keys        StaticKeySet
+	fetchedAt   time.Time
+	attemptedAt time.Time
+	lastErr     error
+}
+
+func NewRemoteKeySet(url string) *RemoteKeySet {
+	return &RemoteKeySet{
+		url:        url,
+		client:     &http.Client{Timeout: 10 * time.Second},
+		maxAge:     time.Hour,
+		minRefresh: time.Minute,
+	}
+}
+
+func (s *RemoteKeySet) Key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	s.mu.Lock()
+	keys, fetchedAt, attemptedAt, lastErr := s.keys, s.fetchedAt, s.attemptedAt, s.lastErr
+	s.mu.Unlock()
+
+	if keys == nil && time.Since(attemptedAt) < s.minRefresh {
+		return nil, lastErr
+	}
+	if keys != nil {
+		key, err := keys.Key(ctx, kid)
+		if err == nil && time.Since(fetchedAt) < s.maxAge {
+			return key, nil
+		}
+		if time.Since(attemptedAt) < s.minRefresh {
+			return key, err
+		}
+	}
+
+	refreshErr := s.refresh(ctx)
+	s.mu.Lock()
+	keys = s.keys
+	s.mu.Unlock()
+	if keys == nil {
+		return nil, refreshErr
+	}
+	key, err := keys.Key(ctx, kid)
+	if err != nil && refreshErr != nil {
+		return nil, refreshErr
+	}
+	return key, err
+}
+
+// refresh fetches the key set once for all callers waiting on it. The fetch
+// is not tied to any one caller's context, so a caller giving up does not
+// fail the others.
+func (s *RemoteKeySet) refresh(ctx context.Context) error {
+	ch := s.group.DoChan("jwks", func() (interface{}, error) {
+		keys, err := s.fetch(context.WithoutCancel(ctx))
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.attemptedAt = time.Now()
+		s.lastErr = err
+		if err != nil {
+			return nil, err
+		}
+		s.keys = keys
+		s.fetchedAt = s.attemptedAt
+		return nil, nil
+	})
+	select {
+	case res := <-ch:
+		return res.Err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *RemoteKeySet) fetch(ctx context.Context) (StaticKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: status %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read JWKS: %w", err)
+	}
+	return ParseJWKS(data)
+}
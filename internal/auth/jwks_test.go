@@ -0,0 +1,278 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// jwksServer serves the JWKS document set with setKeys and counts fetches.
+type jwksServer struct {
+	*httptest.Server
+	fetches atomic.Int32
+
+	mu    sync.Mutex
+	doc   []byte
+	fail  bool
+	delay chan struct{} // when set, fetches block until it is closed
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	s := &jwksServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.fetches.Add(1)
+		s.mu.Lock()
+		doc, fail, delay := s.doc, s.fail, s.delay
+		s.mu.Unlock()
+		if delay != nil {
+			<-delay
+		}
+		if fail {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(doc)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys map[string]*rsa.PublicKey) {
+	var doc struct {
+		Keys []jwk `json:"keys"`
+	}
+	for kid, pub := range keys {
+		doc.Keys = append(doc.Keys, jwk{
+			Kty: "RSA",
+			Kid: kid,
+			Use: "sig",
+			N:   base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+			E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		})
+	}
+	data, _ := json.Marshal(doc)
+	s.mu.Lock()
+	s.doc = data
+	s.mu.Unlock()
+}
+
+func (s *jwksServer) setFailing(fail bool) {
+	s.mu.Lock()
+	s.fail = fail
+	s.mu.Unlock()
+}
+
+func TestRemoteKeySetPicksUpRotatedKeys(t *testing.T) {
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newJWKSServer(t)
+	srv.setKeys(map[string]*rsa.PublicKey{"old": &testRSAKey.PublicKey})
+	keys := NewRemoteKeySet(srv.URL)
+	keys.minRefresh = 0
+	ctx := context.Background()
+
+	if _, err := keys.Key(ctx, "old"); err != nil {
+		t.Fatalf("Key(old) error = %v", err)
+	}
+	if _, err := keys.Key(ctx, "old"); err != nil {
+		t.Fatalf("Key(old) again error = %v", err)
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Fatalf("fetches = %d, want 1 while the cache is fresh", n)
+	}
+
+	srv.setKeys(map[string]*rsa.PublicKey{"old": &testRSAKey.PublicKey, "new": &newKey.PublicKey})
+	got, err := keys.Key(ctx, "new")
+	if err != nil {
+		t.Fatalf("Key(new) after rotation error = %v", err)
+	}
+	if !got.(*rsa.PublicKey).Equal(&newKey.PublicKey) {
+		t.Error("Key(new) returned the wrong key")
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2 after an unknown kid", n)
+	}
+}
+
+func TestRemoteKeySetLimitsRefreshesForUnknownKids(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.setKeys(map[string]*rsa.PublicKey{"a": &testRSAKey.PublicKey})
+	keys := NewRemoteKeySet(srv.URL)
+	ctx := context.Background()
+
+	if _, err := keys.Key(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		if _, err := keys.Key(ctx, "missing"); !errors.Is(err, ErrUnknownKey) {
+			t.Fatalf("Key(missing) error = %v, want ErrUnknownKey", err)
+		}
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1 within minRefresh", n)
+	}
+}
+
+func TestRemoteKeySetServesStaleKeysWhenRefreshFails(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.setKeys(map[string]*rsa.PublicKey{"a": &testRSAKey.PublicKey})
+	keys := NewRemoteKeySet(srv.URL)
+	keys.minRefresh = 0
+	ctx := context.Background()
+
+	if _, err := keys.Key(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+	// Expire the cache and take the provider down
+	keys.mu.Lock()
+	keys.fetchedAt = time.Now().Add(-2 * keys.maxAge)
+	keys.mu.Unlock()
+	srv.setFailing(true)
+
+	if _, err := keys.Key(ctx, "a"); err != nil {
+		t.Errorf("Key(a) with a failing refresh error = %v, want the cached key", err)
+	}
+	if _, err := keys.Key(ctx, "missing"); err == nil {
+		t.Error("Key(missing) with a failing refresh succeeded")
+	}
+	if n := srv.fetches.Load(); n < 2 {
+		t.Errorf("fetches = %d, want a refresh attempt", n)
+	}
+}
+
+func TestRemoteKeySetFailsWithoutKeys(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.setFailing(true)
+	keys := NewRemoteKeySet(srv.URL)
+	if _, err := keys.Key(context.Background(), "a"); err == nil {
+		t.Error("Key() succeeded with no JWKS")
+	}
+}
+
+func TestRemoteKeySetLimitsRetriesBeforeFirstFetch(t *testing.T) {
+	srv := newJWKSServer(t)
+	srv.setKeys(map[string]*rsa.PublicKey{"a": &testRSAKey.PublicKey})
+	srv.setFailing(true)
+	keys := NewRemoteKeySet(srv.URL)
+	ctx := context.Background()
+
+	var first error
+	for i := 0; i < 5; i++ {
+		_, err := keys.Key(ctx, "a")
+		if err == nil {
+			t.Fatal("Key() succeeded with no JWKS")
+		}
+		if first == nil {
+			first = err
+		} else if err.Error() != first.Error() {
+			t.Errorf("throttled Key() error = %v, want the last fetch error %v", err, first)
+		}
+	}
+	if n := srv.fetches.Load(); n != 1 {
+		t.Errorf("fetches = %d, want 1 within minRefresh", n)
+	}
+
+	// Once minRefresh has passed the next call retries
+	srv.setFailing(false)
+	keys.mu.Lock()
+	keys.attemptedAt = time.Now().Add(-keys.minRefresh)
+	keys.mu.Unlock()
+	if _, err := keys.Key(ctx, "a"); err != nil {
+		t.Errorf("Key() after minRefresh error = %v", err)
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want 2", n)
+	}
+}
+
+func TestRemoteKeySetSharesOneFetch(t *testing.T) {
+	newKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := newJWKSServer(t)
+	srv.setKeys(map[string]*rsa.PublicKey{"a": &testRSAKey.PublicKey})
+	keys := NewRemoteKeySet(srv.URL)
+	keys.minRefresh = 0
+	ctx := context.Background()
+	if _, err := keys.Key(ctx, "a"); err != nil {
+		t.Fatal(err)
+	}
+
+	// Hold the next fetch open while several callers ask for the new key
+	release := make(chan struct{})
+	srv.mu.Lock()
+	srv.delay = release
+	srv.mu.Unlock()
+	srv.setKeys(map[string]*rsa.PublicKey{"a": &testRSAKey.PublicKey, "b": &newKey.PublicKey})
+
+	const callers = 8
+	var wg sync.WaitGroup
+	errs := make(chan error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := keys.Key(ctx, "b")
+			errs <- err
+		}()
+	}
+
+	// A cached kid must not wait for the fetch in flight
+	done := make(chan error, 1)
+	go func() {
+		_, err := keys.Key(ctx, "a")
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Errorf("Key(a) during a refresh error = %v", err)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Key(a) blocked on the refresh in flight")
+	}
+
+	// Let the other callers join the fetch in flight before it completes
+	for srv.fetches.Load() < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Errorf("Key(b) error = %v", err)
+		}
+	}
+	if n := srv.fetches.Load(); n != 2 {
+		t.Errorf("fetches = %d, want the concurrent refreshes to share one", n)
+	}
+}
+
+func TestRemoteKeySetCallerCanGiveUp(t *testing.T) {
+	srv := newJWKSServer(t)
+	release := make(chan struct{})
+	defer close(release)
+	srv.delay = release
+	keys := NewRemoteKeySet(srv.URL)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := keys.Key(ctx, "a"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Key() error = %v, want context.DeadlineExceeded", err)
+	}
+}
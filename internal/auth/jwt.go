@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"math/big"
+	"strings"
+)
+
+var (
+	ErrMalformedToken   = errors.New("malformed token")
+	ErrUnsupportedAlg   = errors.New("unsupported signing algorithm")
+	ErrInvalidSignature = errors.New("invalid token signature")
+)
+
+// Audience is the JWT "aud" claim, which may be a single string or an array.
+type Audience []string
+
+func (a *Audience) UnmarshalJSON(data []byte) error {
+	var single string
+	if err := json.Unmarshal(data, &single); err == nil {
+		*a = Audience{single}
+		return nil
+	}
+	var many []string
+	if err := json.Unmarshal(data, &many); err != nil {
+		return err
+	}
+	*a = many
+	return nil
+}
+
+func (a Audience) MarshalJSON() ([]byte, error) {
+	if len(a) == 1 {
+		return json.Marshal(a[0])
+	}
+	return json.Marshal([]string(a))
+}
+
+// Contains reports whether aud is one of the token's audiences.
+func (a Audience) Contains(aud string) bool {
+	for _, v := range a {
+		if v == aud {
+			return true
+		}
+	}
+	return false
+}
+
+// Claims holds the JWT claims the backend reads from a Civic id_token or
+// from one of its own access tokens.
+type Claims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  Audience `json:"aud"`
+	ExpiresAt int64    `json:"exp"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+
+	Email         string `json:"email,omitempty"`
+	EmailVerified bool   `json:"email_verified,omitempty"`
+	Name          string `json:"name,omitempty"`
+
+	// TokenUse is set on first-party tokens ("access") so an id_token can
+	// never be replayed as one of ours or vice versa.
+	TokenUse string `json:"token_use,omitempty"`
+	// WiFiID is the network a connect ticket is good for.
+	WiFiID string `json:"wifi_id,omitempty"`
+}
+
+type jwtHeader struct {
+	Alg string `json:"alg"`
+	Kid string `json:"kid,omitempty"`
+	Typ string `json:"typ,omitempty"`
+}
+
+// parsedJWT is a decoded but not yet verified compact JWS.
+type parsedJWT struct {
+	header       jwtHeader
+	payload      []byte
+	signingInput []byte
+	signature    []byte
+}
+
+func parseJWT(raw string) (*parsedJWT, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, ErrMalformedToken
+	}
+	headerJSON, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, ErrMalformedToken
+	}
+	var header jwtHeader
+	if err := json.Unmarshal(headerJSON, &header); err != nil {
+		return nil, ErrMalformedToken
+	}
+	return &parsedJWT{
+		header:       header,
+		payload:      payload,
+		signingInput: []byte(parts[0] + "." + parts[1]),
+		signature:    sig,
+	}, nil
+}
+
+func hashForAlg(alg string) (crypto.Hash, hash.Hash, error) {
+	switch alg {
+	case "RS256", "ES256":
+		return crypto.SHA256, sha256.New(), nil
+	case "RS384", "ES384":
+		return crypto.SHA384, sha512.New384(), nil
+	case "RS512", "ES512":
+		return crypto.SHA512, sha512.New(), nil
+	}
+	return 0, nil, ErrUnsupportedAlg
+}
+
+// verifySignature checks sig over input with key using the JWS algorithm alg.
+// The key type must match the algorithm family; "none" is never accepted.
+func verifySignature(alg string, key crypto.PublicKey, input, sig []byte) error {
+	hashID, h, err := hashForAlg(alg)
+	if err != nil {
+		return err
+	}
+	h.Write(input)
+	digest := h.Sum(nil)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if !strings.HasPrefix(alg, "RS") {
+			return ErrUnsupportedAlg
+		}
+		if err := rsa.VerifyPKCS1v15(k, hashID, digest, sig); err != nil {
+			return ErrInvalidSignature
+		}
+		return nil
+	case *ecdsa.PublicKey:
+		if !strings.HasPrefix(alg, "ES") {
+			return ErrUnsupportedAlg
+		}
+		size := (k.Curve.Params().BitSize + 7) / 8
+		if len(sig) != 2*size {
+			return ErrInvalidSignature
+		}
+		r := new(big.Int).SetBytes(sig[:size])
+		s := new(big.Int).SetBytes(sig[size:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return ErrInvalidSignature
+		}
+		return nil
+	}
+	return fmt.Errorf("%w: unsupported key type %T", ErrUnsupportedAlg, key)
+}
+
+// signJWT serializes claims into a compact RS256 JWS signed with key.
+func signJWT(kid string, key *rsa.PrivateKey, claims interface{}) (string, error) {
+	header, err := json.Marshal(jwtHeader{Alg: "RS256", Kid: kid, Typ: "JWT"})
+	if err != nil {
+		return "", err
+	}
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	sig, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		return "", err
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig), nil
+}
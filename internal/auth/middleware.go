@@ -1,20 +1,79 @@
 package auth
 
 import (
+	"context"
+	"errors"
 	"net/http"
+	"strings"
+
+	"wifi-go-backend/internal/logging"
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/store"
 
 	"github.com/julienschmidt/httprouter"
 )
 
 // Middleware for authentication
 
-// Simple preliminary auth middleware for testing
-func RequireAuth(next http.Handler) http.Handler {
+type ctxKey int
+
+const claimsKey ctxKey = iota
+
+// WithClaims returns a copy of ctx carrying the authenticated claims.
+func WithClaims(ctx context.Context, c *Claims) context.Context {
+	return context.WithValue(ctx, claimsKey, c)
+}
+
+// ClaimsFromContext returns the claims stored by the auth middleware.
+func ClaimsFromContext(ctx context.Context) (*Claims, bool) {
+	c, ok := ctx.Value(claimsKey).(*Claims)
+	return c, ok && c != nil
+}
+
+// SubjectFromContext returns the authenticated subject, or "" if none.
+func SubjectFromContext(ctx context.Context) string {
+	if c, ok := ClaimsFromContext(ctx); ok {
+		return c.Subject
+	}
+	return ""
+}
+
+// Authenticator checks bearer tokens against its verifiers, in order, and
+// stores the claims of the first one that accepts the token in the request
+// context.
+type Authenticator struct {
+	Verifiers []TokenVerifier
+	// Users resolves the caller's current verification level for RequireLevel.
+	Users store.UserStore
+}
+
+func NewAuthenticator(verifiers ...TokenVerifier) *Authenticator {
+	return &Authenticator{Verifiers: verifiers}
+}
+
+// authenticate returns r with claims attached, or false if the request does
+// not carry a valid bearer token.
+func (a *Authenticator) authenticate(r *http.Request) (*http.Request, bool) {
+	raw := bearerToken(r)
+	if raw == "" {
+		return r, false
+	}
+	for _, v := range a.Verifiers {
+		claims, err := v.Verify(r.Context(), raw)
+		if err == nil {
+			logging.SetUserID(r.Context(), claims.Subject)
+			return r.WithContext(WithClaims(r.Context(), claims)), true
+		}
+	}
+	return r, false
+}
+
+func (a *Authenticator) RequireAuth(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		token := r.Header.Get("Authorization")
-		if token != "Bearer testtoken" {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Unauthorized"))
+		r, ok := a.authenticate(r)
+		if !ok {
+			unauthorized(w, r)
 			return
 		}
 		next.ServeHTTP(w, r)
@@ -22,14 +81,93 @@ func RequireAuth(next http.Handler) http.Handler {
 }
 
 // For httprouter compatibility
-func RequireAuthRouter(next httprouter.Handle) httprouter.Handle {
+func (a *Authenticator) RequireAuthRouter(next httprouter.Handle) httprouter.Handle {
 	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
-		token := r.Header.Get("Authorization")
-		if token != "Bearer testtoken" {
-			w.WriteHeader(http.StatusUnauthorized)
-			w.Write([]byte("Unauthorized"))
+		r, ok := a.authenticate(r)
+		if !ok {
+			unauthorized(w, r)
 			return
 		}
 		next(w, r, ps)
 	}
 }
+
+// OptionalAuth serves requests without an Authorization header anonymously
+// and otherwise behaves like RequireAuthRouter, so a client whose token has
+// expired is told to refresh it rather than silently served as a stranger.
+func (a *Authenticator) OptionalAuth(next httprouter.Handle) httprouter.Handle {
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		if r.Header.Get("Authorization") == "" {
+			next(w, r, ps)
+			return
+		}
+		a.RequireAuthRouter(next)(w, r, ps)
+	}
+}
+
+// RequireLevel authenticates the request and additionally demands that the
+// caller's verification level is at least min. The level is read from the
+// user record on every request so an upgrade takes effect immediately.
+func (a *Authenticator) RequireLevel(min models.VerificationLevel, next httprouter.Handle) httprouter.Handle {
+	return a.RequireAuthRouter(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		level := models.LevelAnonymous
+		if min > models.LevelAnonymous {
+			user, ok := a.loadUser(w, r)
+			if !ok {
+				return
+			}
+			if user != nil {
+				level = user.VerificationLevel
+			}
+		}
+		if level < min {
+			problem.Write(w, r, problem.InsufficientLevel, "Verification level "+min.String()+" required")
+			return
+		}
+		next(w, r, ps)
+	})
+}
+
+// RequireRole authenticates the request and demands that the caller holds
+// role (admins hold every role).
+func (a *Authenticator) RequireRole(role string, next httprouter.Handle) httprouter.Handle {
+	return a.RequireAuthRouter(func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		user, ok := a.loadUser(w, r)
+		if !ok {
+			return
+		}
+		if user == nil || !user.HasRole(role) {
+			problem.Write(w, r, problem.Forbidden, "Forbidden")
+			return
+		}
+		next(w, r, ps)
+	})
+}
+
+// loadUser returns the authenticated caller's user record, or nil if they
+// have none yet. On a store error it writes the response and returns false.
+func (a *Authenticator) loadUser(w http.ResponseWriter, r *http.Request) (*models.User, bool) {
+	user, err := a.Users.FindByID(r.Context(), SubjectFromContext(r.Context()))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, true
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load user")
+		return nil, false
+	}
+	return user, true
+}
+
+func bearerToken(r *http.Request) string {
+	h := r.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(h) <= len(prefix) || !strings.EqualFold(h[:len(prefix)], prefix) {
+		return ""
+	}
+	return strings.TrimSpace(h[len(prefix):])
+}
+
+func unauthorized(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+	problem.Write(w, r, problem.Unauthorized, "A valid bearer token is required")
+}
@@ -15,6 +15,11 @@ func GenerateCodeVerifier() (string, error) {
 	return base64.RawURLEncoding.EncodeToString(b), nil
 }
 
+// GenerateState returns an unguessable value for the OAuth state parameter.
+func GenerateState() (string, error) {
+	return GenerateCodeVerifier()
+}
+
 func GenerateCodeChallenge(verifier string) string {
 	h := sha256.New()
 	h.Write([]byte(verifier))
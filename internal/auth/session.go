@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/store"
+)
+
+const tokenUseAccess = "access"
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrTokenRevoked        = errors.New("token revoked")
+)
+
+// TokenPair is what the client receives after login or refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// SessionManager mints first-party access tokens (short-lived JWTs) and
+// rotating opaque refresh tokens, decoupling clients from Civic's lifetimes.
+type SessionManager struct {
+	Signer     *Signer
+	Keys       KeySet // all keys accepted for verification, incl. rotated ones
+	Audience   string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	ClockSkew  time.Duration
+
+	RefreshTokens store.RefreshTokenStore
+	Revoked       store.RevocationStore
+}
+
+// Issue starts a new session (and refresh token family) for userID.
+func (m *SessionManager) Issue(ctx context.Context, userID string) (*TokenPair, error) {
+	family, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	return m.issue(ctx, userID, family)
+}
+
+// Refresh rotates refreshToken. Presenting an already-rotated token is
+// treated as theft and revokes every token in its family.
+func (m *SessionManager) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	t, err := m.RefreshTokens.MarkUsed(ctx, hashToken(refreshToken))
+	if errors.Is(err, store.ErrTokenReused) {
+		if err := m.RefreshTokens.RevokeFamily(ctx, t.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidRefreshToken
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if err != nil {
+		return nil, err
+	}
+	return m.issue(ctx, t.UserID, t.FamilyID)
+}
+
+// Logout revokes the presented access token and, if given, the refresh
+// token family it belongs to.
+func (m *SessionManager) Logout(ctx context.Context, access *Claims, refreshToken string) error {
+	if access != nil && access.ID != "" {
+		if err := m.Revoked.Revoke(ctx, access.ID, time.Unix(access.ExpiresAt, 0)); err != nil {
+			return err
+		}
+	}
+	if refreshToken == "" {
+		return nil
+	}
+	t, err := m.RefreshTokens.FindByID(ctx, hashToken(refreshToken))
+	if errors.Is(err, store.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if access != nil && t.UserID != access.Subject {
+		return ErrInvalidRefreshToken
+	}
+	return m.RefreshTokens.RevokeFamily(ctx, t.FamilyID)
+}
+
+// Verify implements TokenVerifier for first-party access tokens.
+func (m *SessionManager) Verify(ctx context.Context, raw string) (*Claims, error) {
+	v := Verifier{
+		Issuer:    m.Signer.Issuer,
+		Audience:  m.Audience,
+		Keys:      m.Keys,
+		ClockSkew: m.ClockSkew,
+		TokenUse:  tokenUseAccess,
+	}
+	claims, err := v.Verify(ctx, raw)
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := m.Revoked.IsRevoked(ctx, claims.ID)
+	if err != nil {
+		return nil, err
+	}
+	if revoked {
+		return nil, ErrTokenRevoked
+	}
+	return claims, nil
+}
+
+func (m *SessionManager) issue(ctx context.Context, userID, family string) (*TokenPair, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	access, err := m.Signer.Sign(userID, m.Audience, m.AccessTTL, Claims{ID: jti, TokenUse: tokenUseAccess})
+	if err != nil {
+		return nil, err
+	}
+
+	refresh, err := randomToken()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+	err = m.RefreshTokens.Create(ctx, &models.RefreshToken{
+		ID:        hashToken(refresh),
+		UserID:    userID,
+		FamilyID:  family,
+		CreatedAt: now,
+		ExpiresAt: now.Add(m.RefreshTTL),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  access,
+		RefreshToken: refresh,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(m.AccessTTL / time.Second),
+	}, nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Refresh tokens are stored hashed so a database leak cannot be replayed.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,188 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wifi-go-backend/internal/store"
+)
+
+func testSessionManager(t *testing.T) *SessionManager {
+	t.Helper()
+	signer, err := GenerateSigner("https://api.example.com", "k1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &SessionManager{
+		Signer:        signer,
+		Keys:          signer.KeySet(),
+		Audience:      "api",
+		AccessTTL:     time.Minute,
+		RefreshTTL:    time.Hour,
+		ClockSkew:     time.Minute,
+		RefreshTokens: store.NewMemoryRefreshTokenStore(),
+		Revoked:       store.NewMemoryRevocationStore(),
+	}
+}
+
+func TestSessionIssueAndVerify(t *testing.T) {
+	m := testSessionManager(t)
+	ctx := context.Background()
+	pair, err := m.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pair.TokenType != "Bearer" || pair.ExpiresIn != 60 || pair.RefreshToken == "" {
+		t.Errorf("pair = %+v", pair)
+	}
+	claims, err := m.Verify(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.Subject != "user-1" || claims.TokenUse != tokenUseAccess || claims.ID == "" {
+		t.Errorf("claims = %+v", claims)
+	}
+
+	// Other tokens from the same key, such as connect tickets or anything
+	// shaped like an id_token, are not access tokens
+	other, err := m.Signer.Sign("user-1", "api", time.Minute, Claims{ID: "x"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(ctx, other); err == nil {
+		t.Error("Verify accepted a token without token_use=access")
+	}
+}
+
+func TestSessionRefreshRotates(t *testing.T) {
+	m := testSessionManager(t)
+	ctx := context.Background()
+	first, err := m.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := m.Refresh(ctx, first.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.RefreshToken == first.RefreshToken || second.AccessToken == first.AccessToken {
+		t.Fatal("refresh returned the same tokens")
+	}
+	claims, err := m.Verify(ctx, second.AccessToken)
+	if err != nil || claims.Subject != "user-1" {
+		t.Fatalf("Verify(refreshed) = %+v, %v", claims, err)
+	}
+	third, err := m.Refresh(ctx, second.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Refresh(ctx, third.RefreshToken); err != nil {
+		t.Errorf("Refresh(latest) error = %v", err)
+	}
+
+	if _, err := m.Refresh(ctx, "never-issued"); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh(unknown) error = %v, want ErrInvalidRefreshToken", err)
+	}
+}
+
+func TestSessionRefreshReuseRevokesFamily(t *testing.T) {
+	m := testSessionManager(t)
+	ctx := context.Background()
+	stolen, err := m.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := m.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rotated, err := m.Refresh(ctx, stolen.RefreshToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Presenting the rotated token again means two parties hold the family
+	if _, err := m.Refresh(ctx, stolen.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Fatalf("reuse error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := m.Refresh(ctx, rotated.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh(rotated after reuse) error = %v, want the family revoked", err)
+	}
+	// A separate login is a separate family
+	if _, err := m.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("Refresh(other family) error = %v", err)
+	}
+}
+
+func TestSessionLogout(t *testing.T) {
+	m := testSessionManager(t)
+	ctx := context.Background()
+	pair, err := m.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := m.Verify(ctx, pair.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Someone else's refresh token is refused and left alone
+	intruder, err := m.Issue(ctx, "user-2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Logout(ctx, claims, intruder.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Logout(other user's refresh token) error = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := m.Refresh(ctx, intruder.RefreshToken); err != nil {
+		t.Errorf("other user's family revoked: %v", err)
+	}
+
+	if err := m.Logout(ctx, claims, pair.RefreshToken); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Verify(ctx, pair.AccessToken); !errors.Is(err, ErrTokenRevoked) {
+		t.Errorf("Verify after logout error = %v, want ErrTokenRevoked", err)
+	}
+	if _, err := m.Refresh(ctx, pair.RefreshToken); !errors.Is(err, ErrInvalidRefreshToken) {
+		t.Errorf("Refresh after logout error = %v, want ErrInvalidRefreshToken", err)
+	}
+	// Logging out twice, or with an unknown refresh token, is harmless
+	if err := m.Logout(ctx, claims, pair.RefreshToken); err != nil {
+		t.Errorf("second Logout error = %v", err)
+	}
+}
+
+func TestAuthenticatorRejectsRevokedAccessToken(t *testing.T) {
+	m := testSessionManager(t)
+	ctx := context.Background()
+	pair, err := m.Issue(ctx, "user-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := NewAuthenticator(m)
+	handler := a.RequireAuth(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(SubjectFromContext(r.Context())))
+	}))
+	call := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/me", nil)
+		req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+	if rec := call(); rec.Code != http.StatusOK || rec.Body.String() != "user-1" {
+		t.Fatalf("before logout: %d %s", rec.Code, rec.Body)
+	}
+	claims, _ := m.Verify(ctx, pair.AccessToken)
+	if err := m.Logout(ctx, claims, ""); err != nil {
+		t.Fatal(err)
+	}
+	if rec := call(); rec.Code != http.StatusUnauthorized {
+		t.Errorf("after logout: status = %d, want 401", rec.Code)
+	}
+}
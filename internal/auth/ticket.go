@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"context"
+	"time"
+)
+
+const tokenUseConnect = "connect"
+
+// TicketIssuer signs short-lived connect tickets: a JWT naming the user and
+// the network they proved to be near. Single use is enforced by the caller
+// recording the ticket's jti (see store.ConnectLogStore).
+type TicketIssuer struct {
+	Signer    *Signer
+	Keys      KeySet
+	Audience  string
+	TTL       time.Duration
+	ClockSkew time.Duration
+}
+
+// Issue returns the signed ticket and its claims.
+func (t *TicketIssuer) Issue(userID, wifiID string) (string, *Claims, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return "", nil, err
+	}
+	now := time.Now()
+	claims := &Claims{
+		Issuer:    t.Signer.Issuer,
+		Subject:   userID,
+		Audience:  Audience{t.Audience},
+		ID:        jti,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(t.TTL).Unix(),
+		TokenUse:  tokenUseConnect,
+		WiFiID:    wifiID,
+	}
+	ticket, err := signJWT(t.Signer.KeyID, t.Signer.key, claims)
+	if err != nil {
+		return "", nil, err
+	}
+	return ticket, claims, nil
+}
+
+func (t *TicketIssuer) Verify(ctx context.Context, raw string) (*Claims, error) {
+	v := Verifier{
+		Issuer:    t.Signer.Issuer,
+		Audience:  t.Audience,
+		Keys:      t.Keys,
+		ClockSkew: t.ClockSkew,
+		TokenUse:  tokenUseConnect,
+	}
+	return v.Verify(ctx, raw)
+}
@@ -0,0 +1,232 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const (
+	testIssuer   = "https://auth.example.com"
+	testAudience = "client-123"
+)
+
+var (
+	testRSAKey *rsa.PrivateKey
+	testECKey  *ecdsa.PrivateKey
+)
+
+func init() {
+	var err error
+	if testRSAKey, err = rsa.GenerateKey(rand.Reader, 2048); err != nil {
+		panic(err)
+	}
+	if testECKey, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader); err != nil {
+		panic(err)
+	}
+}
+
+var testNow = time.Date(2025, 6, 1, 12, 0, 0, 0, time.UTC)
+
+func validClaims() Claims {
+	return Claims{
+		Issuer:    testIssuer,
+		Subject:   "user-1",
+		Audience:  Audience{testAudience},
+		IssuedAt:  testNow.Add(-time.Minute).Unix(),
+		ExpiresAt: testNow.Add(time.Hour).Unix(),
+	}
+}
+
+func testVerifier(keys KeySet) *Verifier {
+	return &Verifier{
+		Issuer:    testIssuer,
+		Audience:  testAudience,
+		Keys:      keys,
+		ClockSkew: time.Minute,
+		Now:       func() time.Time { return testNow },
+	}
+}
+
+func signRS256(t *testing.T, kid string, claims Claims) string {
+	t.Helper()
+	tok, err := signJWT(kid, testRSAKey, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return tok
+}
+
+// signES256 builds a compact JWS with an ECDSA P-256 signature, which the
+// package itself never issues.
+func signES256(t *testing.T, kid string, claims Claims) string {
+	t.Helper()
+	header, _ := json.Marshal(jwtHeader{Alg: "ES256", Kid: kid, Typ: "JWT"})
+	payload, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(input))
+	r, s, err := ecdsa.Sign(rand.Reader, testECKey, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := make([]byte, 64)
+	r.FillBytes(sig[:32])
+	s.FillBytes(sig[32:])
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+// withHeader replaces the header of tok, keeping payload and signature.
+func withHeader(t *testing.T, tok string, h jwtHeader) string {
+	t.Helper()
+	data, _ := json.Marshal(h)
+	parts := strings.Split(tok, ".")
+	parts[0] = base64.RawURLEncoding.EncodeToString(data)
+	return strings.Join(parts, ".")
+}
+
+func TestVerifierAcceptsValidTokens(t *testing.T) {
+	keys := StaticKeySet{"rsa": &testRSAKey.PublicKey, "ec": &testECKey.PublicKey}
+	v := testVerifier(keys)
+	for name, tok := range map[string]string{
+		"RS256": signRS256(t, "rsa", validClaims()),
+		"ES256": signES256(t, "ec", validClaims()),
+	} {
+		claims, err := v.Verify(context.Background(), tok)
+		if err != nil {
+			t.Errorf("%s: Verify() error = %v", name, err)
+			continue
+		}
+		if claims.Subject != "user-1" {
+			t.Errorf("%s: subject = %q, want user-1", name, claims.Subject)
+		}
+	}
+}
+
+func TestVerifierRejectsBadSignatures(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	good := signRS256(t, "rsa", validClaims())
+	parts := strings.Split(good, ".")
+
+	forged := validClaims()
+	forged.Subject = "admin"
+	payload, _ := json.Marshal(forged)
+	swapped := parts[0] + "." + base64.RawURLEncoding.EncodeToString(payload) + "." + parts[2]
+
+	sig, _ := base64.RawURLEncoding.DecodeString(parts[2])
+	sig[0] ^= 0xff
+	flipped := parts[0] + "." + parts[1] + "." + base64.RawURLEncoding.EncodeToString(sig)
+
+	otherSigned, err := signJWT("rsa", otherKey, validClaims())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := testVerifier(StaticKeySet{"rsa": &testRSAKey.PublicKey})
+	for name, tok := range map[string]string{
+		"payload swapped":   swapped,
+		"signature flipped": flipped,
+		"other key":         otherSigned,
+	} {
+		if _, err := v.Verify(context.Background(), tok); !errors.Is(err, ErrInvalidSignature) {
+			t.Errorf("%s: Verify() error = %v, want ErrInvalidSignature", name, err)
+		}
+	}
+}
+
+func TestVerifierRejectsAlgorithmMismatch(t *testing.T) {
+	keys := StaticKeySet{"rsa": &testRSAKey.PublicKey, "ec": &testECKey.PublicKey}
+	v := testVerifier(keys)
+	rsaTok := signRS256(t, "rsa", validClaims())
+	ecTok := signES256(t, "ec", validClaims())
+
+	tests := map[string]string{
+		// An RSA signature presented as ECDSA, and the other way round
+		"ES256 header on RSA key": withHeader(t, rsaTok, jwtHeader{Alg: "ES256", Kid: "rsa"}),
+		"RS256 header on EC key":  withHeader(t, ecTok, jwtHeader{Alg: "RS256", Kid: "ec"}),
+		"none":                    withHeader(t, rsaTok, jwtHeader{Alg: "none", Kid: "rsa"}),
+		"HS256":                   withHeader(t, rsaTok, jwtHeader{Alg: "HS256", Kid: "rsa"}),
+	}
+	for name, tok := range tests {
+		if _, err := v.Verify(context.Background(), tok); !errors.Is(err, ErrUnsupportedAlg) {
+			t.Errorf("%s: Verify() error = %v, want ErrUnsupportedAlg", name, err)
+		}
+	}
+}
+
+func TestVerifierClockSkew(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Claims)
+		want   error
+	}{
+		{"expired within skew", func(c *Claims) { c.ExpiresAt = testNow.Add(-30 * time.Second).Unix() }, nil},
+		{"expired beyond skew", func(c *Claims) { c.ExpiresAt = testNow.Add(-2 * time.Minute).Unix() }, ErrTokenExpired},
+		{"no exp", func(c *Claims) { c.ExpiresAt = 0 }, ErrTokenExpired},
+		{"nbf within skew", func(c *Claims) { c.NotBefore = testNow.Add(30 * time.Second).Unix() }, nil},
+		{"nbf beyond skew", func(c *Claims) { c.NotBefore = testNow.Add(2 * time.Minute).Unix() }, ErrTokenNotYet},
+		{"iat in the future", func(c *Claims) { c.IssuedAt = testNow.Add(2 * time.Minute).Unix() }, ErrTokenNotYet},
+	}
+	v := testVerifier(StaticKeySet{"rsa": &testRSAKey.PublicKey})
+	for _, tt := range tests {
+		claims := validClaims()
+		tt.modify(&claims)
+		_, err := v.Verify(context.Background(), signRS256(t, "rsa", claims))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifierRejectsWrongIssuerAndAudience(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Claims)
+		want   error
+	}{
+		{"wrong issuer", func(c *Claims) { c.Issuer = "https://evil.example.com" }, ErrInvalidIssuer},
+		{"wrong audience", func(c *Claims) { c.Audience = Audience{"someone-else"} }, ErrInvalidAud},
+		{"audience list with ours", func(c *Claims) { c.Audience = Audience{"other", testAudience} }, nil},
+		{"no subject", func(c *Claims) { c.Subject = "" }, ErrMissingSubject},
+		{"token use", func(c *Claims) { c.TokenUse = "access" }, ErrWrongTokenUse},
+	}
+	v := testVerifier(StaticKeySet{"rsa": &testRSAKey.PublicKey})
+	for _, tt := range tests {
+		claims := validClaims()
+		tt.modify(&claims)
+		_, err := v.Verify(context.Background(), signRS256(t, "rsa", claims))
+		if !errors.Is(err, tt.want) {
+			t.Errorf("%s: Verify() error = %v, want %v", tt.name, err, tt.want)
+		}
+	}
+}
+
+func TestVerifierRejectsUnknownKid(t *testing.T) {
+	keys := StaticKeySet{"a": &testRSAKey.PublicKey, "b": &testECKey.PublicKey}
+	v := testVerifier(keys)
+	for name, kid := range map[string]string{"unknown kid": "c", "no kid with several keys": ""} {
+		if _, err := v.Verify(context.Background(), signRS256(t, kid, validClaims())); !errors.Is(err, ErrUnknownKey) {
+			t.Errorf("%s: Verify() error = %v, want ErrUnknownKey", name, err)
+		}
+	}
+}
+
+func TestVerifierRejectsMalformedTokens(t *testing.T) {
+	v := testVerifier(StaticKeySet{"rsa": &testRSAKey.PublicKey})
+	for _, tok := range []string{"", "abc", "a.b", "a.b.c.d", "!!.e30.sig"} {
+		if _, err := v.Verify(context.Background(), tok); !errors.Is(err, ErrMalformedToken) {
+			t.Errorf("Verify(%q) error = %v, want ErrMalformedToken", tok, err)
+		}
+	}
+}
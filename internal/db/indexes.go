@@ -0,0 +1,116 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrDuplicateNetworks is returned by EnsureIndexes when existing networks
+// share an SSID and address, so the unique index cannot be built. Every
+// other index is still created.
+var ErrDuplicateNetworks = errors.New("networks with the same ssid and location.address block the unique index")
+
+// EnsureIndexes creates the indexes the application relies on. CreateOne is
+// a no-op when an identical index already exists, so this is safe on every boot.
+func EnsureIndexes(ctx context.Context) error {
+	// Expiring documents are removed once expires_at has passed.
+	for _, name := range []string{"auth_sessions", "refresh_tokens", "revoked_tokens", "stats_idempotency", "rate_limits"} {
+		coll, err := GetCollection(name)
+		if err != nil {
+			return err
+		}
+		_, err = coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "expires_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	refresh, err := GetRefreshTokenCollection()
+	if err != nil {
+		return err
+	}
+	_, err = refresh.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "family_id", Value: 1}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Per-user connect history, read newest first by the location risk
+	// checks; ticket redemption looks entries up by _id. Kept for 90 days.
+	connects, err := GetConnectLogCollection()
+	if err != nil {
+		return err
+	}
+	_, err = connects.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "user_id", Value: 1}, {Key: "issued_at", Value: -1}}},
+		{
+			Keys:    bson.D{{Key: "issued_at", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(90 * 24 * 60 * 60),
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Audit queries filter by actor, action or network and page by _id.
+	audit, err := GetAuditCollection()
+	if err != nil {
+		return err
+	}
+	_, err = audit.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "actor", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "action", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "wifi_id", Value: 1}, {Key: "_id", Value: -1}}},
+		{Keys: bson.D{{Key: "timestamp", Value: -1}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	// Geospatial queries need a 2dsphere index on the GeoJSON location, and
+	// the unique ssid+address index is what rejects duplicate submissions.
+	wifi, err := GetWiFiCollection()
+	if err != nil {
+		return err
+	}
+	_, err = wifi.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "location", Value: "2dsphere"}},
+	})
+	if err != nil {
+		return err
+	}
+	var dupErr error
+	_, err = wifi.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "ssid", Value: 1}, {Key: "location.address", Value: 1}},
+		Options: options.Index().SetUnique(true).SetName("ssid_address_unique"),
+	})
+	if mongo.IsDuplicateKeyError(err) {
+		// Migration 3 merges them; carry on with the remaining indexes
+		dupErr = fmt.Errorf("%w (run `migrate up`): %v", ErrDuplicateNetworks, err)
+	} else if err != nil {
+		return err
+	}
+
+	// One bookmark per user and network; also serves listing by user.
+	saved, err := GetSavedWiFiCollection()
+	if err != nil {
+		return err
+	}
+	_, err = saved.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "wifi_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		return err
+	}
+	return dupErr
+}
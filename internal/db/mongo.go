@@ -2,33 +2,182 @@ package db
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
+	"strconv"
 	"sync"
 
+	"wifi-go-backend/config"
+	"wifi-go-backend/internal/metrics"
+
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
+	"go.mongodb.org/mongo-driver/mongo/readpref"
+	"go.mongodb.org/mongo-driver/mongo/writeconcern"
 )
 
+var ErrNotConnected = errors.New("mongodb client not connected")
+
 var (
+	mu             sync.RWMutex
 	clientInstance *mongo.Client
-	clientOnce     sync.Once
+	databaseName   string
 )
 
+// Connect builds the shared client from cfg and pings the deployment, so a
+// bad URI or unreachable server fails at startup rather than on first use.
+func Connect(ctx context.Context, cfg *config.Config) error {
+	if cfg.MongoURI == "" {
+		return errors.New("MONGO_URI is required")
+	}
+	opts, err := clientOptions(cfg)
+	if err != nil {
+		return err
+	}
+	client, err := mongo.Connect(ctx, opts)
+	if err != nil {
+		return err
+	}
+	pingCtx, cancel := context.WithTimeout(ctx, cfg.MongoConnectTimeout)
+	defer cancel()
+	if err := client.Ping(pingCtx, readpref.Primary()); err != nil {
+		client.Disconnect(context.Background())
+		return fmt.Errorf("ping mongodb: %w", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	clientInstance = client
+	databaseName = cfg.MongoDatabase
+	return nil
+}
+
+// Disconnect closes the shared client, waiting for in-use connections
+// until ctx is done.
+func Disconnect(ctx context.Context) error {
+	mu.Lock()
+	client := clientInstance
+	clientInstance = nil
+	mu.Unlock()
+	if client == nil {
+		return nil
+	}
+	return client.Disconnect(ctx)
+}
+
+func clientOptions(cfg *config.Config) (*options.ClientOptions, error) {
+	opts := options.Client().
+		ApplyURI(cfg.MongoURI).
+		SetConnectTimeout(cfg.MongoConnectTimeout).
+		SetServerSelectionTimeout(cfg.MongoConnectTimeout).
+		SetMaxPoolSize(uint64(cfg.MongoMaxPoolSize)).
+		SetMinPoolSize(uint64(cfg.MongoMinPoolSize)).
+		SetMaxConnIdleTime(cfg.MongoMaxConnIdleTime).
+		SetMonitor(metrics.MongoMonitor())
+	if cfg.MongoOperationTimeout > 0 {
+		opts.SetTimeout(cfg.MongoOperationTimeout)
+	}
+
+	mode, err := readpref.ModeFromString(cfg.MongoReadPreference)
+	if err != nil {
+		return nil, fmt.Errorf("MONGO_READ_PREFERENCE: %w", err)
+	}
+	rp, err := readpref.New(mode)
+	if err != nil {
+		return nil, fmt.Errorf("MONGO_READ_PREFERENCE: %w", err)
+	}
+	opts.SetReadPreference(rp)
+
+	switch cfg.MongoWriteConcern {
+	case "majority":
+		opts.SetWriteConcern(writeconcern.Majority())
+	default:
+		n, err := strconv.Atoi(cfg.MongoWriteConcern)
+		if err != nil || n < 0 {
+			return nil, fmt.Errorf("MONGO_WRITE_CONCERN: want \"majority\" or a node count, got %q", cfg.MongoWriteConcern)
+		}
+		opts.SetWriteConcern(&writeconcern.WriteConcern{W: n})
+	}
+	return opts, opts.Validate()
+}
+
+// GetMongoClient returns the client created by Connect.
 func GetMongoClient() (*mongo.Client, error) {
-	var err error
-	clientOnce.Do(func() {
-		uri := os.Getenv("MONGO_URI")
-		fmt.Println("Connecting to MongoDB at:", uri)
-		clientInstance, err = mongo.Connect(context.Background(), options.Client().ApplyURI(uri))
-	})
-	return clientInstance, err
+	mu.RLock()
+	defer mu.RUnlock()
+	if clientInstance == nil {
+		return nil, ErrNotConnected
+	}
+	return clientInstance, nil
 }
 
-func GetWiFiCollection() (*mongo.Collection, error) {
+func GetDatabase() (*mongo.Database, error) {
 	client, err := GetMongoClient()
 	if err != nil {
 		return nil, err
 	}
-	return client.Database("wifi_db").Collection("wifi"), nil
+	mu.RLock()
+	defer mu.RUnlock()
+	return client.Database(databaseName), nil
+}
+
+func GetCollection(name string) (*mongo.Collection, error) {
+	database, err := GetDatabase()
+	if err != nil {
+		return nil, err
+	}
+	return database.Collection(name), nil
+}
+
+func GetWiFiCollection() (*mongo.Collection, error) {
+	return GetCollection("wifi")
+}
+
+func GetAuthSessionCollection() (*mongo.Collection, error) {
+	return GetCollection("auth_sessions")
+}
+
+func GetUserCollection() (*mongo.Collection, error) {
+	return GetCollection("users")
+}
+
+func GetRefreshTokenCollection() (*mongo.Collection, error) {
+	return GetCollection("refresh_tokens")
+}
+
+func GetRevokedTokenCollection() (*mongo.Collection, error) {
+	return GetCollection("revoked_tokens")
+}
+
+func GetSavedWiFiCollection() (*mongo.Collection, error) {
+	return GetCollection("saved_wifi")
+}
+
+func GetStatsCollection() (*mongo.Collection, error) {
+	return GetCollection("user_stats")
+}
+
+func GetStatsIdempotencyCollection() (*mongo.Collection, error) {
+	return GetCollection("stats_idempotency")
+}
+
+func GetConnectLogCollection() (*mongo.Collection, error) {
+	return GetCollection("connect_issuances")
+}
+
+func GetAuditCollection() (*mongo.Collection, error) {
+	return GetCollection("audit_events")
+}
+
+func GetRateLimitCollection() (*mongo.Collection, error) {
+	return GetCollection("rate_limits")
+}
+
+// Ping checks that the primary is reachable.
+func Ping(ctx context.Context) error {
+	client, err := GetMongoClient()
+	if err != nil {
+		return err
+	}
+	return client.Ping(ctx, readpref.Primary())
 }
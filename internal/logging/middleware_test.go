@@ -0,0 +1,112 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// serveLogged runs handler behind Middleware and returns the response and
+// the access log line, if one was written at the logger's level.
+func serveLogged(t *testing.T, level string, req *http.Request, handler http.HandlerFunc) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	var buf bytes.Buffer
+	logger, err := New(&buf, "json", level)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rec := httptest.NewRecorder()
+	Middleware(logger, handler).ServeHTTP(rec, req)
+	if buf.Len() == 0 {
+		return rec, nil
+	}
+	var line map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &line); err != nil {
+		t.Fatalf("%v: %s", err, buf.Bytes())
+	}
+	return rec, line
+}
+
+func TestMiddlewareRequestID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"generated", "", false},
+		{"from client", "abc-123_x.y", true},
+		{"malformed", "bad id\n", false},
+		{"too long", string(bytes.Repeat([]byte("a"), 129)), false},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/wifi/nearby", nil)
+		if tt.header != "" {
+			req.Header.Set(RequestIDHeader, tt.header)
+		}
+		var seen string
+		rec, line := serveLogged(t, "info", req, func(w http.ResponseWriter, r *http.Request) {
+			seen = RequestID(r.Context())
+		})
+		id := rec.Header().Get(RequestIDHeader)
+		if tt.keep && id != tt.header || !tt.keep && (id == tt.header || len(id) != 32) {
+			t.Errorf("%s: %s = %q", tt.name, RequestIDHeader, id)
+		}
+		if seen != id || line["request_id"] != id {
+			t.Errorf("%s: handler saw %q and log has %v, want %q", tt.name, seen, line["request_id"], id)
+		}
+	}
+}
+
+func TestMiddlewareAccessLog(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/api/wifi/saved/65f0c0ffee0000000000abcd", nil)
+	req.RemoteAddr = "192.0.2.1:4711"
+	rec, line := serveLogged(t, "info", req, func(w http.ResponseWriter, r *http.Request) {
+		SetRoute(r.Context(), "/api/wifi/saved/:id")
+		SetUserID(r.Context(), "u1")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("hello"))
+	})
+	if rec.Code != http.StatusTeapot {
+		t.Fatalf("status = %d", rec.Code)
+	}
+	want := map[string]interface{}{
+		"msg":         "request",
+		"method":      http.MethodPost,
+		"status":      float64(http.StatusTeapot),
+		"bytes":       float64(5),
+		"route":       "/api/wifi/saved/:id",
+		"user_id":     "u1",
+		"remote_addr": "192.0.2.1:4711",
+		"client_ip":   "192.0.2.1",
+	}
+	for k, v := range want {
+		if line[k] != v {
+			t.Errorf("%s = %v, want %v", k, line[k], v)
+		}
+	}
+	if _, ok := line["path"]; ok {
+		t.Errorf("path = %v logged alongside the route", line["path"])
+	}
+	if _, ok := line["latency_ms"]; !ok {
+		t.Error("latency_ms missing")
+	}
+
+	// Unmatched requests log the raw path instead
+	_, line = serveLogged(t, "info", httptest.NewRequest(http.MethodGet, "/nope", nil), http.NotFound)
+	if line["path"] != "/nope" || line["status"] != float64(http.StatusNotFound) {
+		t.Errorf("unmatched request logged as %v", line)
+	}
+}
+
+func TestMiddlewareLogsProbesAtDebug(t *testing.T) {
+	for _, path := range []string{"/healthz", "/readyz", "/metrics"} {
+		if _, line := serveLogged(t, "info", httptest.NewRequest(http.MethodGet, path, nil), func(http.ResponseWriter, *http.Request) {}); line != nil {
+			t.Errorf("%s logged at info: %v", path, line)
+		}
+		if _, line := serveLogged(t, "debug", httptest.NewRequest(http.MethodGet, path, nil), func(http.ResponseWriter, *http.Request) {}); line == nil {
+			t.Errorf("%s not logged at debug", path)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package metrics holds the Prometheus collectors for the service and the
+// /metrics handler that exposes them.
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "wifi"
+
+// Registry holds every collector below plus the Go runtime and process
+// collectors. It is separate from the global registry so that libraries
+// cannot add metrics behind our back.
+var Registry = prometheus.NewRegistry()
+
+var (
+	HTTPRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	HTTPDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route pattern and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	MongoCommandDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "mongo_command_duration_seconds",
+		Help:      "MongoDB command latency by command, collection and outcome.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"command", "collection", "status"})
+
+	GeminiRequests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gemini_requests_total",
+		Help:      "Gemini generate calls by operation and outcome.",
+	}, []string{"operation", "status"})
+
+	GeminiDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "gemini_request_duration_seconds",
+		Help:      "Gemini generate call latency by operation.",
+		Buckets:   []float64{.25, .5, 1, 2, 4, 8, 16, 32},
+	}, []string{"operation"})
+
+	GeminiTokens = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gemini_tokens_total",
+		Help:      "Gemini tokens used by operation and kind (prompt or candidates).",
+	}, []string{"operation", "kind"})
+
+	GeminiParseFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "gemini_parse_failures_total",
+		Help:      "Gemini responses that could not be turned into stops, by operation and reason.",
+	}, []string{"operation", "reason"})
+
+	RateLimitRejections = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_rejections_total",
+		Help:      "Requests answered with 429, by rate limit policy.",
+	}, []string{"policy"})
+
+	RateLimitStoreErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_store_errors_total",
+		Help:      "Requests let through unchecked because the rate limit store failed, by policy.",
+	}, []string{"policy"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		HTTPRequests,
+		HTTPDuration,
+		MongoCommandDuration,
+		GeminiRequests,
+		GeminiDuration,
+		GeminiTokens,
+		GeminiParseFailures,
+		RateLimitRejections,
+		RateLimitStoreErrors,
+	)
+}
+
+// Handler serves Registry in the Prometheus exposition format.
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
@@ -0,0 +1,37 @@
+package metrics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/event"
+)
+
+// MongoMonitor returns a command monitor that records the duration of every
+// command the client sends.
+func MongoMonitor() *event.CommandMonitor {
+	// The collection is only on the started event; remember it by request ID
+	var collections sync.Map
+	observe := func(requestID int64, command, status string, d time.Duration) {
+		collection := ""
+		if v, ok := collections.LoadAndDelete(requestID); ok {
+			collection = v.(string)
+		}
+		MongoCommandDuration.WithLabelValues(command, collection, status).Observe(d.Seconds())
+	}
+	return &event.CommandMonitor{
+		Started: func(_ context.Context, e *event.CommandStartedEvent) {
+			// For CRUD commands the command's value is the collection name
+			if coll, ok := e.Command.Lookup(e.CommandName).StringValueOK(); ok {
+				collections.Store(e.RequestID, coll)
+			}
+		},
+		Succeeded: func(_ context.Context, e *event.CommandSucceededEvent) {
+			observe(e.RequestID, e.CommandName, "ok", e.Duration)
+		},
+		Failed: func(_ context.Context, e *event.CommandFailedEvent) {
+			observe(e.RequestID, e.CommandName, "error", e.Duration)
+		},
+	}
+}
@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Records written by older clients used Go field names as keys ("SSID",
+// "Location.Coordinates", ...). Rename them to the lowercase keys the model
+// reads and default the GeoJSON type so the 2dsphere index accepts them.
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "wifi_field_names",
+		Up:      upWiFiFieldNames,
+		// The old names were never read by the server, so there is nothing
+		// to restore.
+		Down: nil,
+	})
+}
+
+func upWiFiFieldNames(ctx context.Context, db *mongo.Database) error {
+	coll := db.Collection("wifi")
+	// $rename cannot target a field inside one being renamed, so rename the
+	// parent first and the nested keys second
+	renames := []map[string]string{
+		{"SSID": "ssid", "Password": "password", "Location": "location", "Description": "description"},
+		{"location.Type": "location.type", "location.Coordinates": "location.coordinates", "location.Address": "location.address"},
+	}
+	for _, pass := range renames {
+		for from, to := range pass {
+			filter := bson.M{from: bson.M{"$exists": true}, to: bson.M{"$exists": false}}
+			if _, err := coll.UpdateMany(ctx, filter, bson.M{"$rename": bson.M{from: to}}); err != nil {
+				return err
+			}
+		}
+	}
+	_, err := coll.UpdateMany(ctx,
+		bson.M{"location.coordinates": bson.M{"$exists": true}, "location.type": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"location.type": "Point"}},
+	)
+	return err
+}
@@ -0,0 +1,42 @@
+package migrations
+
+import (
+	"context"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Networks added before edits were possible have no revision or timestamps.
+// Start them at revision 1 and take both timestamps from the ObjectID, which
+// records when the network was inserted.
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "wifi_revisions",
+		Up:      upWiFiRevisions,
+		Down:    downWiFiRevisions,
+	})
+}
+
+func upWiFiRevisions(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("wifi").UpdateMany(ctx,
+		bson.M{"revision": bson.M{"$exists": false}},
+		mongo.Pipeline{{{Key: "$set", Value: bson.M{
+			"revision":   1,
+			"created_at": bson.M{"$ifNull": bson.A{"$created_at", bson.M{"$toDate": "$_id"}}},
+			"updated_at": bson.M{"$ifNull": bson.A{"$updated_at", bson.M{"$toDate": "$_id"}}},
+		}}}},
+	)
+	return err
+}
+
+// downWiFiRevisions only clears networks nobody has edited yet, so later
+// changes are not lost.
+func downWiFiRevisions(ctx context.Context, db *mongo.Database) error {
+	_, err := db.Collection("wifi").UpdateMany(ctx,
+		bson.M{"revision": 1, "created_by": bson.M{"$exists": false}},
+		bson.M{"$unset": bson.M{"revision": "", "created_at": "", "updated_at": ""}},
+	)
+	return err
+}
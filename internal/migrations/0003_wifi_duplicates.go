@@ -0,0 +1,106 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// The unique ssid+location.address index cannot be built while two networks
+// share both. The index treats a missing address like null but apart from an
+// empty one, and so does the grouping here. Merge each such group into its oldest network: bookmarks move to it and the others
+// are moved to wifi_duplicates, which records what was merged into what.
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "wifi_duplicates",
+		Up:      upWiFiDuplicates,
+		// Restoring the duplicates would block the index again; they stay
+		// in wifi_duplicates for inspection.
+		Down: nil,
+	})
+}
+
+// duplicateGroup is one ssid+address pair held by more than one network,
+// oldest first.
+type duplicateGroup struct {
+	IDs []primitive.ObjectID `bson:"ids"`
+}
+
+func upWiFiDuplicates(ctx context.Context, db *mongo.Database) error {
+	wifi := db.Collection("wifi")
+	cur, err := wifi.Aggregate(ctx, mongo.Pipeline{
+		{{Key: "$sort", Value: bson.M{"_id": 1}}},
+		{{Key: "$group", Value: bson.M{
+			"_id": bson.M{"ssid": "$ssid", "address": bson.M{"$ifNull": bson.A{"$location.address", nil}}},
+			"ids": bson.M{"$push": "$_id"},
+		}}},
+		{{Key: "$match", Value: bson.M{"ids.1": bson.M{"$exists": true}}}},
+	}, options.Aggregate().SetAllowDiskUse(true))
+	if err != nil {
+		return err
+	}
+	var groups []duplicateGroup
+	if err := cur.All(ctx, &groups); err != nil {
+		return err
+	}
+	for _, g := range groups {
+		if err := mergeDuplicates(ctx, db, g.IDs[0], g.IDs[1:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// mergeDuplicates folds dups into keep. Each step is safe to repeat if the
+// migration is interrupted.
+func mergeDuplicates(ctx context.Context, db *mongo.Database, keep primitive.ObjectID, dups []primitive.ObjectID) error {
+	saved := db.Collection("saved_wifi")
+	cur, err := saved.Find(ctx, bson.M{"wifi_id": bson.M{"$in": dups}})
+	if err != nil {
+		return err
+	}
+	var bookmarks []bson.M
+	if err := cur.All(ctx, &bookmarks); err != nil {
+		return err
+	}
+	for _, b := range bookmarks {
+		// A user who saved both keeps one bookmark
+		_, err := saved.UpdateOne(ctx, bson.M{"_id": b["_id"]}, bson.M{"$set": bson.M{"wifi_id": keep}})
+		if mongo.IsDuplicateKeyError(err) {
+			_, err = saved.DeleteOne(ctx, bson.M{"_id": b["_id"]})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	wifi := db.Collection("wifi")
+	archive := db.Collection("wifi_duplicates")
+	now := time.Now().UTC()
+	for _, id := range dups {
+		var doc bson.M
+		err := wifi.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+		if errors.Is(err, mongo.ErrNoDocuments) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		doc["merged_into"] = keep
+		doc["merged_at"] = now
+		_, err = archive.ReplaceOne(ctx, bson.M{"_id": id}, doc, options.Replace().SetUpsert(true))
+		if err != nil {
+			return err
+		}
+		if _, err := wifi.DeleteOne(ctx, bson.M{"_id": id}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+package migrations
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// testDatabase returns a throwaway database on MONGO_TEST_URI, skipping the
+// test when it is not set.
+func testDatabase(t *testing.T) *mongo.Database {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		t.Fatal(err)
+	}
+	db := client.Database("migrations_test_" + primitive.NewObjectID().Hex())
+	t.Cleanup(func() {
+		db.Drop(context.Background())
+		client.Disconnect(context.Background())
+	})
+	return db
+}
+
+func TestWiFiDuplicatesMerge(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+	wifi, saved := db.Collection("wifi"), db.Collection("saved_wifi")
+
+	ids := make([]primitive.ObjectID, 6)
+	for i := range ids {
+		ids[i] = primitive.NewObjectID()
+	}
+	docs := []interface{}{
+		bson.M{"_id": ids[0], "ssid": "cafe", "location": bson.M{"address": "1 Main St"}},
+		bson.M{"_id": ids[1], "ssid": "cafe", "location": bson.M{"address": "1 Main St"}},
+		bson.M{"_id": ids[2], "ssid": "cafe", "location": bson.M{"address": "2 Main St"}},
+		// No address and a null one collide in the index, an empty one does not
+		bson.M{"_id": ids[3], "ssid": "home", "location": bson.M{}},
+		bson.M{"_id": ids[4], "ssid": "home", "location": bson.M{"address": nil}},
+		bson.M{"_id": ids[5], "ssid": "home", "location": bson.M{"address": ""}},
+	}
+	if _, err := wifi.InsertMany(ctx, docs); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saved.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "user_id", Value: 1}, {Key: "wifi_id", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := saved.InsertMany(ctx, []interface{}{
+		bson.M{"user_id": "both", "wifi_id": ids[0]},
+		bson.M{"user_id": "both", "wifi_id": ids[1]},
+		bson.M{"user_id": "dup", "wifi_id": ids[1]},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// A second run must find nothing left to do
+	for i := 0; i < 2; i++ {
+		if err := upWiFiDuplicates(ctx, db); err != nil {
+			t.Fatalf("run %d: %v", i+1, err)
+		}
+	}
+
+	for id, want := range map[primitive.ObjectID]int64{ids[0]: 1, ids[1]: 0, ids[2]: 1, ids[3]: 1, ids[4]: 0, ids[5]: 1} {
+		if n, _ := wifi.CountDocuments(ctx, bson.M{"_id": id}); n != want {
+			t.Errorf("wifi %s: count = %d, want %d", id.Hex(), n, want)
+		}
+	}
+	var archived bson.M
+	if err := db.Collection("wifi_duplicates").FindOne(ctx, bson.M{"_id": ids[1]}).Decode(&archived); err != nil {
+		t.Fatalf("duplicate not archived: %v", err)
+	}
+	if archived["merged_into"] != ids[0] {
+		t.Errorf("merged_into = %v, want %s", archived["merged_into"], ids[0].Hex())
+	}
+	for user, want := range map[string]int64{"both": 1, "dup": 1} {
+		if n, _ := saved.CountDocuments(ctx, bson.M{"user_id": user, "wifi_id": ids[0]}); n != want {
+			t.Errorf("%s: bookmarks on the kept network = %d, want %d", user, n, want)
+		}
+	}
+
+	if _, err := wifi.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "ssid", Value: 1}, {Key: "location.address", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	}); err != nil {
+		t.Errorf("unique index after the migration: %v", err)
+	}
+}
@@ -0,0 +1,199 @@
+// Package migrations evolves existing documents in wifi_db. Migrations are
+// numbered Go functions applied in order; applied versions are recorded in
+// the schema_migrations collection.
+package migrations
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const collectionName = "schema_migrations"
+
+// ErrIrreversible is returned by Down functions that cannot undo their Up.
+var ErrIrreversible = errors.New("migration cannot be reverted")
+
+// Migration is one schema change. Up and Down must be safe to re-run on a
+// partially migrated database.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(ctx context.Context, db *mongo.Database) error
+	Down    func(ctx context.Context, db *mongo.Database) error
+}
+
+// Applied is a schema_migrations record.
+type Applied struct {
+	Version   int       `bson:"_id"`
+	Name      string    `bson:"name"`
+	AppliedAt time.Time `bson:"applied_at"`
+}
+
+var registry []Migration
+
+// register adds m to the set returned by All; called from each migration's
+// init so numbering lives next to the code.
+func register(m Migration) {
+	registry = append(registry, m)
+}
+
+// All returns the registered migrations ordered by version.
+func All() []Migration {
+	out := append([]Migration(nil), registry...)
+	sort.Slice(out, func(i, j int) bool { return out[i].Version < out[j].Version })
+	return out
+}
+
+// Migrator applies Migrations to DB. In DryRun mode it only reports what
+// would run.
+type Migrator struct {
+	DB         *mongo.Database
+	Migrations []Migration
+	DryRun     bool
+	// Logf reports progress; defaults to discarding.
+	Logf func(format string, args ...interface{})
+}
+
+func NewMigrator(db *mongo.Database) *Migrator {
+	return &Migrator{DB: db, Migrations: All()}
+}
+
+func (m *Migrator) logf(format string, args ...interface{}) {
+	if m.Logf != nil {
+		m.Logf(format, args...)
+	}
+}
+
+// Applied returns the recorded migrations ordered by version.
+func (m *Migrator) Applied(ctx context.Context) ([]Applied, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cur, err := m.DB.Collection(collectionName).Find(ctx, bson.M{}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var out []Applied
+	if err := cur.All(ctx, &out); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// Pending returns the migrations not yet applied, in order.
+func (m *Migrator) Pending(ctx context.Context) ([]Migration, error) {
+	applied, err := m.appliedSet(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var out []Migration
+	for _, mig := range m.Migrations {
+		if !applied[mig.Version] {
+			out = append(out, mig)
+		}
+	}
+	return out, nil
+}
+
+// Up applies pending migrations up to and including target; a target of 0
+// applies all of them. It stops at the first failure.
+func (m *Migrator) Up(ctx context.Context, target int) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	pending, err := m.Pending(ctx)
+	if err != nil {
+		return err
+	}
+	for _, mig := range pending {
+		if target > 0 && mig.Version > target {
+			break
+		}
+		if m.DryRun {
+			m.logf("would apply %04d %s", mig.Version, mig.Name)
+			continue
+		}
+		m.logf("applying %04d %s", mig.Version, mig.Name)
+		if err := mig.Up(ctx, m.DB); err != nil {
+			return fmt.Errorf("migration %04d %s: %w", mig.Version, mig.Name, err)
+		}
+		_, err := m.DB.Collection(collectionName).InsertOne(ctx, Applied{
+			Version:   mig.Version,
+			Name:      mig.Name,
+			AppliedAt: time.Now().UTC(),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Down reverts the steps most recently applied migrations, newest first.
+func (m *Migrator) Down(ctx context.Context, steps int) error {
+	if err := m.validate(); err != nil {
+		return err
+	}
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return err
+	}
+	byVersion := map[int]Migration{}
+	for _, mig := range m.Migrations {
+		byVersion[mig.Version] = mig
+	}
+	for i := len(applied) - 1; i >= 0 && steps > 0; i, steps = i-1, steps-1 {
+		rec := applied[i]
+		mig, ok := byVersion[rec.Version]
+		if !ok {
+			return fmt.Errorf("migration %04d %s is applied but unknown to this build", rec.Version, rec.Name)
+		}
+		if m.DryRun {
+			m.logf("would revert %04d %s", mig.Version, mig.Name)
+			continue
+		}
+		m.logf("reverting %04d %s", mig.Version, mig.Name)
+		if mig.Down == nil {
+			return fmt.Errorf("migration %04d %s: %w", mig.Version, mig.Name, ErrIrreversible)
+		}
+		if err := mig.Down(ctx, m.DB); err != nil {
+			return fmt.Errorf("migration %04d %s: %w", mig.Version, mig.Name, err)
+		}
+		if _, err := m.DB.Collection(collectionName).DeleteOne(ctx, bson.M{"_id": rec.Version}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) appliedSet(ctx context.Context) (map[int]bool, error) {
+	applied, err := m.Applied(ctx)
+	if err != nil {
+		return nil, err
+	}
+	set := map[int]bool{}
+	for _, a := range applied {
+		set[a.Version] = true
+	}
+	return set, nil
+}
+
+// validate rejects registries with missing fields or duplicate versions.
+func (m *Migrator) validate() error {
+	seen := map[int]bool{}
+	for _, mig := range m.Migrations {
+		if mig.Version <= 0 || mig.Up == nil {
+			return fmt.Errorf("migration %q needs a positive version and an Up function", mig.Name)
+		}
+		if seen[mig.Version] {
+			return fmt.Errorf("duplicate migration version %04d", mig.Version)
+		}
+		seen[mig.Version] = true
+	}
+	return nil
+}
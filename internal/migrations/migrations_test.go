@@ -0,0 +1,177 @@
+package migrations
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func TestAllOrdered(t *testing.T) {
+	all := All()
+	for i, mig := range all {
+		if mig.Version != i+1 {
+			t.Errorf("migration %d has version %04d, want versions numbered from 1 without gaps", i, mig.Version)
+		}
+	}
+	if err := (&Migrator{Migrations: all}).validate(); err != nil {
+		t.Errorf("registry: %v", err)
+	}
+}
+
+func TestMigratorValidate(t *testing.T) {
+	up := func(context.Context, *mongo.Database) error { return nil }
+	tests := map[string][]Migration{
+		"no version": {{Name: "a", Up: up}},
+		"no up":      {{Version: 1, Name: "a"}},
+		"duplicate":  {{Version: 1, Name: "a", Up: up}, {Version: 1, Name: "b", Up: up}},
+	}
+	for name, migs := range tests {
+		m := &Migrator{Migrations: migs}
+		if err := m.validate(); err == nil {
+			t.Errorf("%s: validate() succeeded", name)
+		}
+		if err := m.Up(context.Background(), 0); err == nil {
+			t.Errorf("%s: Up() succeeded", name)
+		}
+	}
+}
+
+func TestMigratorUpIsIdempotent(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+	wifi := db.Collection("wifi")
+	legacy, current := primitive.NewObjectID(), primitive.NewObjectID()
+	edited := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	if _, err := wifi.InsertMany(ctx, []interface{}{
+		bson.M{"_id": legacy, "SSID": "cafe", "Password": "pw", "Location": bson.M{
+			"Coordinates": bson.A{13.4, 52.5},
+			"Address":     "1 Main St",
+		}},
+		bson.M{"_id": current, "ssid": "home", "revision": 4, "created_at": edited, "updated_at": edited,
+			"location": bson.M{"type": "Point", "coordinates": bson.A{13.5, 52.6}, "address": "2 Main St"}},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	var logged []string
+	m := NewMigrator(db)
+	m.Logf = func(format string, args ...interface{}) { logged = append(logged, format) }
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	first, err := m.Applied(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var versions []int
+	for _, a := range first {
+		versions = append(versions, a.Version)
+	}
+	if want := []int{1, 2, 3}; !reflect.DeepEqual(versions, want) {
+		t.Fatalf("applied versions = %v, want %v", versions, want)
+	}
+
+	// 0001 renamed the Go field names and defaulted the GeoJSON type
+	var doc bson.M
+	if err := wifi.FindOne(ctx, bson.M{"_id": legacy}).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	loc, _ := doc["location"].(bson.M)
+	if doc["ssid"] != "cafe" || doc["password"] != "pw" || loc["type"] != "Point" || loc["address"] != "1 Main St" || loc["coordinates"] == nil {
+		t.Errorf("renamed document = %v", doc)
+	}
+	for _, old := range []string{"SSID", "Password", "Location"} {
+		if _, ok := doc[old]; ok {
+			t.Errorf("%s still present after the rename", old)
+		}
+	}
+	// 0002 started it at revision 1, dated by its ObjectID
+	created, _ := doc["created_at"].(primitive.DateTime)
+	if doc["revision"] != int32(1) || !created.Time().Equal(legacy.Timestamp()) {
+		t.Errorf("revision = %v, created_at = %v, want 1 and %v", doc["revision"], created.Time(), legacy.Timestamp())
+	}
+	// and left networks that already had a revision alone
+	if err := wifi.FindOne(ctx, bson.M{"_id": current}).Decode(&doc); err != nil {
+		t.Fatal(err)
+	}
+	if updated, _ := doc["updated_at"].(primitive.DateTime); doc["revision"] != int32(4) || !updated.Time().Equal(edited) {
+		t.Errorf("edited network = %v", doc)
+	}
+
+	// A second run applies nothing and keeps the records as they were
+	logged = nil
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if len(logged) != 0 {
+		t.Errorf("second run logged %q", logged)
+	}
+	second, err := m.Applied(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Errorf("applied after the second run = %v, want %v", second, first)
+	}
+	if pending, err := m.Pending(ctx); err != nil || len(pending) != 0 {
+		t.Errorf("Pending() = %v, %v", pending, err)
+	}
+
+	// Re-running an Up by hand on migrated data changes nothing either
+	before, _ := wifi.CountDocuments(ctx, bson.M{"revision": 1})
+	for _, mig := range All() {
+		if err := mig.Up(ctx, db); err != nil {
+			t.Fatalf("%04d %s again: %v", mig.Version, mig.Name, err)
+		}
+	}
+	if after, _ := wifi.CountDocuments(ctx, bson.M{"revision": 1}); after != before {
+		t.Errorf("networks at revision 1 = %d after re-running, want %d", after, before)
+	}
+}
+
+func TestMigratorUpTargetAndDown(t *testing.T) {
+	db := testDatabase(t)
+	ctx := context.Background()
+	var ran []string
+	step := func(name string) func(context.Context, *mongo.Database) error {
+		return func(context.Context, *mongo.Database) error {
+			ran = append(ran, name)
+			return nil
+		}
+	}
+	m := &Migrator{DB: db, Migrations: []Migration{
+		{Version: 1, Name: "one", Up: step("up 1"), Down: step("down 1")},
+		{Version: 2, Name: "two", Up: step("up 2")},
+		{Version: 3, Name: "three", Up: step("up 3"), Down: step("down 3")},
+	}}
+
+	if err := m.Up(ctx, 2); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Up(ctx, 0); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"up 1", "up 2", "up 3"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+
+	// Down goes newest first and stops at a migration without a Down
+	ran = nil
+	err := m.Down(ctx, 3)
+	if !errors.Is(err, ErrIrreversible) || !strings.Contains(err.Error(), "0002") {
+		t.Errorf("Down(3) error = %v, want 0002 irreversible", err)
+	}
+	if want := []string{"down 3"}; !reflect.DeepEqual(ran, want) {
+		t.Errorf("ran %q, want %q", ran, want)
+	}
+	if pending, _ := m.Pending(ctx); len(pending) != 1 || pending[0].Version != 3 {
+		t.Errorf("Pending() after Down = %v, want 0003 only", pending)
+	}
+}
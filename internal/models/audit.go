@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Audit actions
+const (
+	AuditWiFiCreate     = "wifi.create"
+	AuditWiFiUpdate     = "wifi.update"
+	AuditWiFiDelete     = "wifi.delete"
+	AuditPasswordReveal = "wifi.password_reveal"
+	AuditTicketIssue    = "wifi.ticket_issue"
+	AuditTicketRedeem   = "wifi.ticket_redeem"
+	AuditConnectDenied  = "wifi.connect_denied"
+	AuditUserPromote    = "user.promote"
+)
+
+// AuditEvent is an append-only record of who touched which network.
+// Coordinates are what the client supplied, [longitude, latitude].
+type AuditEvent struct {
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	Actor       string             `bson:"actor" json:"actor"`
+	Action      string             `bson:"action" json:"action"`
+	WiFiID      primitive.ObjectID `bson:"wifi_id,omitempty" json:"wifi_id,omitempty"`
+	Coordinates []float64          `bson:"coordinates,omitempty" json:"coordinates,omitempty"`
+	IP          string             `bson:"ip,omitempty" json:"ip,omitempty"`
+	UserAgent   string             `bson:"user_agent,omitempty" json:"user_agent,omitempty"`
+	Risk        *RiskAssessment    `bson:"risk,omitempty" json:"risk,omitempty"`
+	Timestamp   time.Time          `bson:"timestamp" json:"timestamp"`
+}
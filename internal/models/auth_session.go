@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// AuthSession is the server-side half of an in-flight Civic OAuth flow,
+// keyed by the state parameter handed to Civic. For a verification upgrade
+// UserID is the caller who started it and TargetLevel what they asked for.
+type AuthSession struct {
+	State        string            `bson:"_id" json:"state"`
+	CodeVerifier string            `bson:"code_verifier" json:"-"`
+	RedirectTo   string            `bson:"redirect_to,omitempty" json:"redirect_to,omitempty"`
+	UserID       string            `bson:"user_id,omitempty" json:"user_id,omitempty"`
+	TargetLevel  VerificationLevel `bson:"target_level,omitempty" json:"target_level,omitempty"`
+	CreatedAt    time.Time         `bson:"created_at" json:"created_at"`
+	ExpiresAt    time.Time         `bson:"expires_at" json:"expires_at"`
+}
@@ -0,0 +1,66 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Connect modes: reveal the password directly, or hand out a ticket that is
+// redeemed once for a provisioning payload. Attempts refused on location
+// risk are logged as ConnectModeDenied so they count towards later
+// assessments; nothing was revealed for them.
+const (
+	ConnectModePassword = "password"
+	ConnectModeTicket   = "ticket"
+	ConnectModeDenied   = "denied"
+)
+
+// ConnectIssuance logs every password reveal or ticket issued to a user, so
+// scraping can be spotted and throttled. For tickets ID is the ticket's jti.
+// Coordinates are the position the client reported, [longitude, latitude],
+// and AccuracyM its claimed accuracy in meters (0 if not sent).
+type ConnectIssuance struct {
+	ID          string             `bson:"_id"`
+	UserID      string             `bson:"user_id"`
+	WiFiID      primitive.ObjectID `bson:"wifi_id"`
+	Mode        string             `bson:"mode"`
+	IP          string             `bson:"ip,omitempty"`
+	Coordinates []float64          `bson:"coordinates,omitempty"`
+	AccuracyM   float64            `bson:"accuracy_m,omitempty"`
+	ScanMatched bool               `bson:"scan_matched,omitempty"` // the client's scan list matched the network
+	Risk        *RiskAssessment    `bson:"risk,omitempty"`
+	IssuedAt    time.Time          `bson:"issued_at"`
+	ExpiresAt   time.Time          `bson:"expires_at,omitempty"`
+	RedeemedAt  *time.Time         `bson:"redeemed_at,omitempty"`
+}
+
+// Location risk reasons
+const (
+	RiskImpossibleTravel = "impossible_travel"
+	RiskAccuracyMissing  = "accuracy_missing"
+	RiskLowAccuracy      = "low_accuracy"
+	RiskExactLocation    = "exact_network_location"
+	RiskRepeatedExact    = "repeated_exact_location"
+	RiskManyNetworks     = "many_networks"
+)
+
+// RiskAssessment scores how likely a reported position is spoofed, from 0
+// (plausible) to 100.
+type RiskAssessment struct {
+	Score   int      `bson:"score" json:"score"`
+	Reasons []string `bson:"reasons,omitempty" json:"reasons,omitempty"`
+}
+
+// Has reports whether reason contributed to the score.
+func (a *RiskAssessment) Has(reason string) bool {
+	if a == nil {
+		return false
+	}
+	for _, r := range a.Reasons {
+		if r == reason {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,15 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// SavedWiFi is a user's bookmark of a network. Only the ID is stored so the
+// bookmark always reflects the current state of the network.
+type SavedWiFi struct {
+	UserID  string             `bson:"user_id" json:"-"`
+	WiFiID  primitive.ObjectID `bson:"wifi_id" json:"wifi_id"`
+	SavedAt time.Time          `bson:"saved_at" json:"saved_at"`
+}
@@ -0,0 +1,30 @@
+package models
+
+import "time"
+
+// UserStats are per-user counters maintained by the scan and connect
+// handlers and by client-reported events via PATCH /api/stats.
+type UserStats struct {
+	UserID              string `bson:"_id" json:"-"`
+	NetworksContributed int64  `bson:"networks_contributed" json:"networks_contributed"`
+	SuccessfulConnects  int64  `bson:"successful_connects" json:"successful_connects"`
+	BytesTransferred    int64  `bson:"bytes_transferred" json:"bytes_transferred"`
+	SessionSeconds      int64  `bson:"session_seconds" json:"session_seconds"`
+	// CityCells are coarse ~10 km grid cells standing in for cities, since
+	// addresses are free text and there is no geocoder.
+	CityCells     []string  `bson:"city_cells" json:"-"`
+	CurrentStreak int64     `bson:"current_streak" json:"current_streak"`
+	LongestStreak int64     `bson:"longest_streak" json:"longest_streak"`
+	LastActiveDay string    `bson:"last_active_day" json:"last_active_day,omitempty"` // YYYY-MM-DD, UTC
+	UpdatedAt     time.Time `bson:"updated_at" json:"updated_at"`
+}
+
+// DataSavedBytes estimates mobile data saved: everything transferred over
+// shared WiFi would otherwise have gone over the user's data plan.
+func (s UserStats) DataSavedBytes() int64 {
+	return s.BytesTransferred
+}
+
+func (s UserStats) CitiesCovered() int {
+	return len(s.CityCells)
+}
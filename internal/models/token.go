@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// RefreshToken is a hashed, single-use refresh token. Tokens issued from the
+// same login share a FamilyID so a replayed token can revoke the whole chain.
+type RefreshToken struct {
+	ID        string     `bson:"_id"` // sha256 of the opaque token
+	UserID    string     `bson:"user_id"`
+	FamilyID  string     `bson:"family_id"`
+	CreatedAt time.Time  `bson:"created_at"`
+	ExpiresAt time.Time  `bson:"expires_at"`
+	UsedAt    *time.Time `bson:"used_at,omitempty"`
+}
+
+// RevokedToken marks an access token (by jti) as unusable until it expires.
+type RevokedToken struct {
+	ID        string    `bson:"_id"`
+	ExpiresAt time.Time `bson:"expires_at"`
+}
@@ -1,7 +1,80 @@
 package models
 
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+// User is provisioned on first Civic login, keyed by the OIDC subject.
 type User struct {
-	ID    string `bson:"_id,omitempty"`
-	Email string `bson:"email"`
-	Name  string `bson:"name"`
+	ID                string            `bson:"_id,omitempty" json:"id"`
+	Email             string            `bson:"email" json:"email"`
+	Name              string            `bson:"name" json:"name"`
+	VerificationLevel VerificationLevel `bson:"verification_level" json:"verification_level"`
+	Role              string            `bson:"role,omitempty" json:"role,omitempty"`
+	CreatedAt         time.Time         `bson:"created_at" json:"created_at"`
+	LastLoginAt       time.Time         `bson:"last_login_at" json:"last_login_at"`
+}
+
+// Roles are granted by editing the user document; regular users have none.
+const (
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+// HasRole reports whether the user holds role. Admins hold every role.
+func (u *User) HasRole(role string) bool {
+	return u.Role == role || u.Role == RoleAdmin
+}
+
+// VerificationLevel is an ordered trust tier. It is stored as an int so
+// Mongo can compare levels ($max, $gte) and serialized as a name in JSON.
+type VerificationLevel int
+
+const (
+	LevelAnonymous VerificationLevel = iota
+	LevelEmailVerified
+	LevelCivicIDVerified
+	LevelTrustedContributor
+)
+
+var levelNames = map[VerificationLevel]string{
+	LevelAnonymous:          "anonymous",
+	LevelEmailVerified:      "email_verified",
+	LevelCivicIDVerified:    "civic_id_verified",
+	LevelTrustedContributor: "trusted_contributor",
+}
+
+func ParseVerificationLevel(s string) (VerificationLevel, error) {
+	for level, name := range levelNames {
+		if name == s {
+			return level, nil
+		}
+	}
+	return LevelAnonymous, fmt.Errorf("unknown verification level %q", s)
+}
+
+func (l VerificationLevel) String() string {
+	if name, ok := levelNames[l]; ok {
+		return name
+	}
+	return fmt.Sprintf("level(%d)", int(l))
+}
+
+func (l VerificationLevel) MarshalJSON() ([]byte, error) {
+	return json.Marshal(l.String())
+}
+
+func (l *VerificationLevel) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	parsed, err := ParseVerificationLevel(s)
+	if err != nil {
+		return err
+	}
+	*l = parsed
+	return nil
 }
@@ -1,6 +1,10 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
 
 type Location struct {
 	Type        string    `bson:"type" json:"type"`
@@ -9,11 +13,108 @@ type Location struct {
 }
 
 type WiFi struct {
-	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	SSID        string             `json:"ssid"`
-	Password    string             `json:"password"`
-	Location    Location           `json:"location"`
-	Description string             `json:"description"`
+	ID   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	SSID string             `json:"ssid"`
+	// Password is only accepted from clients; at rest it lives encrypted in
+	// PasswordEnc. A plaintext value in the database predates encryption.
+	Password    string          `bson:"password,omitempty" json:"password"`
+	PasswordEnc *EncryptedValue `bson:"password_enc,omitempty" json:"-"`
+	Location    Location        `json:"location"`
+	Description string          `json:"description"`
+	// BSSIDs are the network's own access points and NeighborBSSIDs the
+	// others visible where it was scanned, as lower-case aa:bb:cc:dd:ee:ff.
+	// The neighbours fingerprint the place, so responses never include them.
+	BSSIDs         []string `bson:"bssids,omitempty" json:"bssids,omitempty"`
+	NeighborBSSIDs []string `bson:"neighbor_bssids,omitempty" json:"neighbor_bssids,omitempty"`
+
+	// What the contributor's device saw when the network was scanned
+	Security      string `bson:"security,omitempty" json:"security,omitempty"` // one of the Security* values
+	Band          string `bson:"band,omitempty" json:"band,omitempty"`         // one of the Band* values
+	Channel       int    `bson:"channel,omitempty" json:"channel,omitempty"`
+	RSSI          int    `bson:"rssi,omitempty" json:"rssi,omitempty"` // dBm
+	CaptivePortal bool   `bson:"captive_portal,omitempty" json:"captive_portal"`
+	Hidden        bool   `bson:"hidden,omitempty" json:"hidden"`
+
+	// CreatedBy is the contributor's user ID; networks added before it was
+	// recorded have none and only moderators may change them. Revision
+	// starts at 1 and increases with every update.
+	CreatedBy string    `bson:"created_by,omitempty" json:"created_by,omitempty"`
+	CreatedAt time.Time `bson:"created_at,omitempty" json:"created_at"`
+	UpdatedAt time.Time `bson:"updated_at,omitempty" json:"updated_at"`
+	Revision  int64     `bson:"revision" json:"revision"`
+}
+
+// HasFingerprint reports whether w has neighbouring BSSIDs to check a scan
+// list against. Its own BSSIDs are public, so they cannot prove presence
+// on their own.
+func (w *WiFi) HasFingerprint() bool {
+	return len(w.NeighborBSSIDs) > 0
+}
+
+// Security types
+const (
+	SecurityOpen       = "open"
+	SecurityWEP        = "wep"
+	SecurityWPA        = "wpa"
+	SecurityWPA2       = "wpa2"
+	SecurityWPA3       = "wpa3"
+	SecurityWPA2WPA3   = "wpa2_wpa3" // transition mode
+	SecurityEnterprise = "enterprise"
+)
+
+// Frequency bands
+const (
+	Band24GHz = "2.4ghz"
+	Band5GHz  = "5ghz"
+	Band6GHz  = "6ghz"
+)
+
+var securityTypes = map[string]bool{
+	SecurityOpen: true, SecurityWEP: true, SecurityWPA: true, SecurityWPA2: true,
+	SecurityWPA3: true, SecurityWPA2WPA3: true, SecurityEnterprise: true,
+}
+
+// channelRanges are the channel numbers used in each band.
+var channelRanges = map[string][2]int{
+	Band24GHz: {1, 14},
+	Band5GHz:  {32, 177},
+	Band6GHz:  {1, 233},
+}
+
+func ValidSecurity(s string) bool {
+	return securityTypes[s]
+}
+
+func ValidBand(b string) bool {
+	_, ok := channelRanges[b]
+	return ok
+}
+
+// ValidChannel reports whether channel exists in band.
+func ValidChannel(band string, channel int) bool {
+	r, ok := channelRanges[band]
+	return ok && channel >= r[0] && channel <= r[1]
+}
+
+// QRSecurity maps Security onto the T: field of a WIFI: QR payload.
+func (w *WiFi) QRSecurity() string {
+	switch w.Security {
+	case SecurityOpen:
+		return "nopass"
+	case SecurityWEP:
+		return "WEP"
+	}
+	return "WPA"
+}
+
+// EncryptedValue is an envelope-encrypted secret: Ciphertext is sealed with
+// a per-record data key, which is itself wrapped by the key KeyID names.
+type EncryptedValue struct {
+	Alg        string `bson:"alg"`
+	KeyID      string `bson:"key_id"`
+	WrappedKey []byte `bson:"wrapped_key"`
+	Nonce      []byte `bson:"nonce"`
+	Ciphertext []byte `bson:"ciphertext"`
 }
 
 type GeoJSON struct {
@@ -0,0 +1,151 @@
+// Package problem writes error responses as RFC 7807 problem details
+// (application/problem+json) with a machine-readable code, so clients can
+// branch on the code instead of matching messages.
+package problem
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"wifi-go-backend/internal/logging"
+)
+
+const ContentType = "application/problem+json"
+
+// Code identifies an error condition. Each code maps to one HTTP status.
+type Code string
+
+const (
+	// Request errors
+	InvalidBody        Code = "INVALID_BODY"
+	ValidationFailed   Code = "VALIDATION_FAILED"
+	InvalidWiFiID      Code = "INVALID_WIFI_ID"
+	RedirectNotAllowed Code = "REDIRECT_NOT_ALLOWED"
+	NotFound           Code = "NOT_FOUND"
+	MethodNotAllowed   Code = "METHOD_NOT_ALLOWED"
+
+	// Conditional requests (If-Match)
+	PreconditionFailed   Code = "PRECONDITION_FAILED"
+	PreconditionRequired Code = "PRECONDITION_REQUIRED"
+
+	// Authentication and authorization
+	Unauthorized        Code = "UNAUTHORIZED"
+	Forbidden           Code = "FORBIDDEN"
+	InsufficientLevel   Code = "INSUFFICIENT_LEVEL"
+	LoginFailed         Code = "LOGIN_FAILED"
+	InvalidLoginState   Code = "INVALID_LOGIN_STATE"
+	InvalidIDToken      Code = "INVALID_ID_TOKEN"
+	AccountMismatch     Code = "ACCOUNT_MISMATCH"
+	AlreadyVerified     Code = "ALREADY_VERIFIED"
+	InvalidRefreshToken Code = "INVALID_REFRESH_TOKEN"
+	UpstreamAuthFailed  Code = "UPSTREAM_AUTH_FAILED"
+	UserNotFound        Code = "USER_NOT_FOUND"
+
+	// WiFi networks
+	WiFiNotFound     Code = "WIFI_NOT_FOUND"
+	DuplicateNetwork Code = "DUPLICATE_NETWORK"
+	TooFar           Code = "TOO_FAR"
+	LocationDoubtful Code = "LOCATION_DOUBTFUL"
+	ScanMismatch     Code = "SCAN_MISMATCH"
+	NotSaved         Code = "NOT_SAVED"
+	TicketInvalid    Code = "TICKET_INVALID"
+	TicketUsed       Code = "TICKET_USED"
+	RateLimited      Code = "RATE_LIMITED"
+
+	// Recommendations
+	AIUnavailable Code = "AI_UNAVAILABLE"
+	AIFailed      Code = "AI_FAILED"
+
+	Internal Code = "INTERNAL"
+)
+
+var statuses = map[Code]int{
+	InvalidBody:        http.StatusBadRequest,
+	ValidationFailed:   http.StatusBadRequest,
+	InvalidWiFiID:      http.StatusBadRequest,
+	RedirectNotAllowed: http.StatusBadRequest,
+	NotFound:           http.StatusNotFound,
+	MethodNotAllowed:   http.StatusMethodNotAllowed,
+
+	PreconditionFailed:   http.StatusPreconditionFailed,
+	PreconditionRequired: http.StatusPreconditionRequired,
+
+	Unauthorized:        http.StatusUnauthorized,
+	Forbidden:           http.StatusForbidden,
+	InsufficientLevel:   http.StatusForbidden,
+	LoginFailed:         http.StatusBadRequest,
+	InvalidLoginState:   http.StatusBadRequest,
+	InvalidIDToken:      http.StatusUnauthorized,
+	AccountMismatch:     http.StatusForbidden,
+	AlreadyVerified:     http.StatusConflict,
+	InvalidRefreshToken: http.StatusUnauthorized,
+	UpstreamAuthFailed:  http.StatusBadGateway,
+	UserNotFound:        http.StatusNotFound,
+
+	WiFiNotFound:     http.StatusNotFound,
+	DuplicateNetwork: http.StatusConflict,
+	TooFar:           http.StatusForbidden,
+	LocationDoubtful: http.StatusForbidden,
+	ScanMismatch:     http.StatusForbidden,
+	NotSaved:         http.StatusNotFound,
+	TicketInvalid:    http.StatusUnauthorized,
+	TicketUsed:       http.StatusGone,
+	RateLimited:      http.StatusTooManyRequests,
+
+	AIUnavailable: http.StatusServiceUnavailable,
+	AIFailed:      http.StatusBadGateway,
+
+	Internal: http.StatusInternalServerError,
+}
+
+// Status returns the HTTP status for code; unknown codes are server errors.
+func (c Code) Status() int {
+	if s, ok := statuses[c]; ok {
+		return s
+	}
+	return http.StatusInternalServerError
+}
+
+// Problem is an RFC 7807 problem details object. Code, RequestID and
+// Errors are extension members.
+type Problem struct {
+	Type      string       `json:"type"`
+	Title     string       `json:"title"`
+	Status    int          `json:"status"`
+	Detail    string       `json:"detail,omitempty"`
+	Code      Code         `json:"code"`
+	RequestID string       `json:"request_id,omitempty"`
+	Errors    []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError points at one invalid request field.
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Write sends a problem response for code with a human-readable detail.
+func Write(w http.ResponseWriter, r *http.Request, code Code, detail string) {
+	write(w, r, &Problem{Code: code, Detail: detail})
+}
+
+// Validation sends a VALIDATION_FAILED problem listing the invalid fields.
+func Validation(w http.ResponseWriter, r *http.Request, errs ...FieldError) {
+	write(w, r, &Problem{
+		Code:   ValidationFailed,
+		Detail: "The request has invalid fields",
+		Errors: errs,
+	})
+}
+
+func write(w http.ResponseWriter, r *http.Request, p *Problem) {
+	p.Status = p.Code.Status()
+	// The code carries the meaning, so the type is the RFC's default
+	p.Type = "about:blank"
+	p.Title = http.StatusText(p.Status)
+	p.RequestID = logging.RequestID(r.Context())
+	w.Header().Set("Content-Type", ContentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
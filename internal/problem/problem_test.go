@@ -0,0 +1,124 @@
+package problem
+
+import (
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"wifi-go-backend/internal/logging"
+)
+
+func TestCodeStatus(t *testing.T) {
+	// Clients branch on these, and the README lists them
+	want := map[Code]int{
+		InvalidBody:          400,
+		ValidationFailed:     400,
+		InvalidWiFiID:        400,
+		RedirectNotAllowed:   400,
+		NotFound:             404,
+		MethodNotAllowed:     405,
+		PreconditionFailed:   412,
+		PreconditionRequired: 428,
+		Unauthorized:         401,
+		Forbidden:            403,
+		InsufficientLevel:    403,
+		LoginFailed:          400,
+		InvalidLoginState:    400,
+		InvalidIDToken:       401,
+		AccountMismatch:      403,
+		AlreadyVerified:      409,
+		InvalidRefreshToken:  401,
+		UpstreamAuthFailed:   502,
+		UserNotFound:         404,
+		WiFiNotFound:         404,
+		DuplicateNetwork:     409,
+		TooFar:               403,
+		LocationDoubtful:     403,
+		ScanMismatch:         403,
+		NotSaved:             404,
+		TicketInvalid:        401,
+		TicketUsed:           410,
+		RateLimited:          429,
+		AIUnavailable:        503,
+		AIFailed:             502,
+		Internal:             500,
+	}
+	for code, status := range want {
+		if got := code.Status(); got != status {
+			t.Errorf("%s.Status() = %d, want %d", code, got, status)
+		}
+	}
+	for code := range statuses {
+		if _, ok := want[code]; !ok {
+			t.Errorf("%s has a status but no test", code)
+		}
+	}
+	if got := Code("NO_SUCH_CODE").Status(); got != http.StatusInternalServerError {
+		t.Errorf("unknown code status = %d, want 500", got)
+	}
+}
+
+// serveProblem runs write behind the logging middleware, which assigns the
+// request ID, and decodes the body.
+func serveProblem(t *testing.T, write func(http.ResponseWriter, *http.Request)) (*httptest.ResponseRecorder, map[string]interface{}) {
+	t.Helper()
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/api/wifi/x", nil)
+	req.Header.Set(logging.RequestIDHeader, "req-1")
+	logging.Middleware(logger, http.HandlerFunc(write)).ServeHTTP(rec, req)
+	var body map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&body); err != nil {
+		t.Fatal(err)
+	}
+	return rec, body
+}
+
+func TestWrite(t *testing.T) {
+	rec, body := serveProblem(t, func(w http.ResponseWriter, r *http.Request) {
+		Write(w, r, WiFiNotFound, "WiFi not found")
+	})
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("status = %d, want 404", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q", ct)
+	}
+	if rec.Header().Get("X-Content-Type-Options") != "nosniff" {
+		t.Error("X-Content-Type-Options not set")
+	}
+	want := map[string]interface{}{
+		"type":       "about:blank",
+		"title":      "Not Found",
+		"status":     float64(404),
+		"detail":     "WiFi not found",
+		"code":       "WIFI_NOT_FOUND",
+		"request_id": "req-1",
+	}
+	if !reflect.DeepEqual(body, want) {
+		t.Errorf("body = %v, want %v", body, want)
+	}
+}
+
+func TestValidation(t *testing.T) {
+	rec, body := serveProblem(t, func(w http.ResponseWriter, r *http.Request) {
+		Validation(w, r,
+			FieldError{Field: "ssid", Message: "is required"},
+			FieldError{Field: "location.coordinates", Message: "must be [longitude, latitude]"},
+		)
+	})
+	if rec.Code != http.StatusBadRequest || body["code"] != "VALIDATION_FAILED" {
+		t.Errorf("status = %d, code = %v", rec.Code, body["code"])
+	}
+	want := []interface{}{
+		map[string]interface{}{"field": "ssid", "message": "is required"},
+		map[string]interface{}{"field": "location.coordinates", "message": "must be [longitude, latitude]"},
+	}
+	if !reflect.DeepEqual(body["errors"], want) {
+		t.Errorf("errors = %v, want %v", body["errors"], want)
+	}
+}
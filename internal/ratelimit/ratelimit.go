@@ -0,0 +1,146 @@
+// Package ratelimit throttles routes with token buckets. A Rule pairs a
+// Policy with the key a caller is counted under (their user ID or IP); a
+// route may carry several rules and is refused once any of them is empty.
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wifi-go-backend/config"
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/logging"
+	"wifi-go-backend/internal/metrics"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/store"
+	"wifi-go-backend/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Policy allows bursts of up to Limit requests and refills at Limit per
+// Interval. The zero Policy is disabled.
+type Policy struct {
+	Name     string
+	Limit    int
+	Interval time.Duration
+}
+
+// ParsePolicy reads spec in the format of config.ParseRate, e.g. "30/1h".
+// "" and "off" disable the policy.
+func ParsePolicy(name, spec string) (Policy, error) {
+	limit, interval, err := config.ParseRate(spec)
+	if err != nil || limit == 0 {
+		return Policy{}, err
+	}
+	return Policy{Name: name, Limit: limit, Interval: interval}, nil
+}
+
+func (p Policy) Enabled() bool {
+	return p.Limit > 0
+}
+
+// String formats p the way ParsePolicy reads it.
+func (p Policy) String() string {
+	if !p.Enabled() {
+		return "off"
+	}
+	return strconv.Itoa(p.Limit) + "/" + p.Interval.String()
+}
+
+// untilTokens returns how long p takes to refill n tokens.
+func (p Policy) untilTokens(n float64) time.Duration {
+	if n <= 0 {
+		return 0
+	}
+	return time.Duration(n / float64(p.Limit) * float64(p.Interval))
+}
+
+// KeyFunc names the bucket a request is counted in.
+type KeyFunc func(r *http.Request) string
+
+// ByIP counts requests per client address.
+func ByIP(r *http.Request) string {
+	return "ip:" + utils.ClientIP(r)
+}
+
+// ByUser counts requests per authenticated user, falling back to the client
+// address for anonymous callers.
+func ByUser(r *http.Request) string {
+	if sub := auth.SubjectFromContext(r.Context()); sub != "" {
+		return "user:" + sub
+	}
+	return ByIP(r)
+}
+
+type Rule struct {
+	Policy Policy
+	Key    KeyFunc
+}
+
+// Limiter applies rules against a shared bucket store. A nil Limiter lets
+// every request through.
+type Limiter struct {
+	Store  store.RateLimitStore
+	Logger *slog.Logger
+}
+
+// Limit wraps next so that each request takes a token from every enabled
+// rule's bucket. Responses carry X-RateLimit-Limit, -Remaining and -Reset
+// (seconds until the bucket is full) for the tightest rule; refused requests
+// get 429 with Retry-After.
+func (l *Limiter) Limit(next httprouter.Handle, rules ...Rule) httprouter.Handle {
+	if l == nil {
+		return next
+	}
+	var enabled []Rule
+	for _, rule := range rules {
+		if rule.Policy.Enabled() {
+			enabled = append(enabled, rule)
+		}
+	}
+	if len(enabled) == 0 {
+		return next
+	}
+	return func(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+		tightest := -1.0
+		for _, rule := range enabled {
+			p := rule.Policy
+			allowed, remaining, err := l.Store.Take(r.Context(), p.Name+":"+rule.Key(r), p.Limit, p.Interval)
+			if err != nil {
+				// Fail open: an unavailable store should not take the API down.
+				// Counted so that a limiter that has silently stopped shows up
+				l.Logger.Warn("rate limit check failed",
+					"request_id", logging.RequestID(r.Context()), "policy", p.Name, "err", err)
+				metrics.RateLimitStoreErrors.WithLabelValues(p.Name).Inc()
+				continue
+			}
+			if !allowed {
+				setHeaders(w, p, remaining)
+				w.Header().Set("Retry-After", seconds(p.untilTokens(1-remaining)))
+				metrics.RateLimitRejections.WithLabelValues(p.Name).Inc()
+				problem.Write(w, r, problem.RateLimited, "Too many requests, try again later")
+				return
+			}
+			if tightest < 0 || remaining < tightest {
+				tightest = remaining
+				setHeaders(w, p, remaining)
+			}
+		}
+		next(w, r, ps)
+	}
+}
+
+func setHeaders(w http.ResponseWriter, p Policy, remaining float64) {
+	w.Header().Set("X-RateLimit-Limit", strconv.Itoa(p.Limit))
+	w.Header().Set("X-RateLimit-Remaining", strconv.Itoa(int(math.Floor(remaining))))
+	w.Header().Set("X-RateLimit-Reset", seconds(p.untilTokens(float64(p.Limit)-remaining)))
+}
+
+// seconds rounds d up to whole seconds, as the headers expect.
+func seconds(d time.Duration) string {
+	return strconv.FormatInt(int64(math.Ceil(d.Seconds())), 10)
+}
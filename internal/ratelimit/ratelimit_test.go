@@ -0,0 +1,160 @@
+package ratelimit
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"wifi-go-backend/internal/metrics"
+	"wifi-go-backend/internal/store"
+
+	"github.com/julienschmidt/httprouter"
+	dto "github.com/prometheus/client_model/go"
+)
+
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time { return c.now }
+
+func newTestLimiter(clock *fakeClock) *Limiter {
+	s := store.NewMemoryRateLimitStore()
+	s.Now = clock.Now
+	return &Limiter{Store: s, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+}
+
+func ok(w http.ResponseWriter, _ *http.Request, _ httprouter.Params) {
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func call(handle httprouter.Handle, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, "/api/wifi/nearby", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	handle(rec, req, nil)
+	return rec
+}
+
+func expectHeaders(t *testing.T, rec *httptest.ResponseRecorder, want map[string]string) {
+	t.Helper()
+	for k, v := range want {
+		if got := rec.Header().Get(k); got != v {
+			t.Errorf("%s = %q, want %q", k, got, v)
+		}
+	}
+}
+
+func TestLimitRefusesWhenEmptyAndRefills(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	l := newTestLimiter(clock)
+	handle := l.Limit(ok, Rule{Policy: Policy{Name: "ip", Limit: 2, Interval: time.Minute}, Key: ByIP})
+
+	rec := call(handle, "192.0.2.1:1234")
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("first request status = %d", rec.Code)
+	}
+	expectHeaders(t, rec, map[string]string{
+		"X-RateLimit-Limit":     "2",
+		"X-RateLimit-Remaining": "1",
+		"X-RateLimit-Reset":     "30",
+	})
+	call(handle, "192.0.2.1:1234")
+
+	rec = call(handle, "192.0.2.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("third request status = %d, want 429", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/problem+json" {
+		t.Errorf("Content-Type = %q, want application/problem+json", ct)
+	}
+	expectHeaders(t, rec, map[string]string{
+		"Retry-After":           "30",
+		"X-RateLimit-Remaining": "0",
+		"X-RateLimit-Reset":     "60",
+	})
+
+	// Another client has its own bucket
+	if rec := call(handle, "192.0.2.2:1234"); rec.Code != http.StatusNoContent {
+		t.Errorf("other client status = %d, want 204", rec.Code)
+	}
+
+	clock.now = clock.now.Add(30 * time.Second)
+	if rec := call(handle, "192.0.2.1:1234"); rec.Code != http.StatusNoContent {
+		t.Errorf("status after refill = %d, want 204", rec.Code)
+	}
+}
+
+func TestLimitReportsTightestRule(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	l := newTestLimiter(clock)
+	handle := l.Limit(ok,
+		Rule{Policy: Policy{Name: "wide", Limit: 100, Interval: time.Minute}, Key: ByIP},
+		Rule{Policy: Policy{Name: "narrow", Limit: 5, Interval: time.Hour}, Key: ByIP},
+		Rule{Policy: Policy{}, Key: ByIP},
+	)
+	rec := call(handle, "192.0.2.1:1234")
+	expectHeaders(t, rec, map[string]string{"X-RateLimit-Limit": "5", "X-RateLimit-Remaining": "4"})
+}
+
+func TestLimitWithoutEnabledRules(t *testing.T) {
+	var nilLimiter *Limiter
+	if rec := call(nilLimiter.Limit(ok, Rule{Policy: Policy{Name: "ip", Limit: 1, Interval: time.Minute}, Key: ByIP}), "192.0.2.1:1"); rec.Code != http.StatusNoContent {
+		t.Errorf("nil Limiter status = %d", rec.Code)
+	}
+	l := newTestLimiter(&fakeClock{now: time.Now()})
+	rec := call(l.Limit(ok, Rule{Key: ByIP}), "192.0.2.1:1")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Errorf("disabled rule: status = %d, headers = %v", rec.Code, rec.Header())
+	}
+}
+
+func storeErrors(t *testing.T, policy string) float64 {
+	t.Helper()
+	var m dto.Metric
+	if err := metrics.RateLimitStoreErrors.WithLabelValues(policy).Write(&m); err != nil {
+		t.Fatal(err)
+	}
+	return m.GetCounter().GetValue()
+}
+
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, int, time.Duration) (bool, float64, error) {
+	return false, 0, errors.New("store unavailable")
+}
+
+func TestLimitFailsOpen(t *testing.T) {
+	l := &Limiter{Store: failingStore{}, Logger: slog.New(slog.NewTextHandler(io.Discard, nil))}
+	handle := l.Limit(ok, Rule{Policy: Policy{Name: "fail-open", Limit: 1, Interval: time.Minute}, Key: ByIP})
+	before := storeErrors(t, "fail-open")
+	for i := 0; i < 3; i++ {
+		if rec := call(handle, "192.0.2.1:1234"); rec.Code != http.StatusNoContent {
+			t.Fatalf("request %d status = %d, want 204 while the store is down", i+1, rec.Code)
+		}
+	}
+	if n := storeErrors(t, "fail-open") - before; n != 3 {
+		t.Errorf("store errors counted = %v, want 3", n)
+	}
+}
+
+func TestParsePolicy(t *testing.T) {
+	p, err := ParsePolicy("connect", "30/1h")
+	if err != nil || p != (Policy{Name: "connect", Limit: 30, Interval: time.Hour}) {
+		t.Errorf("ParsePolicy(30/1h) = %+v, %v", p, err)
+	}
+	if p.String() != "30/1h0m0s" {
+		t.Errorf("String() = %q", p.String())
+	}
+	for _, spec := range []string{"", "off"} {
+		if p, err := ParsePolicy("x", spec); err != nil || p.Enabled() {
+			t.Errorf("ParsePolicy(%q) = %+v, %v; want disabled", spec, p, err)
+		}
+	}
+	if _, err := ParsePolicy("x", "lots"); err == nil {
+		t.Error("ParsePolicy(lots) succeeded")
+	}
+}
@@ -0,0 +1,53 @@
+package risk
+
+import "wifi-go-backend/internal/models"
+
+// A scan proves presence when at least minNeighbors of the stored
+// neighbouring access points are visible and they make up at least
+// minNeighborShare of them; access points come and go, so a partial overlap
+// is expected. Seeing one of the network's own access points lowers the bar
+// to a single neighbour, but is not enough alone: those BSSIDs are listed
+// in nearby results and could be replayed.
+const (
+	minNeighbors     = 3
+	minNeighborShare = 0.3
+)
+
+// ScanMatch is how a client's WiFi scan list overlaps a network's stored
+// fingerprint.
+type ScanMatch struct {
+	Own       bool // one of the network's own access points is visible
+	Neighbors int  // stored neighbouring access points that are visible
+	Stored    int  // stored neighbouring access points
+}
+
+// MatchScan compares scan, a list of normalized BSSIDs, with wifi's
+// fingerprint.
+func MatchScan(wifi *models.WiFi, scan []string) ScanMatch {
+	visible := make(map[string]bool, len(scan))
+	for _, b := range scan {
+		visible[b] = true
+	}
+	m := ScanMatch{Stored: len(wifi.NeighborBSSIDs)}
+	for _, b := range wifi.BSSIDs {
+		if visible[b] {
+			m.Own = true
+			break
+		}
+	}
+	for _, b := range wifi.NeighborBSSIDs {
+		if visible[b] {
+			m.Neighbors++
+		}
+	}
+	return m
+}
+
+// Present reports whether the scan places the client at the network.
+func (m ScanMatch) Present() bool {
+	share := float64(m.Neighbors) >= minNeighborShare*float64(m.Stored)
+	if m.Own && m.Neighbors > 0 {
+		return share
+	}
+	return m.Neighbors >= minNeighbors && share
+}
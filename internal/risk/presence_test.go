@@ -0,0 +1,54 @@
+package risk
+
+import (
+	"testing"
+
+	"wifi-go-backend/internal/models"
+)
+
+func TestMatchScan(t *testing.T) {
+	wifi := &models.WiFi{
+		BSSIDs:         []string{"aa:00:00:00:00:01", "aa:00:00:00:00:02"},
+		NeighborBSSIDs: []string{"bb:00:00:00:00:01", "bb:00:00:00:00:02", "bb:00:00:00:00:03"},
+	}
+	tests := []struct {
+		name string
+		scan []string
+		want ScanMatch
+	}{
+		{"empty", nil, ScanMatch{Stored: 3}},
+		{"own only", []string{"aa:00:00:00:00:02"}, ScanMatch{Own: true, Stored: 3}},
+		{"neighbours", []string{"bb:00:00:00:00:01", "bb:00:00:00:00:03", "cc:00:00:00:00:01"}, ScanMatch{Neighbors: 2, Stored: 3}},
+		{"duplicates count once", []string{"bb:00:00:00:00:01", "bb:00:00:00:00:01"}, ScanMatch{Neighbors: 1, Stored: 3}},
+		{"both", []string{"aa:00:00:00:00:01", "aa:00:00:00:00:02", "bb:00:00:00:00:02"}, ScanMatch{Own: true, Neighbors: 1, Stored: 3}},
+	}
+	for _, tt := range tests {
+		if got := MatchScan(wifi, tt.scan); got != tt.want {
+			t.Errorf("%s: MatchScan = %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScanMatchPresent(t *testing.T) {
+	tests := []struct {
+		name string
+		m    ScanMatch
+		want bool
+	}{
+		{"nothing", ScanMatch{Stored: 10}, false},
+		{"own alone", ScanMatch{Own: true, Stored: 10}, false},
+		{"own alone without neighbours stored", ScanMatch{Own: true}, false},
+		{"own and one of three", ScanMatch{Own: true, Neighbors: 1, Stored: 3}, true},
+		{"own and one of ten", ScanMatch{Own: true, Neighbors: 1, Stored: 10}, false},
+		{"own and three of ten", ScanMatch{Own: true, Neighbors: 3, Stored: 10}, true},
+		{"two neighbours", ScanMatch{Neighbors: 2, Stored: 2}, false},
+		{"three of three", ScanMatch{Neighbors: 3, Stored: 3}, true},
+		{"three of ten", ScanMatch{Neighbors: 3, Stored: 10}, true},
+		{"three of eleven", ScanMatch{Neighbors: 3, Stored: 11}, false},
+	}
+	for _, tt := range tests {
+		if got := tt.m.Present(); got != tt.want {
+			t.Errorf("%s: Present() = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
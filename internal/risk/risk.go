@@ -0,0 +1,152 @@
+// Package risk judges whether a client-reported position is plausible before
+// a WiFi password is revealed. The proximity check trusts coordinates the
+// client sends, so the engine looks for the traces spoofing leaves: jumps no
+// traveller could make, missing or vague accuracy, coordinates copied from
+// the stored network, and requests for many networks in a short time.
+package risk
+
+import (
+	"math"
+	"time"
+
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Points each signal adds to the score, which is capped at 100.
+const (
+	impossibleTravelPoints = 60
+	accuracyMissingPoints  = 10
+	lowAccuracyPoints      = 25
+	exactLocationPoints    = 30
+	repeatedExactPoints    = 30
+	manyNetworksPoints     = 30
+)
+
+// exactDegrees is about 10 cm; real GPS fixes never land this close to
+// where the network was pinned.
+const exactDegrees = 1e-6
+
+// Report is a position sent by the client. AccuracyM is 0 when the client
+// did not say how accurate it is.
+type Report struct {
+	Latitude  float64
+	Longitude float64
+	AccuracyM float64
+}
+
+// Action is what the caller should do with an assessment.
+type Action int
+
+const (
+	Allow Action = iota
+	StepUp
+	Deny
+)
+
+// Engine scores reports against the user's connect history. A threshold of
+// 0 turns its action off.
+type Engine struct {
+	MaxSpeedKmh   float64       // faster travel between reports is impossible
+	MaxAccuracyM  float64       // a vaguer fix cannot place the user in range
+	History       time.Duration // how far back reports are compared
+	NetworkWindow time.Duration
+	MaxNetworks   int // distinct networks per NetworkWindow
+
+	DenyScore   int
+	StepUpScore int
+	StepUpLevel models.VerificationLevel // required at or above StepUpScore
+}
+
+// Lookback is how much connect history Assess needs.
+func (e *Engine) Lookback() time.Duration {
+	if e.NetworkWindow > e.History {
+		return e.NetworkWindow
+	}
+	return e.History
+}
+
+// Assess scores r, a request at now for the network wifiID located at
+// network ([longitude, latitude]). history is the user's recent issuances,
+// newest first.
+func (e *Engine) Assess(now time.Time, r Report, wifiID primitive.ObjectID, network []float64, history []models.ConnectIssuance) *models.RiskAssessment {
+	a := &models.RiskAssessment{}
+	add := func(points int, reason string) {
+		a.Score += points
+		a.Reasons = append(a.Reasons, reason)
+	}
+
+	if e.impossibleTravel(now, r, history) {
+		add(impossibleTravelPoints, models.RiskImpossibleTravel)
+	}
+
+	switch {
+	case r.AccuracyM == 0:
+		add(accuracyMissingPoints, models.RiskAccuracyMissing)
+	case e.MaxAccuracyM > 0 && r.AccuracyM > e.MaxAccuracyM:
+		add(lowAccuracyPoints, models.RiskLowAccuracy)
+	}
+
+	if len(network) == 2 &&
+		math.Abs(r.Longitude-network[0]) < exactDegrees && math.Abs(r.Latitude-network[1]) < exactDegrees {
+		add(exactLocationPoints, models.RiskExactLocation)
+		for _, h := range history {
+			if now.Sub(h.IssuedAt) <= e.History && h.Risk.Has(models.RiskExactLocation) {
+				add(repeatedExactPoints, models.RiskRepeatedExact)
+				break
+			}
+		}
+	}
+
+	if e.MaxNetworks > 0 {
+		networks := map[primitive.ObjectID]bool{wifiID: true}
+		for _, h := range history {
+			if now.Sub(h.IssuedAt) <= e.NetworkWindow {
+				networks[h.WiFiID] = true
+			}
+		}
+		if len(networks) > e.MaxNetworks {
+			add(manyNetworksPoints, models.RiskManyNetworks)
+		}
+	}
+
+	if a.Score > 100 {
+		a.Score = 100
+	}
+	return a
+}
+
+// impossibleTravel reports whether reaching r from any earlier report would
+// have taken more than MaxSpeedKmh. Both fixes' accuracy is allowed for.
+func (e *Engine) impossibleTravel(now time.Time, r Report, history []models.ConnectIssuance) bool {
+	if e.MaxSpeedKmh <= 0 {
+		return false
+	}
+	for _, h := range history {
+		if len(h.Coordinates) != 2 || now.Sub(h.IssuedAt) > e.History {
+			continue
+		}
+		km := utils.Haversine(r.Latitude, r.Longitude, h.Coordinates[1], h.Coordinates[0])
+		km -= (r.AccuracyM + h.AccuracyM) / 1000
+		// A floor on the elapsed time keeps GPS jitter between quick
+		// requests from reading as supersonic
+		hours := math.Max(now.Sub(h.IssuedAt).Hours(), time.Minute.Hours())
+		if km > 0 && km/hours > e.MaxSpeedKmh {
+			return true
+		}
+	}
+	return false
+}
+
+// Action decides what to do with a, given the caller's verification level.
+func (e *Engine) Action(a *models.RiskAssessment, level models.VerificationLevel) Action {
+	if e.DenyScore > 0 && a.Score >= e.DenyScore {
+		return Deny
+	}
+	if e.StepUpScore > 0 && a.Score >= e.StepUpScore && level < e.StepUpLevel {
+		return StepUp
+	}
+	return Allow
+}
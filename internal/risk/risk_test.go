@@ -0,0 +1,152 @@
+package risk
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testEngine() *Engine {
+	return &Engine{
+		MaxSpeedKmh:   900,
+		MaxAccuracyM:  100,
+		History:       24 * time.Hour,
+		NetworkWindow: time.Hour,
+		MaxNetworks:   3,
+		DenyScore:     80,
+		StepUpScore:   50,
+		StepUpLevel:   models.LevelCivicIDVerified,
+	}
+}
+
+func TestAssess(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	wifiID := primitive.NewObjectID()
+	// Berlin, [longitude, latitude]
+	network := []float64{13.4, 52.5}
+	near := Report{Latitude: 52.5005, Longitude: 13.4, AccuracyM: 20}
+	exact := Report{Latitude: 52.5, Longitude: 13.4, AccuracyM: 20}
+	at := func(ago time.Duration, lng, lat float64) models.ConnectIssuance {
+		return models.ConnectIssuance{
+			WiFiID:      primitive.NewObjectID(),
+			Coordinates: []float64{lng, lat},
+			AccuracyM:   20,
+			IssuedAt:    now.Add(-ago),
+		}
+	}
+	exactBefore := at(2*time.Hour, 13.4, 52.5)
+	exactBefore.Risk = &models.RiskAssessment{Reasons: []string{models.RiskExactLocation}}
+	exactLongAgo := exactBefore
+	exactLongAgo.IssuedAt = now.Add(-48 * time.Hour)
+
+	tests := []struct {
+		name    string
+		report  Report
+		history []models.ConnectIssuance
+		score   int
+		reasons []string
+	}{
+		{"plausible", near, nil, 0, nil},
+		{"accuracy missing", Report{Latitude: 52.5005, Longitude: 13.4}, nil, accuracyMissingPoints,
+			[]string{models.RiskAccuracyMissing}},
+		{"low accuracy", Report{Latitude: 52.5005, Longitude: 13.4, AccuracyM: 500}, nil, lowAccuracyPoints,
+			[]string{models.RiskLowAccuracy}},
+		// Munich to Berlin (about 500 km) in 10 minutes
+		{"impossible travel", near, []models.ConnectIssuance{at(10*time.Minute, 11.58, 48.14)}, impossibleTravelPoints,
+			[]string{models.RiskImpossibleTravel}},
+		{"travel by plane", near, []models.ConnectIssuance{at(2*time.Hour, 11.58, 48.14)}, 0, nil},
+		{"travel outside history", near, []models.ConnectIssuance{at(25*time.Hour, -74, 40.7)}, 0, nil},
+		// GPS jitter between quick requests is not travel
+		{"jitter", near, []models.ConnectIssuance{at(time.Second, 13.4, 52.5004)}, 0, nil},
+		{"exact location", exact, nil, exactLocationPoints, []string{models.RiskExactLocation}},
+		{"repeated exact location", exact, []models.ConnectIssuance{exactBefore}, exactLocationPoints + repeatedExactPoints,
+			[]string{models.RiskExactLocation, models.RiskRepeatedExact}},
+		{"exact location outside history", exact, []models.ConnectIssuance{exactLongAgo}, exactLocationPoints,
+			[]string{models.RiskExactLocation}},
+		{"many networks", near, []models.ConnectIssuance{
+			at(10*time.Minute, 13.4, 52.5), at(20*time.Minute, 13.4, 52.5), at(30*time.Minute, 13.4, 52.5),
+		}, manyNetworksPoints, []string{models.RiskManyNetworks}},
+		{"networks outside window", near, []models.ConnectIssuance{
+			at(10*time.Minute, 13.4, 52.5), at(20*time.Minute, 13.4, 52.5), at(2*time.Hour, 13.4, 52.5),
+		}, 0, nil},
+		{"capped", Report{Latitude: 52.5, Longitude: 13.4}, []models.ConnectIssuance{
+			at(time.Minute, -74, 40.7), exactBefore, at(20*time.Minute, 13.4, 52.5), at(30*time.Minute, 13.4, 52.5),
+		}, 100, []string{
+			models.RiskImpossibleTravel, models.RiskAccuracyMissing, models.RiskExactLocation,
+			models.RiskRepeatedExact, models.RiskManyNetworks,
+		}},
+	}
+	e := testEngine()
+	for _, tt := range tests {
+		a := e.Assess(now, tt.report, wifiID, network, tt.history)
+		if a.Score != tt.score || !reflect.DeepEqual(a.Reasons, tt.reasons) {
+			t.Errorf("%s: Assess = %d %v, want %d %v", tt.name, a.Score, a.Reasons, tt.score, tt.reasons)
+		}
+	}
+}
+
+func TestAssessRepeatsOfOneNetworkCountOnce(t *testing.T) {
+	now := time.Now()
+	wifiID := primitive.NewObjectID()
+	var history []models.ConnectIssuance
+	for i := 0; i < 5; i++ {
+		history = append(history, models.ConnectIssuance{WiFiID: wifiID, IssuedAt: now.Add(-time.Minute)})
+	}
+	a := testEngine().Assess(now, Report{Latitude: 52.5005, Longitude: 13.4, AccuracyM: 20}, wifiID, []float64{13.4, 52.5}, history)
+	if a.Has(models.RiskManyNetworks) {
+		t.Errorf("reasons = %v, want no many_networks for one network", a.Reasons)
+	}
+}
+
+func TestAssessDisabledRules(t *testing.T) {
+	now := time.Now()
+	e := &Engine{History: time.Hour, NetworkWindow: time.Hour}
+	history := []models.ConnectIssuance{
+		{WiFiID: primitive.NewObjectID(), Coordinates: []float64{-74, 40.7}, IssuedAt: now.Add(-time.Minute)},
+	}
+	a := e.Assess(now, Report{Latitude: 52.5005, Longitude: 13.4, AccuracyM: 5000}, primitive.NewObjectID(), []float64{13.4, 52.5}, history)
+	if a.Score != 0 {
+		t.Errorf("Assess = %d %v, want 0 with speed, accuracy and network limits off", a.Score, a.Reasons)
+	}
+}
+
+func TestAction(t *testing.T) {
+	tests := []struct {
+		score int
+		level models.VerificationLevel
+		want  Action
+	}{
+		{0, models.LevelAnonymous, Allow},
+		{49, models.LevelAnonymous, Allow},
+		{50, models.LevelAnonymous, StepUp},
+		{50, models.LevelEmailVerified, StepUp},
+		{50, models.LevelCivicIDVerified, Allow},
+		{79, models.LevelTrustedContributor, Allow},
+		{80, models.LevelTrustedContributor, Deny},
+		{100, models.LevelAnonymous, Deny},
+	}
+	e := testEngine()
+	for _, tt := range tests {
+		if got := e.Action(&models.RiskAssessment{Score: tt.score}, tt.level); got != tt.want {
+			t.Errorf("Action(%d, %v) = %v, want %v", tt.score, tt.level, got, tt.want)
+		}
+	}
+
+	off := &Engine{}
+	if got := off.Action(&models.RiskAssessment{Score: 100}, models.LevelAnonymous); got != Allow {
+		t.Errorf("Action with thresholds off = %v, want Allow", got)
+	}
+}
+
+func TestLookback(t *testing.T) {
+	if got := (&Engine{History: time.Hour, NetworkWindow: 2 * time.Hour}).Lookback(); got != 2*time.Hour {
+		t.Errorf("Lookback = %v, want the network window", got)
+	}
+	if got := testEngine().Lookback(); got != 24*time.Hour {
+		t.Errorf("Lookback = %v, want the history", got)
+	}
+}
@@ -2,67 +2,209 @@ package routes
 
 import (
 	"encoding/json"
+	"errors"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
-	"wifi-go-backend/internal/db"
+	"wifi-go-backend/internal/auth"
 	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/secrets"
+	"wifi-go-backend/internal/store"
+	"wifi-go-backend/internal/utils"
 
 	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Limits on BSSID lists: a network's own access points, and a scan list
+const (
+	maxOwnBSSIDs  = 16
+	maxScanBSSIDs = 100
 )
 
 func (h *Handlers) WiFiScan(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	var wifi models.WiFi
 	if err := json.NewDecoder(r.Body).Decode(&wifi); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid request body"))
+		problem.Write(w, r, problem.InvalidBody, "Invalid request body")
 		return
 	}
 
-	// Ensure description is present
-	if wifi.Description == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Description is required"))
+	if errs := validateWiFi(&wifi); len(errs) > 0 {
+		problem.Validation(w, r, errs...)
 		return
 	}
 
-	// Ensure location is GeoJSON format
-	wifi.Location.Type = "Point"
-	if len(wifi.Location.Coordinates) != 2 {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Coordinates must be [longitude, latitude]"))
+	// The caller owns what they add
+	wifi.CreatedBy = auth.SubjectFromContext(r.Context())
+	wifi.CreatedAt = time.Now().UTC()
+	wifi.UpdatedAt = wifi.CreatedAt
+
+	// Encrypt the password before it reaches the database
+	wifi.ID = primitive.NewObjectID()
+	if err := secrets.EncryptWiFiPassword(r.Context(), h.PasswordKeys, &wifi); err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to encrypt WiFi password")
 		return
 	}
 
-	coll, err := db.GetWiFiCollection()
+	// Only add if there is no WiFi with the same SSID at the same address
+	err := h.WiFi.Insert(r.Context(), &wifi)
+	if errors.Is(err, store.ErrDuplicate) {
+		problem.Write(w, r, problem.DuplicateNetwork, "WiFi with this SSID already exists at this address")
+		return
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Database connection error"))
+		problem.Write(w, r, problem.Internal, "Failed to save WiFi details")
 		return
 	}
+	if err := h.audit(r, models.AuditWiFiCreate, wifi.ID, wifi.Location.Coordinates); err != nil {
+		h.logger(r).Error("failed to write audit event", "err", err, "wifi_id", wifi.ID.Hex())
+	}
+	h.recordStats(r, store.StatsEvent{
+		NetworksContributed: 1,
+		CityCell:            cityCell(wifi.Location.Coordinates[1], wifi.Location.Coordinates[0]),
+	})
+	h.promoteContributor(r, wifi.ID)
+	w.Header().Set("Location", "/api/wifi/"+wifi.ID.Hex())
+	w.Header().Set("ETag", wifiETag(&wifi))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(wifiDetail(&wifi, true))
+}
 
-	// Only add if there is no WiFi with the same SSID at the same address
-	filter := map[string]interface{}{
-		"ssid":             wifi.SSID,
-		"location.address": wifi.Location.Address,
+// promoteContributor makes a Civic-ID-verified caller a trusted contributor
+// once they have added TRUSTED_CONTRIBUTOR_MIN_NETWORKS networks. Failures
+// are logged; the next contribution tries again.
+func (h *Handlers) promoteContributor(r *http.Request, wifiID primitive.ObjectID) {
+	need := h.Cfg.TrustedContributorMinNetworks
+	if need <= 0 {
+		return
 	}
-	count, err := coll.CountDocuments(r.Context(), filter)
+	ctx := r.Context()
+	userID := auth.SubjectFromContext(ctx)
+	user, err := h.Users.FindByID(ctx, userID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to check existing WiFi"))
+		if !errors.Is(err, store.ErrNotFound) {
+			h.logger(r).Error("failed to load user for promotion", "err", err)
+		}
 		return
 	}
-	if count > 0 {
-		w.WriteHeader(http.StatusConflict)
-		w.Write([]byte("WiFi with this SSID already exists at this address"))
+	if user.VerificationLevel != models.LevelCivicIDVerified {
 		return
 	}
-
-	_, err = coll.InsertOne(r.Context(), wifi)
+	stats, err := h.Stats.Get(ctx, userID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to save WiFi details"))
+		h.logger(r).Error("failed to load statistics for promotion", "err", err)
 		return
 	}
-	w.WriteHeader(http.StatusCreated)
-	w.Write([]byte("WiFi details saved"))
+	if stats.NetworksContributed < int64(need) {
+		return
+	}
+	if _, err := h.Users.RaiseLevel(ctx, userID, models.LevelTrustedContributor); err != nil {
+		h.logger(r).Error("failed to promote user", "err", err)
+		return
+	}
+	if err := h.audit(r, models.AuditUserPromote, wifiID, nil); err != nil {
+		h.logger(r).Error("failed to write audit event", "err", err, "wifi_id", wifiID.Hex())
+	}
+}
+
+// validateWiFi checks a submitted network, normalizing its enums and BSSID
+// lists in place. Only the SSID, description and location are required.
+func validateWiFi(wifi *models.WiFi) []problem.FieldError {
+	var errs []problem.FieldError
+	invalid := func(field, message string) {
+		errs = append(errs, problem.FieldError{Field: field, Message: message})
+	}
+
+	if wifi.SSID == "" {
+		invalid("ssid", "is required")
+	} else if len(wifi.SSID) > 32 {
+		invalid("ssid", "must be at most 32 bytes")
+	}
+	if wifi.Description == "" {
+		invalid("description", "is required")
+	}
+	// Ensure location is GeoJSON format
+	wifi.Location.Type = "Point"
+	if len(wifi.Location.Coordinates) != 2 {
+		invalid("location.coordinates", "must be [longitude, latitude]")
+	} else {
+		lng, lat := wifi.Location.Coordinates[0], wifi.Location.Coordinates[1]
+		errs = append(errs, latLngErrors("location.coordinates[1]", "location.coordinates[0]", lat, lng)...)
+	}
+
+	// The scan fingerprint is optional but must be well-formed
+	var bssidErrs, neighborErrs []problem.FieldError
+	wifi.BSSIDs, bssidErrs = normalizeBSSIDs("bssids", wifi.BSSIDs, maxOwnBSSIDs)
+	wifi.NeighborBSSIDs, neighborErrs = normalizeBSSIDs("neighbor_bssids", wifi.NeighborBSSIDs, maxScanBSSIDs)
+	errs = append(append(errs, bssidErrs...), neighborErrs...)
+	wifi.NeighborBSSIDs = withoutBSSIDs(wifi.NeighborBSSIDs, wifi.BSSIDs)
+
+	wifi.Security = strings.ToLower(strings.TrimSpace(wifi.Security))
+	if wifi.Security != "" && !models.ValidSecurity(wifi.Security) {
+		invalid("security", "must be one of open, wep, wpa, wpa2, wpa3, wpa2_wpa3, enterprise")
+	}
+	wifi.Band = strings.ToLower(strings.TrimSpace(wifi.Band))
+	if wifi.Band != "" && !strings.HasSuffix(wifi.Band, "ghz") {
+		wifi.Band += "ghz"
+	}
+	switch {
+	case wifi.Band != "" && !models.ValidBand(wifi.Band):
+		invalid("band", "must be one of 2.4ghz, 5ghz, 6ghz")
+	case wifi.Channel != 0 && wifi.Band == "":
+		invalid("channel", "requires band")
+	case wifi.Channel != 0 && !models.ValidChannel(wifi.Band, wifi.Channel):
+		invalid("channel", "is not a "+wifi.Band+" channel")
+	}
+	if wifi.RSSI != 0 && (wifi.RSSI < -120 || wifi.RSSI > -1) {
+		invalid("rssi", "must be between -120 and -1 dBm")
+	}
+	return errs
+}
+
+// normalizeBSSIDs validates and normalizes a list of BSSIDs, dropping
+// duplicates. Errors name the offending element, e.g. "bssids[2]".
+func normalizeBSSIDs(field string, in []string, max int) ([]string, []problem.FieldError) {
+	if len(in) > max {
+		return nil, []problem.FieldError{{Field: field, Message: "must have at most " + strconv.Itoa(max) + " entries"}}
+	}
+	var out []string
+	var errs []problem.FieldError
+	seen := make(map[string]bool, len(in))
+	for i, raw := range in {
+		b, ok := utils.NormalizeBSSID(raw)
+		if !ok {
+			errs = append(errs, problem.FieldError{
+				Field:   field + "[" + strconv.Itoa(i) + "]",
+				Message: "must be a MAC address such as aa:bb:cc:dd:ee:ff",
+			})
+			continue
+		}
+		if !seen[b] {
+			seen[b] = true
+			out = append(out, b)
+		}
+	}
+	return out, errs
+}
+
+// withoutBSSIDs returns list without the entries in drop.
+func withoutBSSIDs(list, drop []string) []string {
+	var out []string
+	for _, b := range list {
+		keep := true
+		for _, d := range drop {
+			if b == d {
+				keep = false
+				break
+			}
+		}
+		if keep {
+			out = append(out, b)
+		}
+	}
+	return out
 }
@@ -0,0 +1,128 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/store"
+)
+
+func scanBody(ssid string, lng, lat float64) string {
+	return fmt.Sprintf(`{"ssid": %q, "password": "secret", "description": "cafe", "location": {"coordinates": [%v, %v]}}`, ssid, lng, lat)
+}
+
+func TestWiFiScanPromotesTrustedContributors(t *testing.T) {
+	h := newTestHandlers(t)
+	h.Cfg.TrustedContributorMinNetworks = 2
+	ctx := context.Background()
+	h.Users.UpsertLogin(ctx, &models.User{ID: "civic", VerificationLevel: models.LevelCivicIDVerified})
+	h.Users.UpsertLogin(ctx, &models.User{ID: "email", VerificationLevel: models.LevelEmailVerified})
+
+	for _, user := range []string{"civic", "email"} {
+		for i := 0; i < 2; i++ {
+			rec := serve(h.WiFiScan, http.MethodPost, "/api/wifi/scan", scanBody(fmt.Sprintf("%s-%d", user, i), 13.4, 52.5), user)
+			expectStatus(t, rec, http.StatusCreated)
+		}
+	}
+
+	tests := map[string]models.VerificationLevel{
+		"civic": models.LevelTrustedContributor,
+		"email": models.LevelEmailVerified,
+	}
+	for id, want := range tests {
+		u, err := h.Users.FindByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.VerificationLevel != want {
+			t.Errorf("%s: level = %v, want %v", id, u.VerificationLevel, want)
+		}
+	}
+	events, err := h.Audit.List(ctx, store.AuditFilter{Actor: "civic", Limit: 10})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) == 0 || events[0].Action != models.AuditUserPromote {
+		t.Errorf("latest audit events = %+v, want a %s event", events, models.AuditUserPromote)
+	}
+}
+
+func TestWiFiScanDoesNotPromoteBelowThreshold(t *testing.T) {
+	h := newTestHandlers(t)
+	h.Cfg.TrustedContributorMinNetworks = 3
+	ctx := context.Background()
+	h.Users.UpsertLogin(ctx, &models.User{ID: "civic", VerificationLevel: models.LevelCivicIDVerified})
+
+	expectStatus(t, serve(h.WiFiScan, http.MethodPost, "/api/wifi/scan", scanBody("one", 13.4, 52.5), "civic"), http.StatusCreated)
+	u, _ := h.Users.FindByID(ctx, "civic")
+	if u.VerificationLevel != models.LevelCivicIDVerified {
+		t.Errorf("level = %v after one network, want civic_id_verified", u.VerificationLevel)
+	}
+}
+
+func TestWiFiScanStoresEncryptedNetwork(t *testing.T) {
+	h := newTestHandlers(t)
+	rec := serve(h.WiFiScan, http.MethodPost, "/api/wifi/scan", scanBody("cafe", 13.4, 52.5), "u1")
+	expectStatus(t, rec, http.StatusCreated)
+
+	found, err := h.WiFi.FindWithin(context.Background(), 13.4, 52.5, 0.1)
+	if err != nil || len(found) != 1 {
+		t.Fatalf("FindWithin() = %d networks, %v; want the new one", len(found), err)
+	}
+	wifi := found[0]
+	if rec.Header().Get("Location") != "/api/wifi/"+wifi.ID.Hex() {
+		t.Errorf("Location = %q, want the new network", rec.Header().Get("Location"))
+	}
+	if wifi.CreatedBy != "u1" {
+		t.Errorf("created_by = %q, want u1", wifi.CreatedBy)
+	}
+	if wifi.Password != "" || wifi.PasswordEnc == nil {
+		t.Error("password was stored in plaintext")
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type = %q, want application/json", ct)
+	}
+	var created map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&created); err != nil {
+		t.Fatal(err)
+	}
+	if created["id"] != wifi.ID.Hex() || created["ssid"] != "cafe" || created["created_by"] != "u1" {
+		t.Errorf("response = %v, want the created network", created)
+	}
+	if _, ok := created["password"]; ok {
+		t.Error("response includes the password")
+	}
+	if n := userStats(t, h, "u1").NetworksContributed; n != 1 {
+		t.Errorf("networks_contributed = %d, want 1", n)
+	}
+}
+
+func TestWiFiScanRejectsInvalidNetworks(t *testing.T) {
+	h := newTestHandlers(t)
+	for name, body := range map[string]string{
+		"no ssid":        scanBody("", 13.4, 52.5),
+		"no coordinates": `{"ssid": "cafe", "description": "cafe", "location": {}}`,
+		"bad band":       `{"ssid": "cafe", "description": "cafe", "band": "9", "location": {"coordinates": [13.4, 52.5]}}`,
+		"not json":       `{`,
+		"longitude":      scanBody("cafe", 500, 2),
+		"latitude":       scanBody("cafe", 13.4, -91),
+	} {
+		rec := serve(h.WiFiScan, http.MethodPost, "/api/wifi/scan", body, "u1")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+	if found, _ := h.WiFi.FindWithin(context.Background(), 13.4, 52.5, 1); len(found) != 0 {
+		t.Errorf("%d invalid networks were stored", len(found))
+	}
+}
+
+func TestWiFiScanRejectsDuplicates(t *testing.T) {
+	h := newTestHandlers(t)
+	expectStatus(t, serve(h.WiFiScan, http.MethodPost, "/api/wifi/scan", scanBody("cafe", 13.4, 52.5), "u1"), http.StatusCreated)
+	expectStatus(t, serve(h.WiFiScan, http.MethodPost, "/api/wifi/scan", scanBody("cafe", 13.4, 52.5), "u2"), http.StatusConflict)
+}
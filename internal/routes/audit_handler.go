@@ -0,0 +1,102 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/store"
+	"wifi-go-backend/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	defaultAuditPageSize = 50
+	maxAuditPageSize     = 200
+)
+
+// audit appends an event for the current caller. coords are the
+// coordinates the client supplied, [longitude, latitude], if any.
+func (h *Handlers) audit(r *http.Request, action string, wifiID primitive.ObjectID, coords []float64) error {
+	return h.Audit.Append(r.Context(), &models.AuditEvent{
+		Actor:       auth.SubjectFromContext(r.Context()),
+		Action:      action,
+		WiFiID:      wifiID,
+		Coordinates: coords,
+		IP:          utils.ClientIP(r),
+		UserAgent:   r.UserAgent(),
+		Timestamp:   time.Now().UTC(),
+	})
+}
+
+// AdminAudit handles GET /api/admin/audit
+// Query params (all optional): actor, action, wifi_id, from, to (RFC 3339), cursor, limit
+// Returns: { "events": [...], "next_cursor": "..." }, newest first
+func (h *Handlers) AdminAudit(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	q := r.URL.Query()
+	f := store.AuditFilter{Actor: q.Get("actor"), Action: q.Get("action"), Limit: defaultAuditPageSize}
+
+	if v := q.Get("wifi_id"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			problem.Write(w, r, problem.InvalidWiFiID, "Invalid wifi_id")
+			return
+		}
+		f.WiFiID = id
+	}
+	for name, dst := range map[string]*time.Time{"from": &f.From, "to": &f.To} {
+		if v := q.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				problem.Validation(w, r, problem.FieldError{Field: name, Message: "must be an RFC 3339 timestamp"})
+				return
+			}
+			*dst = t
+		}
+	}
+	if v := q.Get("cursor"); v != "" {
+		id, err := primitive.ObjectIDFromHex(v)
+		if err != nil {
+			problem.Validation(w, r, problem.FieldError{Field: "cursor", Message: "is not a valid cursor"})
+			return
+		}
+		f.Before = id
+	}
+	if v := q.Get("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxAuditPageSize {
+			problem.Validation(w, r, problem.FieldError{Field: "limit", Message: "must be between 1 and " + strconv.Itoa(maxAuditPageSize)})
+			return
+		}
+		f.Limit = n
+	}
+
+	// One extra event tells whether another page follows
+	limit := f.Limit
+	f.Limit++
+	events, err := h.Audit.List(r.Context(), f)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load audit events")
+		return
+	}
+
+	nextCursor := ""
+	if len(events) > limit {
+		events = events[:limit]
+		nextCursor = events[limit-1].ID.Hex()
+	}
+	if events == nil {
+		events = []models.AuditEvent{}
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"events":      events,
+		"next_cursor": nextCursor,
+	})
+}
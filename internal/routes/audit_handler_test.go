@@ -0,0 +1,179 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+type auditPage struct {
+	Events     []models.AuditEvent `json:"events"`
+	NextCursor string              `json:"next_cursor"`
+}
+
+// listAudit calls AdminAudit with query and decodes the page.
+func listAudit(t *testing.T, h *Handlers, query url.Values) auditPage {
+	t.Helper()
+	rec := serve(h.AdminAudit, http.MethodGet, "/api/admin/audit?"+query.Encode(), "", "admin")
+	expectStatus(t, rec, http.StatusOK)
+	var page auditPage
+	if err := json.NewDecoder(rec.Body).Decode(&page); err != nil {
+		t.Fatal(err)
+	}
+	return page
+}
+
+func auditIDs(events []models.AuditEvent) []primitive.ObjectID {
+	ids := make([]primitive.ObjectID, len(events))
+	for i, e := range events {
+		ids[i] = e.ID
+	}
+	return ids
+}
+
+// seedAudit appends events an hour apart, oldest first, and returns them.
+func seedAudit(t *testing.T, h *Handlers, start time.Time, events ...models.AuditEvent) []models.AuditEvent {
+	t.Helper()
+	for i := range events {
+		events[i].Timestamp = start.Add(time.Duration(i) * time.Hour)
+		if err := h.Audit.Append(context.Background(), &events[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return events
+}
+
+func TestAdminAuditFilters(t *testing.T) {
+	h := newTestHandlers(t)
+	network := primitive.NewObjectID()
+	start := time.Date(2026, 5, 1, 8, 0, 0, 0, time.UTC)
+	ev := seedAudit(t, h, start,
+		models.AuditEvent{Actor: "alice", Action: models.AuditWiFiCreate, WiFiID: network},
+		models.AuditEvent{Actor: "bob", Action: models.AuditPasswordReveal, WiFiID: network},
+		models.AuditEvent{Actor: "alice", Action: models.AuditPasswordReveal, WiFiID: primitive.NewObjectID()},
+		models.AuditEvent{Actor: "bob", Action: models.AuditTicketIssue, WiFiID: network},
+	)
+
+	tests := []struct {
+		name  string
+		query url.Values
+		want  []models.AuditEvent
+	}{
+		{"all, newest first", url.Values{}, []models.AuditEvent{ev[3], ev[2], ev[1], ev[0]}},
+		{"actor", url.Values{"actor": {"alice"}}, []models.AuditEvent{ev[2], ev[0]}},
+		{"action", url.Values{"action": {models.AuditPasswordReveal}}, []models.AuditEvent{ev[2], ev[1]}},
+		{"actor and action", url.Values{"actor": {"bob"}, "action": {models.AuditPasswordReveal}}, []models.AuditEvent{ev[1]}},
+		{"network", url.Values{"wifi_id": {network.Hex()}}, []models.AuditEvent{ev[3], ev[1], ev[0]}},
+		// from is inclusive, to exclusive
+		{"time range", url.Values{
+			"from": {start.Add(time.Hour).Format(time.RFC3339)},
+			"to":   {start.Add(3 * time.Hour).Format(time.RFC3339)},
+		}, []models.AuditEvent{ev[2], ev[1]}},
+		{"nothing matches", url.Values{"actor": {"carol"}}, nil},
+	}
+	for _, tt := range tests {
+		page := listAudit(t, h, tt.query)
+		got, want := auditIDs(page.Events), auditIDs(tt.want)
+		if len(got) != len(want) {
+			t.Errorf("%s: events = %v, want %v", tt.name, got, want)
+			continue
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Errorf("%s: events = %v, want %v", tt.name, got, want)
+				break
+			}
+		}
+		if page.Events == nil || page.NextCursor != "" {
+			t.Errorf("%s: events = %v, next_cursor = %q; want a list and no further page", tt.name, page.Events, page.NextCursor)
+		}
+	}
+}
+
+func TestAdminAuditPaginates(t *testing.T) {
+	h := newTestHandlers(t)
+	var events []models.AuditEvent
+	for i := 0; i < 5; i++ {
+		events = append(events, models.AuditEvent{Actor: "alice", Action: models.AuditPasswordReveal})
+	}
+	seedAudit(t, h, time.Now().UTC().Add(-time.Hour), events...)
+
+	// Pages of two: 2, 2, 1, and the last page has no cursor
+	var seen []primitive.ObjectID
+	query := url.Values{"limit": {"2"}}
+	for pages := 1; ; pages++ {
+		page := listAudit(t, h, query)
+		seen = append(seen, auditIDs(page.Events)...)
+		if page.NextCursor == "" {
+			if pages != 3 || len(page.Events) != 1 {
+				t.Errorf("ended on page %d with %d events, want page 3 with 1", pages, len(page.Events))
+			}
+			break
+		}
+		if pages > 3 {
+			t.Fatal("pagination did not end")
+		}
+		query.Set("cursor", page.NextCursor)
+	}
+	if len(seen) != 5 {
+		t.Fatalf("saw %d events, want 5", len(seen))
+	}
+	for i := 1; i < len(seen); i++ {
+		if seen[i].Hex() >= seen[i-1].Hex() {
+			t.Errorf("event %d out of order or repeated: %v", i, seen)
+		}
+	}
+
+	// A limit that exactly covers the rest leaves no empty page behind
+	page := listAudit(t, h, url.Values{"limit": {"5"}})
+	if len(page.Events) != 5 || page.NextCursor != "" {
+		t.Errorf("limit 5 of 5: %d events, next_cursor = %q", len(page.Events), page.NextCursor)
+	}
+}
+
+func TestAdminAuditRejectsBadQueries(t *testing.T) {
+	h := newTestHandlers(t)
+	for _, q := range []string{"wifi_id=nope", "from=yesterday", "to=2026-05-01", "cursor=nope", "limit=0", "limit=201", "limit=x"} {
+		rec := serve(h.AdminAudit, http.MethodGet, "/api/admin/audit?"+q, "", "admin")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", q, rec.Code)
+		}
+	}
+}
+
+func TestAdminAuditRequiresAdmin(t *testing.T) {
+	h := newTestHandlers(t)
+	h.Users = roleUsers{UserStore: h.Users, roles: map[string]string{"mod": models.RoleModerator, "admin": models.RoleAdmin}}
+	a := auth.NewAuthenticator(h.Sessions)
+	a.Users = h.Users
+	gated := a.RequireRole(models.RoleAdmin, h.AdminAudit)
+	ctx := context.Background()
+	if _, err := h.Users.UpsertLogin(ctx, &models.User{ID: "user", VerificationLevel: models.LevelCivicIDVerified}); err != nil {
+		t.Fatal(err)
+	}
+
+	for caller, want := range map[string]int{"": http.StatusUnauthorized, "user": http.StatusForbidden, "stranger": http.StatusForbidden, "mod": http.StatusForbidden, "admin": http.StatusOK} {
+		req := httptest.NewRequest(http.MethodGet, "/api/admin/audit", nil)
+		if caller != "" {
+			pair, err := h.Sessions.Issue(ctx, caller)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.Header.Set("Authorization", "Bearer "+pair.AccessToken)
+		}
+		rec := httptest.NewRecorder()
+		gated(rec, req, nil)
+		if rec.Code != want {
+			t.Errorf("caller %q: status = %d, want %d", caller, rec.Code, want)
+		}
+	}
+}
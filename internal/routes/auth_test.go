@@ -0,0 +1,275 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-go-backend/config"
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/store"
+
+	"github.com/julienschmidt/httprouter"
+	"golang.org/x/oauth2"
+)
+
+func TestRedirectAllowed(t *testing.T) {
+	h := &Handlers{Cfg: &config.Config{AuthAllowedRedirects: []string{
+		"https://app.example.com/auth",
+		"tap2wifi://auth",
+	}}}
+	tests := []struct {
+		target string
+		want   bool
+	}{
+		{"https://app.example.com/auth", true},
+		{"https://app.example.com/auth/done?x=1", true},
+		{"https://APP.example.com/auth", true},
+		{"tap2wifi://auth", true},
+		{"tap2wifi://auth/callback", true},
+
+		{"https://app.example.com.evil/auth", false},
+		{"https://app.example.com@evil.example/auth", false},
+		{"https://user@app.example.com/auth", false},
+		{"https://app.example.com/authx", false},
+		{"https://app.example.com/auth/../admin", false},
+		{"https://app.example.com/", false},
+		{"http://app.example.com/auth", false},
+		{"https://app.example.com:8443/auth", false},
+		{"https://app.example.com/auth#frag", false},
+		{"//app.example.com/auth", false},
+		{"/auth", false},
+		{"tap2wifi://auth.evil", false},
+		{"tap2wifi:auth", false},
+	}
+	for _, tt := range tests {
+		if got := h.redirectAllowed(tt.target); got != tt.want {
+			t.Errorf("redirectAllowed(%q) = %v, want %v", tt.target, got, tt.want)
+		}
+	}
+}
+
+func loginHandlers() *Handlers {
+	return &Handlers{
+		Cfg: &config.Config{
+			OAuthRedirectURL: "https://api.example.com/api/auth/civic/callback",
+			AuthSessionTTL:   10 * time.Minute,
+		},
+		CivicOAuth: &oauth2.Config{
+			ClientID:    "client",
+			Endpoint:    oauth2.Endpoint{AuthURL: "https://auth.example.com/oauth", TokenURL: "https://auth.example.com/oauth/token"},
+			RedirectURL: "https://api.example.com/api/auth/civic/callback",
+		},
+		AuthSessions: store.NewMemoryAuthSessionStore(),
+	}
+}
+
+func TestCivicAuthSetsStateCookie(t *testing.T) {
+	h := loginHandlers()
+	rec := httptest.NewRecorder()
+	h.CivicAuth(rec, httptest.NewRequest(http.MethodGet, "/api/auth/civic", nil), nil)
+	if rec.Code != http.StatusFound {
+		t.Fatalf("status = %d, want 302", rec.Code)
+	}
+	loc, err := url.Parse(rec.Header().Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	state := loc.Query().Get("state")
+
+	var cookie *http.Cookie
+	for _, c := range rec.Result().Cookies() {
+		if c.Name == loginStateCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil {
+		t.Fatal("no state cookie set")
+	}
+	if cookie.Value != state {
+		t.Errorf("cookie value = %q, want the state %q", cookie.Value, state)
+	}
+	if !cookie.HttpOnly || !cookie.Secure || cookie.SameSite != http.SameSiteLaxMode {
+		t.Errorf("cookie = %+v, want HttpOnly, Secure and SameSite=Lax", cookie)
+	}
+	if cookie.MaxAge <= 0 || cookie.MaxAge > 600 {
+		t.Errorf("cookie MaxAge = %d, want at most the session TTL", cookie.MaxAge)
+	}
+}
+
+func TestCivicCallbackRequiresStateCookie(t *testing.T) {
+	tests := []struct {
+		name   string
+		cookie string
+	}{
+		{"no cookie", ""},
+		{"other browser's state", "someone-else"},
+	}
+	for _, tt := range tests {
+		h := loginHandlers()
+		ctx := context.Background()
+		if err := h.AuthSessions.Create(ctx, &models.AuthSession{
+			State:     "state-1",
+			ExpiresAt: time.Now().Add(time.Minute),
+		}); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/civic/callback?code=c&state=state-1", nil)
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: loginStateCookie, Value: tt.cookie})
+		}
+		rec := httptest.NewRecorder()
+		h.CivicCallback(rec, req, httprouter.Params{})
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", tt.name, rec.Code)
+		}
+		if !strings.Contains(rec.Body.String(), "INVALID_LOGIN_STATE") {
+			t.Errorf("%s: body = %s, want INVALID_LOGIN_STATE", tt.name, rec.Body)
+		}
+	}
+}
+
+// staticVerifier accepts any id_token as the given claims.
+type staticVerifier struct{ claims *auth.Claims }
+
+func (v staticVerifier) Verify(context.Context, string) (*auth.Claims, error) {
+	return v.claims, nil
+}
+
+// civicTokenServer answers code exchanges with an id_token and, unless
+// scope is nil, the granted scopes.
+func civicTokenServer(t *testing.T, scope *string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		resp := map[string]interface{}{"access_token": "civic-access", "token_type": "Bearer", "id_token": "civic-id-token"}
+		if scope != nil {
+			resp["scope"] = *scope
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestAuthUpgradeRecordsLevelOnlyWhenScopesGranted(t *testing.T) {
+	all := "openid profile email verification"
+	partial := "openid profile email"
+	tests := []struct {
+		name  string
+		scope *string
+		want  models.VerificationLevel
+	}{
+		{"granted", &all, models.LevelCivicIDVerified},
+		{"partial", &partial, models.LevelEmailVerified},
+		{"missing", nil, models.LevelEmailVerified},
+	}
+	for _, tt := range tests {
+		h := newTestHandlers(t)
+		h.Cfg.OAuthRedirectURL = "https://api.example.com/api/auth/civic/callback"
+		h.Cfg.AuthSessionTTL = 10 * time.Minute
+		h.Cfg.CivicUpgradeScopes = strings.Fields(all)
+		h.CivicOAuth = &oauth2.Config{
+			ClientID:    "client",
+			Endpoint:    oauth2.Endpoint{AuthURL: "https://auth.example.com/oauth", TokenURL: civicTokenServer(t, tt.scope).URL},
+			RedirectURL: h.Cfg.OAuthRedirectURL,
+		}
+		h.CivicVerifier = staticVerifier{&auth.Claims{Subject: "u1", EmailVerified: true}}
+		ctx := context.Background()
+		if _, err := h.Users.UpsertLogin(ctx, &models.User{ID: "u1", VerificationLevel: models.LevelEmailVerified}); err != nil {
+			t.Fatal(err)
+		}
+
+		rec := serve(h.AuthUpgrade, http.MethodPost, "/api/auth/upgrade", `{"level": "civic_id_verified"}`, "u1")
+		expectStatus(t, rec, http.StatusOK)
+		var started struct {
+			AuthorizationURL string `json:"authorization_url"`
+		}
+		if err := json.NewDecoder(rec.Body).Decode(&started); err != nil {
+			t.Fatal(err)
+		}
+		authURL, err := url.Parse(started.AuthorizationURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := authURL.Query().Get("scope"); got != all {
+			t.Errorf("%s: requested scope = %q, want %q", tt.name, got, all)
+		}
+
+		callback := "/api/auth/civic/callback?code=c&state=" + url.QueryEscape(authURL.Query().Get("state"))
+		rec = serve(h.CivicCallback, http.MethodGet, callback, "", "")
+		expectStatus(t, rec, http.StatusOK)
+
+		user, err := h.Users.FindByID(ctx, "u1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if user.VerificationLevel != tt.want {
+			t.Errorf("%s: level = %v, want %v", tt.name, user.VerificationLevel, tt.want)
+		}
+	}
+}
+
+func TestAuthMeKeepsProfileAcrossNarrowerLogins(t *testing.T) {
+	h := newTestHandlers(t)
+	h.Cfg.OAuthRedirectURL = "https://api.example.com/api/auth/civic/callback"
+	h.CivicOAuth = &oauth2.Config{
+		ClientID:    "client",
+		Endpoint:    oauth2.Endpoint{AuthURL: "https://auth.example.com/oauth", TokenURL: civicTokenServer(t, nil).URL},
+		RedirectURL: h.Cfg.OAuthRedirectURL,
+	}
+	ctx := context.Background()
+	logins := 0
+	login := func(claims *auth.Claims) {
+		t.Helper()
+		h.CivicVerifier = staticVerifier{claims}
+		logins++
+		state := fmt.Sprintf("state-%d", logins)
+		if err := h.AuthSessions.Create(ctx, &models.AuthSession{State: state, ExpiresAt: time.Now().Add(time.Minute)}); err != nil {
+			t.Fatal(err)
+		}
+		req := httptest.NewRequest(http.MethodGet, "/api/auth/civic/callback?code=c&state="+state, nil)
+		req.AddCookie(&http.Cookie{Name: loginStateCookie, Value: state})
+		rec := httptest.NewRecorder()
+		h.CivicCallback(rec, req, httprouter.Params{})
+		expectStatus(t, rec, http.StatusOK)
+	}
+	me := func() models.User {
+		t.Helper()
+		rec := serve(h.AuthMe, http.MethodGet, "/api/auth/me", "", "u1")
+		expectStatus(t, rec, http.StatusOK)
+		var u models.User
+		if err := json.NewDecoder(rec.Body).Decode(&u); err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+
+	login(&auth.Claims{Subject: "u1", Email: "ada@example.com", Name: "Ada", EmailVerified: true})
+	if u := me(); u.Email != "ada@example.com" || u.Name != "Ada" || u.VerificationLevel != models.LevelEmailVerified {
+		t.Fatalf("after the first login: %+v", u)
+	}
+
+	// A login without the email and profile claims keeps what is on record
+	login(&auth.Claims{Subject: "u1"})
+	if u := me(); u.Email != "ada@example.com" || u.Name != "Ada" || u.VerificationLevel != models.LevelEmailVerified {
+		t.Errorf("after a login without profile claims: %+v", u)
+	}
+
+	// and one that carries them refreshes it
+	login(&auth.Claims{Subject: "u1", Email: "ada@example.org", Name: "Ada L."})
+	if u := me(); u.Email != "ada@example.org" || u.Name != "Ada L." {
+		t.Errorf("after a login with new profile claims: %+v", u)
+	}
+
+	if rec := serve(h.AuthMe, http.MethodGet, "/api/auth/me", "", "nobody"); rec.Code != http.StatusNotFound {
+		t.Errorf("unknown user: status = %d, want 404", rec.Code)
+	}
+}
@@ -0,0 +1,161 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/secrets"
+	"wifi-go-backend/internal/store"
+	"wifi-go-backend/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"github.com/skip2/go-qrcode"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// issueConnectTicket answers WiFiConnect in ticket mode: instead of the
+// password the caller gets a signed, single-use ticket to redeem for the
+// network's provisioning payload. issuance carries the request's details
+// and is completed and recorded here.
+func (h *Handlers) issueConnectTicket(w http.ResponseWriter, r *http.Request, wifi *models.WiFi, issuance *models.ConnectIssuance) {
+	ticket, claims, err := h.Tickets.Issue(issuance.UserID, wifi.ID.Hex())
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to issue connect ticket")
+		return
+	}
+	expiresAt := time.Unix(claims.ExpiresAt, 0).UTC()
+	issuance.ID = claims.ID
+	issuance.Mode = models.ConnectModeTicket
+	issuance.IssuedAt = time.Unix(claims.IssuedAt, 0).UTC()
+	issuance.ExpiresAt = expiresAt
+	if err := h.ConnectLog.Record(r.Context(), issuance); err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to record connect ticket")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ticket":     ticket,
+		"expires_at": expiresAt,
+		"ssid":       wifi.SSID,
+	})
+}
+
+// ConnectTicketRedeem handles POST /api/wifi/connect/ticket/redeem
+// Expects JSON body: { "ticket": "...", "format": "json" }
+// The ticket is the credential, so it travels in the body rather than a URL
+// that proxies and browser history keep. Returns { "ssid": ...,
+// "payload": "WIFI:T:WPA;S:...;P:...;;", "qr_png": <base64 PNG> }, the bare
+// payload with "format": "text", or only the QR code PNG with "png". A
+// ticket redeems once, so the JSON carries both forms.
+func (h *Handlers) ConnectTicketRedeem(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req struct {
+		Ticket string `json:"ticket"`
+		Format string `json:"format"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.InvalidBody, "Invalid request body")
+		return
+	}
+	var errs []problem.FieldError
+	if req.Ticket == "" {
+		errs = append(errs, problem.FieldError{Field: "ticket", Message: "is required"})
+	}
+	if req.Format != "" && req.Format != "json" && req.Format != "text" && req.Format != "png" {
+		errs = append(errs, problem.FieldError{Field: "format", Message: "must be json, text or png"})
+	}
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs...)
+		return
+	}
+
+	ctx := r.Context()
+	claims, err := h.Tickets.Verify(ctx, req.Ticket)
+	if err != nil {
+		problem.Write(w, r, problem.TicketInvalid, "Invalid or expired ticket")
+		return
+	}
+	_, err = h.ConnectLog.Redeem(ctx, claims.ID)
+	if errors.Is(err, store.ErrAlreadyRedeemed) {
+		problem.Write(w, r, problem.TicketUsed, "Ticket already used")
+		return
+	}
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.TicketInvalid, "Invalid or expired ticket")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to redeem ticket")
+		return
+	}
+
+	objID, err := primitive.ObjectIDFromHex(claims.WiFiID)
+	if err != nil {
+		problem.Write(w, r, problem.TicketInvalid, "Invalid or expired ticket")
+		return
+	}
+	wifi, err := h.WiFi.FindByID(ctx, objID)
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.WiFiNotFound, "WiFi not found")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load WiFi")
+		return
+	}
+	// Redemption needs no login, so the ticket's subject is the actor
+	if err := h.Audit.Append(ctx, &models.AuditEvent{
+		Actor:     claims.Subject,
+		Action:    models.AuditTicketRedeem,
+		WiFiID:    wifi.ID,
+		IP:        utils.ClientIP(r),
+		UserAgent: r.UserAgent(),
+		Timestamp: time.Now().UTC(),
+	}); err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to write audit event")
+		return
+	}
+
+	password, err := secrets.DecryptWiFiPassword(ctx, h.PasswordKeys, wifi)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to decrypt WiFi password")
+		return
+	}
+	payload := utils.WiFiQRPayload(wifi.SSID, password, wifi.QRSecurity(), wifi.Hidden)
+
+	var contentType string
+	var body []byte
+	if req.Format == "text" {
+		contentType = "text/plain; charset=utf-8"
+		body = []byte(payload)
+	} else {
+		png, err := qrcode.Encode(payload, qrcode.Medium, 256)
+		if err != nil {
+			problem.Write(w, r, problem.Internal, "Failed to render QR code")
+			return
+		}
+		if req.Format == "png" {
+			contentType = "image/png"
+			body = png
+		} else {
+			contentType = "application/json"
+			body, _ = json.Marshal(map[string]interface{}{
+				"ssid":    wifi.SSID,
+				"payload": payload,
+				"qr_png":  png,
+			})
+		}
+	}
+
+	// A ticket connect counts once its payload is handed out, not on issue
+	h.recordStatsFor(r, claims.Subject, connectStats(wifi))
+
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
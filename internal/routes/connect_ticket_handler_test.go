@@ -0,0 +1,103 @@
+package routes
+
+import (
+	"bytes"
+	"encoding/json"
+	"image/png"
+	"net/http"
+	"testing"
+)
+
+// issueTestTicket connects to a new network in ticket mode as u1.
+func issueTestTicket(t *testing.T, h *Handlers, ssid, password string) string {
+	t.Helper()
+	wifi := addTestWiFi(t, h, ssid, password, 13.4, 52.5)
+	body := `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": 52.5, "longitude": 13.4, "mode": "ticket"}`
+	rec := serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", body, "u1")
+	expectStatus(t, rec, http.StatusCreated)
+	var issued map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&issued); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := issued["qr_url"]; ok {
+		t.Error("issuance links to a second redemption")
+	}
+	ticket, _ := issued["ticket"].(string)
+	return ticket
+}
+
+func TestConnectTicketRedeemReturnsPayloadAndQR(t *testing.T) {
+	h := newTestHandlers(t)
+	ticket := issueTestTicket(t, h, "cafe;1", "pa:ss")
+
+	rec := serve(h.ConnectTicketRedeem, http.MethodPost, "/api/wifi/connect/ticket/redeem", `{"ticket": "`+ticket+`"}`, "")
+	expectStatus(t, rec, http.StatusOK)
+	if cc := rec.Header().Get("Cache-Control"); cc != "no-store" {
+		t.Errorf("Cache-Control = %q, want no-store", cc)
+	}
+	var got struct {
+		SSID    string `json:"ssid"`
+		Payload string `json:"payload"`
+		QRPNG   []byte `json:"qr_png"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got.SSID != "cafe;1" || got.Payload != `WIFI:T:WPA;S:cafe\;1;P:pa\:ss;;` {
+		t.Errorf("redeemed %q with payload %q", got.SSID, got.Payload)
+	}
+	if _, err := png.Decode(bytes.NewReader(got.QRPNG)); err != nil {
+		t.Errorf("qr_png is not a PNG: %v", err)
+	}
+
+	// Single use, whatever the format
+	rec = serve(h.ConnectTicketRedeem, http.MethodPost, "/api/wifi/connect/ticket/redeem", `{"ticket": "`+ticket+`", "format": "png"}`, "")
+	expectStatus(t, rec, http.StatusGone)
+}
+
+func TestConnectTicketRedeemFormats(t *testing.T) {
+	h := newTestHandlers(t)
+	tests := []struct {
+		format      string
+		contentType string
+	}{
+		{"text", "text/plain; charset=utf-8"},
+		{"png", "image/png"},
+		{"json", "application/json"},
+	}
+	for _, tt := range tests {
+		ticket := issueTestTicket(t, h, "cafe-"+tt.format, "secret")
+		body := `{"ticket": "` + ticket + `", "format": "` + tt.format + `"}`
+		rec := serve(h.ConnectTicketRedeem, http.MethodPost, "/api/wifi/connect/ticket/redeem", body, "")
+		expectStatus(t, rec, http.StatusOK)
+		if ct := rec.Header().Get("Content-Type"); ct != tt.contentType {
+			t.Errorf("%s: Content-Type = %q, want %q", tt.format, ct, tt.contentType)
+		}
+	}
+
+	ticket := issueTestTicket(t, h, "plain", "secret")
+	rec := serve(h.ConnectTicketRedeem, http.MethodPost, "/api/wifi/connect/ticket/redeem", `{"ticket": "`+ticket+`", "format": "text"}`, "")
+	if got := rec.Body.String(); got != "WIFI:T:WPA;S:plain;P:secret;;" {
+		t.Errorf("text payload = %q", got)
+	}
+}
+
+func TestConnectTicketRedeemRefusals(t *testing.T) {
+	h := newTestHandlers(t)
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"no body", ``, http.StatusBadRequest},
+		{"no ticket", `{}`, http.StatusBadRequest},
+		{"bad format", `{"ticket": "x", "format": "gif"}`, http.StatusBadRequest},
+		{"forged ticket", `{"ticket": "x.y.z"}`, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		rec := serve(h.ConnectTicketRedeem, http.MethodPost, "/api/wifi/connect/ticket/redeem", tt.body, "")
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d; body: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
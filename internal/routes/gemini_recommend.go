@@ -6,6 +6,9 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
+
+	"wifi-go-backend/internal/metrics"
 
 	"github.com/google/generative-ai-go/genai"
 	"google.golang.org/api/option"
@@ -35,10 +38,11 @@ type RecommendationResponse struct {
 // LocationRecommender handles AI-powered location recommendations
 type LocationRecommender struct {
 	client *genai.Client
+	model  string
 }
 
 // NewLocationRecommender creates a new instance of LocationRecommender
-func NewLocationRecommender(apiKey string) (*LocationRecommender, error) {
+func NewLocationRecommender(apiKey, model string) (*LocationRecommender, error) {
 	ctx := context.Background()
 	client, err := genai.NewClient(ctx, option.WithAPIKey(apiKey))
 	if err != nil {
@@ -47,12 +51,13 @@ func NewLocationRecommender(apiKey string) (*LocationRecommender, error) {
 
 	return &LocationRecommender{
 		client: client,
+		model:  model,
 	}, nil
 }
 
 // FindStops uses Gemini AI to find recommended stops between two coordinates
 func (lr *LocationRecommender) FindStops(ctx context.Context, req RecommendationRequest) (*RecommendationResponse, error) {
-	model := lr.client.GenerativeModel("gemini-1.5-flash")
+	model := lr.client.GenerativeModel(lr.model)
 
 	// Configure the model for structured output
 	model.SetTemperature(0.7)
@@ -93,12 +98,14 @@ Important guidelines:
 		req.StopType, req.MaxStops, req.StopType, req.MaxStops)
 
 	// Generate content using Gemini
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	const op = "find_stops"
+	resp, err := generate(ctx, op, model, prompt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+		metrics.GeminiParseFailures.WithLabelValues(op, "empty").Inc()
 		return nil, fmt.Errorf("no response generated")
 	}
 
@@ -118,6 +125,7 @@ Important guidelines:
 	jsonEnd := strings.LastIndex(responseText, "}") + 1
 
 	if jsonStart == -1 || jsonEnd <= jsonStart {
+		metrics.GeminiParseFailures.WithLabelValues(op, "no_json").Inc()
 		return nil, fmt.Errorf("no valid JSON found in response")
 	}
 
@@ -126,6 +134,7 @@ Important guidelines:
 	// Parse the JSON response
 	var recommendation RecommendationResponse
 	if err := json.Unmarshal([]byte(jsonContent), &recommendation); err != nil {
+		metrics.GeminiParseFailures.WithLabelValues(op, "invalid_json").Inc()
 		return nil, fmt.Errorf("failed to parse JSON response: %w", err)
 	}
 
@@ -134,7 +143,7 @@ Important guidelines:
 
 // FindStopsBetween returns 5 recommended stops as JSON between two coordinates
 func (lr *LocationRecommender) FindStopsBetween(ctx context.Context, startLat, startLng, endLat, endLng float64) ([]byte, error) {
-	model := lr.client.GenerativeModel("gemini-1.5-flash")
+	model := lr.client.GenerativeModel(lr.model)
 	model.SetTemperature(0.7)
 
 	prompt := fmt.Sprintf(`You are a travel recommendation AI. Given two coordinates, find 5 interesting stops along or near the route.
@@ -168,12 +177,14 @@ Important guidelines:
 - Focus on popular, well-known locations`,
 		startLat, startLng, endLat, endLng)
 
-	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	const op = "find_stops_between"
+	resp, err := generate(ctx, op, model, prompt)
 	if err != nil {
 		return nil, fmt.Errorf("failed to generate content: %w", err)
 	}
 
 	if len(resp.Candidates) == 0 || len(resp.Candidates[0].Content.Parts) == 0 {
+		metrics.GeminiParseFailures.WithLabelValues(op, "empty").Inc()
 		return nil, fmt.Errorf("no response generated")
 	}
 
@@ -187,12 +198,35 @@ Important guidelines:
 	jsonStart := strings.Index(responseText, "{")
 	jsonEnd := strings.LastIndex(responseText, "}") + 1
 	if jsonStart == -1 || jsonEnd <= jsonStart {
+		metrics.GeminiParseFailures.WithLabelValues(op, "no_json").Inc()
 		return nil, fmt.Errorf("no valid JSON found in response")
 	}
 	jsonContent := responseText[jsonStart:jsonEnd]
+	if !json.Valid([]byte(jsonContent)) {
+		metrics.GeminiParseFailures.WithLabelValues(op, "invalid_json").Inc()
+		return nil, fmt.Errorf("invalid JSON in response")
+	}
 	return []byte(jsonContent), nil
 }
 
+// generate calls Gemini and records the call's outcome, latency and token
+// usage under op.
+func generate(ctx context.Context, op string, model *genai.GenerativeModel, prompt string) (*genai.GenerateContentResponse, error) {
+	start := time.Now()
+	resp, err := model.GenerateContent(ctx, genai.Text(prompt))
+	metrics.GeminiDuration.WithLabelValues(op).Observe(time.Since(start).Seconds())
+	if err != nil {
+		metrics.GeminiRequests.WithLabelValues(op, "error").Inc()
+		return nil, err
+	}
+	metrics.GeminiRequests.WithLabelValues(op, "ok").Inc()
+	if u := resp.UsageMetadata; u != nil {
+		metrics.GeminiTokens.WithLabelValues(op, "prompt").Add(float64(u.PromptTokenCount))
+		metrics.GeminiTokens.WithLabelValues(op, "candidates").Add(float64(u.CandidatesTokenCount))
+	}
+	return resp, nil
+}
+
 // Close closes the client connection
 func (lr *LocationRecommender) Close() {
 	if lr.client != nil {
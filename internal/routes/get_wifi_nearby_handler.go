@@ -2,12 +2,18 @@ package routes
 
 import (
 	"encoding/json"
-	"math"
+	"errors"
+	"fmt"
 	"net/http"
-	"strconv"
+	"time"
 
-	"wifi-go-backend/internal/db"
+	"wifi-go-backend/internal/auth"
 	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/risk"
+	"wifi-go-backend/internal/secrets"
+	"wifi-go-backend/internal/store"
+	"wifi-go-backend/internal/utils"
 
 	"github.com/julienschmidt/httprouter"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -19,132 +25,269 @@ func (h *Handlers) WiFiConnect(w http.ResponseWriter, r *http.Request, _ httprou
 		WiFiID    string  `json:"wifi_id"`
 		Latitude  float64 `json:"latitude"`
 		Longitude float64 `json:"longitude"`
+		// Accuracy is the fix's reported accuracy radius in meters
+		Accuracy float64 `json:"accuracy"`
+		// VisibleBSSIDs is the client's current WiFi scan list
+		VisibleBSSIDs []string `json:"visible_bssids"`
+		// Mode is "password" (default) or "ticket"
+		Mode string `json:"mode"`
 	}
 	var req ConnectRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid request body"))
+		problem.Write(w, r, problem.InvalidBody, "Invalid request body")
 		return
 	}
 
 	if req.WiFiID == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("wifi_id is required"))
+		problem.Validation(w, r, problem.FieldError{Field: "wifi_id", Message: "is required"})
 		return
 	}
-
-	coll, err := db.GetWiFiCollection()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Database connection error"))
+	if req.Mode == "" {
+		req.Mode = models.ConnectModePassword
+	}
+	if req.Mode != models.ConnectModePassword && req.Mode != models.ConnectModeTicket {
+		problem.Validation(w, r, problem.FieldError{Field: "mode", Message: "must be password or ticket"})
+		return
+	}
+	if errs := latLngErrors("latitude", "longitude", req.Latitude, req.Longitude); len(errs) > 0 {
+		problem.Validation(w, r, errs...)
+		return
+	}
+	if req.Accuracy < 0 {
+		problem.Validation(w, r, problem.FieldError{Field: "accuracy", Message: "must not be negative"})
+		return
+	}
+	scan, errs := normalizeBSSIDs("visible_bssids", req.VisibleBSSIDs, maxScanBSSIDs)
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs...)
 		return
 	}
 
 	// Find WiFi by ID
-	var wifi models.WiFi
 	objID, err := primitive.ObjectIDFromHex(req.WiFiID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid wifi_id"))
+		problem.Write(w, r, problem.InvalidWiFiID, "Invalid wifi_id")
+		return
+	}
+	wifi, err := h.WiFi.FindByID(r.Context(), objID)
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.WiFiNotFound, "WiFi not found")
 		return
 	}
-	err = coll.FindOne(r.Context(), map[string]interface{}{"_id": objID}).Decode(&wifi)
 	if err != nil {
-		w.WriteHeader(http.StatusNotFound)
-		w.Write([]byte("WiFi not found"))
+		problem.Write(w, r, problem.Internal, "Failed to load WiFi")
 		return
 	}
 
 	// Check if the provided location is within 100 meters of the WiFi
 	if len(wifi.Location.Coordinates) != 2 {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("WiFi location data invalid"))
+		problem.Write(w, r, problem.Internal, "WiFi location data invalid")
 		return
 	}
 	wifiLng := wifi.Location.Coordinates[0]
 	wifiLat := wifi.Location.Coordinates[1]
-	dist := haversine(req.Latitude, req.Longitude, wifiLat, wifiLng)
-	if dist > 0.1 {
-		w.WriteHeader(http.StatusForbidden)
-		w.Write([]byte("You are too far from this WiFi to connect"))
+	nearby := utils.Haversine(req.Latitude, req.Longitude, wifiLat, wifiLng) <= 0.1
+
+	// A scan list that overlaps the network's fingerprint is further proof
+	// of presence; WIFI_CONNECT_PRESENCE decides how it combines with the
+	// distance check
+	scanned := len(scan) > 0 && wifi.HasFingerprint() && risk.MatchScan(wifi, scan).Present()
+	switch h.Cfg.ConnectPresence {
+	case "either":
+		if !nearby && !scanned {
+			problem.Write(w, r, problem.TooFar, "You are too far from this WiFi to connect")
+			return
+		}
+	case "both":
+		if !nearby {
+			problem.Write(w, r, problem.TooFar, "You are too far from this WiFi to connect")
+			return
+		}
+		if wifi.HasFingerprint() && !scanned {
+			problem.Write(w, r, problem.ScanMismatch, "Your WiFi scan does not match this network's surroundings")
+			return
+		}
+	default:
+		if !nearby {
+			problem.Write(w, r, problem.TooFar, "You are too far from this WiFi to connect")
+			return
+		}
+	}
+
+	issuance := &models.ConnectIssuance{
+		UserID:      auth.SubjectFromContext(r.Context()),
+		WiFiID:      wifi.ID,
+		IP:          utils.ClientIP(r),
+		Coordinates: []float64{req.Longitude, req.Latitude},
+		AccuracyM:   req.Accuracy,
+		ScanMatched: scanned,
+	}
+	if h.Risk != nil {
+		report := risk.Report{Latitude: req.Latitude, Longitude: req.Longitude, AccuracyM: req.Accuracy}
+		if !h.checkLocationRisk(w, r, wifi, report, issuance) {
+			return
+		}
+	}
+
+	// Reveals are only allowed once they are on the audit record
+	action := models.AuditPasswordReveal
+	if req.Mode == models.ConnectModeTicket {
+		action = models.AuditTicketIssue
+	}
+	if err := h.audit(r, action, wifi.ID, []float64{req.Longitude, req.Latitude}); err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to write audit event")
+		return
+	}
+
+	if req.Mode == models.ConnectModeTicket {
+		h.issueConnectTicket(w, r, wifi, issuance)
+		return
+	}
+
+	// Passwords are only decrypted here and when a connect ticket is redeemed
+	password, err := secrets.DecryptWiFiPassword(r.Context(), h.PasswordKeys, wifi)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to decrypt WiFi password")
+		return
+	}
+
+	issuance.ID = primitive.NewObjectID().Hex()
+	issuance.Mode = models.ConnectModePassword
+	issuance.IssuedAt = time.Now().UTC()
+	if err := h.ConnectLog.Record(r.Context(), issuance); err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to record connect")
 		return
 	}
+	h.recordStats(r, connectStats(wifi))
 
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"ssid":        wifi.SSID,
-		"password":    wifi.Password,
+		"password":    password,
 		"location":    wifi.Location,
 		"description": wifi.Description,
 	})
 }
 
-func (h *Handlers) WiFiNearby(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// Parse query params for latitude and longitude
-	latStr := r.URL.Query().Get("latitude")
-	lngStr := r.URL.Query().Get("longitude")
-	if latStr == "" || lngStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("latitude and longitude query parameters are required"))
-		return
+// checkLocationRisk scores the reported position against the user's
+// recent connects and records the assessment on issuance. It writes the
+// response and returns false when the connect must not go ahead.
+func (h *Handlers) checkLocationRisk(w http.ResponseWriter, r *http.Request, wifi *models.WiFi, report risk.Report, issuance *models.ConnectIssuance) bool {
+	ctx := r.Context()
+	now := time.Now().UTC()
+	history, err := h.ConnectLog.Since(ctx, issuance.UserID, now.Add(-h.Risk.Lookback()))
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load connect history")
+		return false
 	}
-	lat, err1 := strconv.ParseFloat(latStr, 64)
-	lng, err2 := strconv.ParseFloat(lngStr, 64)
-	if err1 != nil || err2 != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid latitude or longitude"))
-		return
+	assessment := h.Risk.Assess(now, report, wifi.ID, wifi.Location.Coordinates, history)
+	issuance.Risk = assessment
+
+	level := models.LevelAnonymous
+	if h.Risk.StepUpScore > 0 && assessment.Score >= h.Risk.StepUpScore {
+		user, err := h.Users.FindByID(ctx, issuance.UserID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			problem.Write(w, r, problem.Internal, "Failed to load user")
+			return false
+		}
+		if user != nil {
+			level = user.VerificationLevel
+		}
+	}
+	action := h.Risk.Action(assessment, level)
+	if action == risk.Allow {
+		return true
 	}
 
-	coll, err := db.GetWiFiCollection()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Database connection error"))
+	h.logger(r).Warn("connect refused on location risk",
+		"wifi_id", wifi.ID.Hex(), "score", assessment.Score, "reasons", assessment.Reasons)
+	// Refusals stay in the history Assess reads, so probing networks one
+	// after another still adds up
+	issuance.ID = primitive.NewObjectID().Hex()
+	issuance.Mode = models.ConnectModeDenied
+	issuance.IssuedAt = now
+	if err := h.ConnectLog.Record(ctx, issuance); err != nil {
+		h.logger(r).Error("failed to record refused connect", "err", err, "wifi_id", wifi.ID.Hex())
+	}
+	if err := h.Audit.Append(ctx, &models.AuditEvent{
+		Actor:       issuance.UserID,
+		Action:      models.AuditConnectDenied,
+		WiFiID:      wifi.ID,
+		Coordinates: issuance.Coordinates,
+		IP:          issuance.IP,
+		UserAgent:   r.UserAgent(),
+		Risk:        assessment,
+		Timestamp:   now,
+	}); err != nil {
+		h.logger(r).Error("failed to write audit event", "err", err, "wifi_id", wifi.ID.Hex())
+	}
+	// The reasons stay server-side so they cannot be used to tune a spoofer
+	if action == risk.StepUp {
+		problem.Write(w, r, problem.InsufficientLevel,
+			"Verification level "+h.Risk.StepUpLevel.String()+" required to connect from this location")
+	} else {
+		problem.Write(w, r, problem.LocationDoubtful, "Your location could not be verified")
+	}
+	return false
+}
+
+// wifiSummary is how a network appears in listings. The password and the
+// neighbouring BSSIDs are never included.
+func wifiSummary(wifi *models.WiFi) map[string]interface{} {
+	item := map[string]interface{}{
+		"id":             wifi.ID,
+		"ssid":           wifi.SSID,
+		"location":       wifi.Location,
+		"description":    wifi.Description,
+		"captive_portal": wifi.CaptivePortal,
+		"hidden":         wifi.Hidden,
+	}
+	if len(wifi.BSSIDs) > 0 {
+		item["bssids"] = wifi.BSSIDs
+	}
+	if wifi.Security != "" {
+		item["security"] = wifi.Security
+	}
+	if wifi.Band != "" {
+		item["band"] = wifi.Band
+	}
+	if wifi.Channel != 0 {
+		item["channel"] = wifi.Channel
+	}
+	if wifi.RSSI != 0 {
+		item["rssi"] = wifi.RSSI
+	}
+	return item
+}
+
+func (h *Handlers) WiFiNearby(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	// Parse query params for latitude and longitude
+	coords, errs := floatParams(r, "latitude", "longitude")
+	errs = append(errs, latLngErrors("latitude", "longitude", coords[0], coords[1])...)
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs...)
 		return
 	}
+	lat, lng := coords[0], coords[1]
 
-	// 1km radius in degrees (approx)
 	const radiusKm = 1.0
-	const earthRadiusKm = 6371.0
-	// MongoDB $geoWithin with $centerSphere expects [lng, lat]
-	filter := map[string]interface{}{
-		"location": map[string]interface{}{
-			"$geoWithin": map[string]interface{}{
-				"$centerSphere": []interface{}{
-					[]float64{lng, lat},
-					radiusKm / earthRadiusKm,
-				},
-			},
-		},
-	}
-	cur, err := coll.Find(r.Context(), filter)
+	wifis, err := h.WiFi.FindWithin(r.Context(), lng, lat, radiusKm)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to search WiFi"))
+		problem.Write(w, r, problem.Internal, "Failed to search WiFi")
 		return
 	}
-	defer cur.Close(r.Context())
 
 	var results []map[string]interface{}
-	for cur.Next(r.Context()) {
-		var wifi models.WiFi
-		if err := cur.Decode(&wifi); err != nil {
-			continue
-		}
+	for _, wifi := range wifis {
 		// Coordinates: [lng, lat]
 		var wifiLat, wifiLng float64
 		if len(wifi.Location.Coordinates) == 2 {
 			wifiLng = wifi.Location.Coordinates[0]
 			wifiLat = wifi.Location.Coordinates[1]
 		}
-		dist := haversine(lat, lng, wifiLat, wifiLng)
-		results = append(results, map[string]interface{}{
-			"id":          wifi.ID, // Add the ID field
-			"ssid":        wifi.SSID,
-			"location":    wifi.Location,
-			"distance":    dist,
-			"description": wifi.Description,
-		})
+		item := wifiSummary(&wifi)
+		item["distance"] = utils.Haversine(lat, lng, wifiLat, wifiLng)
+		results = append(results, item)
 	}
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
@@ -162,8 +305,16 @@ func (h *Handlers) NearbyWiFiForStopsHandler(w http.ResponseWriter, r *http.Requ
 		} `json:"stops"`
 	}
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid JSON body"))
+		problem.Write(w, r, problem.InvalidBody, "Invalid JSON body")
+		return
+	}
+	var errs []problem.FieldError
+	for i, stop := range req.Stops {
+		field := fmt.Sprintf("stops[%d].", i)
+		errs = append(errs, latLngErrors(field+"latitude", field+"longitude", stop.Latitude, stop.Longitude)...)
+	}
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs...)
 		return
 	}
 
@@ -174,39 +325,15 @@ func (h *Handlers) NearbyWiFiForStopsHandler(w http.ResponseWriter, r *http.Requ
 	var results []WiFiWithStop
 	ctx := r.Context()
 	for _, stop := range req.Stops {
-		coll, err := db.GetWiFiCollection()
-		if err != nil {
-			continue
-		}
 		const radiusKm = 1.0
-		const earthRadiusKm = 6371.0
-		filter := map[string]interface{}{
-			"location": map[string]interface{}{
-				"$geoWithin": map[string]interface{}{
-					"$centerSphere": []interface{}{
-						[]float64{stop.Longitude, stop.Latitude},
-						radiusKm / earthRadiusKm,
-					},
-				},
-			},
-		}
-		cur, err := coll.Find(ctx, filter)
+		found, err := h.WiFi.FindWithin(ctx, stop.Longitude, stop.Latitude, radiusKm)
 		if err != nil {
 			continue
 		}
 		var wifis []map[string]interface{}
-		for cur.Next(ctx) {
-			var wifi models.WiFi
-			if err := cur.Decode(&wifi); err == nil {
-				wifis = append(wifis, map[string]interface{}{
-					"id":          wifi.ID,
-					"ssid":        wifi.SSID,
-					"location":    wifi.Location,
-					"description": wifi.Description,
-				})
-			}
+		for i := range found {
+			wifis = append(wifis, wifiSummary(&found[i]))
 		}
-		cur.Close(ctx)
 		results = append(results, WiFiWithStop{
 			Stop: map[string]interface{}{
 				"latitude":  stop.Latitude,
@@ -219,15 +346,3 @@ func (h *Handlers) NearbyWiFiForStopsHandler(w http.ResponseWriter, r *http.Requ
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(results)
 }
-
-// Haversine formula to calculate distance between two lat/lng points in km
-func haversine(lat1, lng1, lat2, lng2 float64) float64 {
-	const R = 6371 // Earth radius in km
-	dLat := (lat2 - lat1) * math.Pi / 180.0
-	dLng := (lng2 - lng1) * math.Pi / 180.0
-	lat1R := lat1 * math.Pi / 180.0
-	lat2R := lat2 * math.Pi / 180.0
-	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Sin(dLng/2)*math.Sin(dLng/2)*math.Cos(lat1R)*math.Cos(lat2R)
-	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
-	return R * c
-}
@@ -0,0 +1,262 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/risk"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestWiFiConnectRevealsPasswordNearby(t *testing.T) {
+	h := newTestHandlers(t)
+	wifi := addTestWiFi(t, h, "cafe", "secret", 13.4, 52.5)
+
+	body := `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": 52.5003, "longitude": 13.4}`
+	rec := serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", body, "u1")
+	expectStatus(t, rec, http.StatusOK)
+	var resp struct {
+		SSID     string `json:"ssid"`
+		Password string `json:"password"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.SSID != "cafe" || resp.Password != "secret" {
+		t.Errorf("connect = %+v, want cafe/secret", resp)
+	}
+}
+
+func TestWiFiConnectRefusals(t *testing.T) {
+	h := newTestHandlers(t)
+	wifi := addTestWiFi(t, h, "cafe", "secret", 13.4, 52.5)
+	id := wifi.ID.Hex()
+
+	tests := []struct {
+		name string
+		body string
+		want int
+	}{
+		{"too far", `{"wifi_id": "` + id + `", "latitude": 52.502, "longitude": 13.4}`, http.StatusForbidden},
+		{"no wifi_id", `{"latitude": 52.5, "longitude": 13.4}`, http.StatusBadRequest},
+		{"bad wifi_id", `{"wifi_id": "nope", "latitude": 52.5, "longitude": 13.4}`, http.StatusBadRequest},
+		{"unknown wifi", `{"wifi_id": "000000000000000000000000", "latitude": 52.5, "longitude": 13.4}`, http.StatusNotFound},
+		{"bad mode", `{"wifi_id": "` + id + `", "latitude": 52.5, "longitude": 13.4, "mode": "qr"}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		rec := serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", tt.body, "u1")
+		if rec.Code != tt.want {
+			t.Errorf("%s: status = %d, want %d; body: %s", tt.name, rec.Code, tt.want, rec.Body)
+		}
+	}
+}
+
+func TestWiFiConnectAcceptsMatchingScan(t *testing.T) {
+	h := newTestHandlers(t)
+	wifi := addTestWiFi(t, h, "cafe", "secret", 13.4, 52.5)
+	wifi.BSSIDs = []string{"aa:bb:cc:00:00:01"}
+	wifi.NeighborBSSIDs = []string{"aa:bb:cc:00:00:02", "aa:bb:cc:00:00:03", "aa:bb:cc:00:00:04"}
+	if err := h.WiFi.Update(context.Background(), wifi); err != nil {
+		t.Fatal(err)
+	}
+
+	// Far from the stored position, but the scan places the caller there;
+	// the default policy only trusts the distance
+	far := `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": 52.6, "longitude": 13.4`
+	scan := `, "visible_bssids": ["AA:BB:CC:00:00:01", "aa:bb:cc:00:00:02", "aa:bb:cc:00:00:03"]}`
+	expectStatus(t, serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", far+scan, "u1"), http.StatusForbidden)
+
+	h.Cfg.ConnectPresence = "either"
+	expectStatus(t, serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", far+scan, "u1"), http.StatusOK)
+	expectStatus(t, serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", far+`, "visible_bssids": ["aa:bb:cc:00:00:09"]}`, "u1"), http.StatusForbidden)
+
+	h.Cfg.ConnectPresence = "both"
+	near := `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": 52.5, "longitude": 13.4}`
+	rec := serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", near, "u1")
+	expectStatus(t, rec, http.StatusForbidden)
+	if !strings.Contains(rec.Body.String(), "SCAN_MISMATCH") {
+		t.Errorf("body = %s, want SCAN_MISMATCH", rec.Body)
+	}
+}
+
+func TestWiFiNearby(t *testing.T) {
+	h := newTestHandlers(t)
+	near := addTestWiFi(t, h, "near", "secret", 13.4, 52.505)
+	addTestWiFi(t, h, "far", "secret", 13.4, 52.52)
+
+	rec := serve(h.WiFiNearby, http.MethodGet, "/api/wifi/nearby?latitude=52.5&longitude=13.4", "", "")
+	expectStatus(t, rec, http.StatusOK)
+	var got []map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0]["id"] != near.ID.Hex() {
+		t.Fatalf("nearby = %v, want only the network within 1 km", got)
+	}
+	if d, _ := got[0]["distance"].(float64); d < 0.5 || d > 0.6 {
+		t.Errorf("distance = %v km, want about 0.56", got[0]["distance"])
+	}
+	if _, ok := got[0]["password"]; ok {
+		t.Error("nearby listing includes the password")
+	}
+
+	expectStatus(t, serve(h.WiFiNearby, http.MethodGet, "/api/wifi/nearby?latitude=x&longitude=13.4", "", ""), http.StatusBadRequest)
+}
+
+func TestNearbyWiFiForStops(t *testing.T) {
+	h := newTestHandlers(t)
+	berlin := addTestWiFi(t, h, "berlin", "secret", 13.4, 52.5)
+	munich := addTestWiFi(t, h, "munich", "secret", 11.58, 48.14)
+
+	body := `{"stops": [
+		{"name": "Alexanderplatz", "latitude": 52.501, "longitude": 13.4},
+		{"name": "Marienplatz", "latitude": 48.14, "longitude": 11.581},
+		{"name": "Nowhere", "latitude": 0, "longitude": 0}
+	]}`
+	rec := serve(h.NearbyWiFiForStopsHandler, http.MethodPost, "/api/wifi/nearby/stops", body, "")
+	expectStatus(t, rec, http.StatusOK)
+	var got []struct {
+		Stop  map[string]interface{}   `json:"stop"`
+		WiFis []map[string]interface{} `json:"wifis"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d stops, want 3", len(got))
+	}
+	for i, want := range []string{berlin.ID.Hex(), munich.ID.Hex(), ""} {
+		var ids []string
+		for _, w := range got[i].WiFis {
+			ids = append(ids, w["id"].(string))
+		}
+		if want == "" && len(ids) != 0 || want != "" && (len(ids) != 1 || ids[0] != want) {
+			t.Errorf("stop %v: wifis = %v, want [%s]", got[i].Stop["name"], ids, want)
+		}
+	}
+
+	expectStatus(t, serve(h.NearbyWiFiForStopsHandler, http.MethodPost, "/api/wifi/nearby/stops", `{"stops":`, ""), http.StatusBadRequest)
+}
+
+func TestCoordinatesOffTheGlobeAreRejected(t *testing.T) {
+	h := newTestHandlers(t)
+	wifi := addTestWiFi(t, h, "cafe", "secret", 13.4, 52.5)
+	connect := func(lat, lng string) string {
+		return `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": ` + lat + `, "longitude": ` + lng + `}`
+	}
+
+	tests := []struct {
+		name   string
+		handle httprouter.Handle
+		method string
+		target string
+		body   string
+	}{
+		{"connect latitude", h.WiFiConnect, http.MethodPost, "/api/wifi/connect", connect("91", "13.4")},
+		{"connect longitude", h.WiFiConnect, http.MethodPost, "/api/wifi/connect", connect("52.5", "-180.5")},
+		{"nearby latitude", h.WiFiNearby, http.MethodGet, "/api/wifi/nearby?latitude=-100&longitude=13.4", ""},
+		{"nearby longitude", h.WiFiNearby, http.MethodGet, "/api/wifi/nearby?latitude=52.5&longitude=500", ""},
+		{"nearby NaN", h.WiFiNearby, http.MethodGet, "/api/wifi/nearby?latitude=NaN&longitude=13.4", ""},
+		{"saved", h.WiFiSaved, http.MethodGet, "/api/wifi/saved?latitude=52.5&longitude=Inf", ""},
+		{"stops", h.NearbyWiFiForStopsHandler, http.MethodPost, "/api/wifi/nearby/stops",
+			`{"stops": [{"latitude": 52.5, "longitude": 13.4}, {"latitude": 95, "longitude": 13.4}]}`},
+	}
+	for _, tt := range tests {
+		rec := serve(tt.handle, tt.method, tt.target, tt.body, "u1")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400; body: %s", tt.name, rec.Code, rec.Body)
+		}
+	}
+}
+
+func TestWiFiConnectLocationRisk(t *testing.T) {
+	h := newTestHandlers(t)
+	h.Risk = &risk.Engine{
+		MaxSpeedKmh:   900,
+		MaxAccuracyM:  100,
+		History:       24 * time.Hour,
+		NetworkWindow: time.Hour,
+		MaxNetworks:   10,
+		DenyScore:     80,
+		StepUpScore:   50,
+		StepUpLevel:   models.LevelCivicIDVerified,
+	}
+	munich := addTestWiFi(t, h, "munich", "secret", 11.58, 48.14)
+	berlin := addTestWiFi(t, h, "berlin", "secret", 13.4, 52.5)
+	connect := func(wifi *models.WiFi, lat, lng float64, accuracy string, user string) *httptest.ResponseRecorder {
+		body := fmt.Sprintf(`{"wifi_id": %q, "latitude": %v, "longitude": %v%s}`, wifi.ID.Hex(), lat, lng, accuracy)
+		return serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", body, user)
+	}
+	expectProblem := func(rec *httptest.ResponseRecorder, code string) {
+		t.Helper()
+		expectStatus(t, rec, http.StatusForbidden)
+		if !strings.Contains(rec.Body.String(), code) {
+			t.Errorf("body = %s, want %s", rec.Body, code)
+		}
+	}
+
+	expectStatus(t, connect(munich, 48.1403, 11.58, `, "accuracy": 15`, "u1"), http.StatusOK)
+
+	// Berlin minutes after Munich is impossible travel: a step-up
+	expectProblem(connect(berlin, 52.5003, 13.4, `, "accuracy": 15`, "u1"), "INSUFFICIENT_LEVEL")
+	if _, err := h.Users.UpsertLogin(context.Background(), &models.User{ID: "u1"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := h.Users.RaiseLevel(context.Background(), "u1", models.LevelCivicIDVerified); err != nil {
+		t.Fatal(err)
+	}
+	expectStatus(t, connect(berlin, 52.5003, 13.4, `, "accuracy": 15`, "u1"), http.StatusOK)
+
+	// The network's exact coordinates without an accuracy on top of the
+	// jump are refused whatever the level
+	expectProblem(connect(munich, 48.14, 11.58, "", "u1"), "LOCATION_DOUBTFUL")
+
+	history, err := h.ConnectLog.Since(context.Background(), "u1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var modes []string
+	for _, e := range history {
+		modes = append(modes, e.Mode)
+	}
+	want := []string{models.ConnectModeDenied, models.ConnectModePassword, models.ConnectModeDenied, models.ConnectModePassword}
+	if !reflect.DeepEqual(modes, want) {
+		t.Errorf("connect history = %v, want %v (newest first)", modes, want)
+	}
+	if n := successfulConnects(t, h, "u1"); n != 2 {
+		t.Errorf("successful connects = %d, want 2", n)
+	}
+}
+
+func TestWiFiConnectRefusalsCountTowardsManyNetworks(t *testing.T) {
+	h := newTestHandlers(t)
+	// A second network within the window steps up, and the refusal lands
+	// in the history later assessments read
+	h.Risk = &risk.Engine{History: time.Hour, NetworkWindow: time.Hour, MaxNetworks: 1, MaxAccuracyM: 100, DenyScore: 60, StepUpScore: 30, StepUpLevel: models.LevelCivicIDVerified}
+	var wifis []*models.WiFi
+	for i := 0; i < 2; i++ {
+		wifis = append(wifis, addTestWiFi(t, h, fmt.Sprintf("cafe-%d", i), "secret", 13.4, 52.5+float64(i)*0.0001))
+	}
+	connect := func(wifi *models.WiFi) *httptest.ResponseRecorder {
+		body := `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": 52.50005, "longitude": 13.4, "accuracy": 10}`
+		return serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", body, "u1")
+	}
+	expectStatus(t, connect(wifis[0]), http.StatusOK)
+	expectStatus(t, connect(wifis[1]), http.StatusForbidden)
+	history, err := h.ConnectLog.Since(context.Background(), "u1", time.Now().Add(-time.Hour))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 2 || history[0].Mode != models.ConnectModeDenied || !history[0].Risk.Has(models.RiskManyNetworks) {
+		t.Fatalf("history = %+v, want the refusal recorded with many_networks", history)
+	}
+}
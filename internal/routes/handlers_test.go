@@ -0,0 +1,87 @@
+package routes
+
+import (
+	"bytes"
+	"io"
+	"log/slog"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"wifi-go-backend/config"
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/secrets"
+	"wifi-go-backend/internal/store"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+var (
+	ticketSignerOnce sync.Once
+	ticketSigner     *auth.Signer
+)
+
+// newTestHandlers wires Handlers to the in-memory stores.
+func newTestHandlers(t *testing.T) *Handlers {
+	t.Helper()
+	keys, err := secrets.NewLocalKeyProvider(bytes.Repeat([]byte{7}, 32))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticketSignerOnce.Do(func() {
+		ticketSigner, err = auth.GenerateSigner("test", "k1")
+	})
+	if ticketSigner == nil {
+		t.Fatal(err)
+	}
+	return &Handlers{
+		Cfg:          &config.Config{ConnectPresence: "location"}, // the default
+		Logger:       slog.New(slog.NewTextHandler(io.Discard, nil)),
+		WiFi:         store.NewMemoryWiFiRepository(),
+		AuthSessions: store.NewMemoryAuthSessionStore(),
+		Users:        store.NewMemoryUserStore(),
+		SavedWiFi:    store.NewMemorySavedWiFiStore(),
+		Stats:        store.NewMemoryStatsStore(),
+		PasswordKeys: keys,
+		ConnectLog:   store.NewMemoryConnectLogStore(),
+		Tickets: &auth.TicketIssuer{
+			Signer:   ticketSigner,
+			Keys:     ticketSigner.KeySet(),
+			Audience: "test/connect",
+			TTL:      time.Minute,
+		},
+		Audit: store.NewMemoryAuditStore(),
+		Sessions: &auth.SessionManager{
+			Signer:        ticketSigner,
+			Keys:          ticketSigner.KeySet(),
+			Audience:      "test",
+			AccessTTL:     time.Minute,
+			RefreshTTL:    time.Hour,
+			RefreshTokens: store.NewMemoryRefreshTokenStore(),
+			Revoked:       store.NewMemoryRevocationStore(),
+		},
+	}
+}
+
+// serve calls handle as userID ("" for an anonymous caller).
+func serve(handle httprouter.Handle, method, target, body, userID string, ps ...httprouter.Param) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	if body != "" {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if userID != "" {
+		req = req.WithContext(auth.WithClaims(req.Context(), &auth.Claims{Subject: userID}))
+	}
+	rec := httptest.NewRecorder()
+	handle(rec, req, ps)
+	return rec
+}
+
+func expectStatus(t *testing.T, rec *httptest.ResponseRecorder, want int) {
+	t.Helper()
+	if rec.Code != want {
+		t.Fatalf("status = %d, want %d; body: %s", rec.Code, want, rec.Body)
+	}
+}
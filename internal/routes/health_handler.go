@@ -0,0 +1,84 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"wifi-go-backend/internal/buildinfo"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Readiness tracks the startup and shutdown state reported by /readyz.
+type Readiness struct {
+	indexesReady atomic.Bool
+	shuttingDown atomic.Bool
+}
+
+// SetIndexesReady records that the index bootstrap has completed.
+func (r *Readiness) SetIndexesReady() { r.indexesReady.Store(true) }
+
+// SetShuttingDown makes /readyz fail so load balancers stop routing new
+// requests while in-flight ones drain.
+func (r *Readiness) SetShuttingDown() { r.shuttingDown.Store(true) }
+
+// Healthz handles GET /healthz: the process is up and serving.
+func (h *Handlers) Healthz(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{"status": "ok"})
+}
+
+// Readyz handles GET /readyz. It fails while shutting down, before the
+// indexes exist, or when MongoDB does not answer a ping.
+func (h *Handlers) Readyz(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	checks := map[string]string{}
+	ready := true
+	fail := func(name, msg string) {
+		checks[name] = msg
+		ready = false
+	}
+
+	if h.Ready.shuttingDown.Load() {
+		fail("shutdown", "in progress")
+	}
+	if h.Ready.indexesReady.Load() {
+		checks["indexes"] = "ok"
+	} else {
+		fail("indexes", "not ensured")
+	}
+	ctx, cancel := context.WithTimeout(r.Context(), 2*time.Second)
+	defer cancel()
+	if err := h.Ping(ctx); err != nil {
+		// The error can name hosts and credentials; keep it in the logs
+		h.logger(r).Error("readiness check: MongoDB ping failed", "err", err)
+		fail("mongo", "unavailable")
+	} else {
+		checks["mongo"] = "ok"
+	}
+	// Gemini is optional, and when enabled NewHandlers has built its client
+	if h.Cfg.GeminiEnabled {
+		checks["gemini"] = "ok"
+	} else {
+		checks["gemini"] = "disabled"
+	}
+
+	status := "ok"
+	w.Header().Set("Content-Type", "application/json")
+	if !ready {
+		status = "unavailable"
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"status": status,
+		"checks": checks,
+	})
+}
+
+// Version handles GET /version.
+func (h *Handlers) Version(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(buildinfo.Get())
+}
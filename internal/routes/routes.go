@@ -1,140 +1,746 @@
 package routes
 
 import (
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"net/url"
+	"path"
+	"runtime/debug"
 	"strconv"
+	"strings"
+	"time"
 
 	"wifi-go-backend/config"
 	"wifi-go-backend/internal/auth"
 	"wifi-go-backend/internal/db"
+	"wifi-go-backend/internal/logging"
+	"wifi-go-backend/internal/metrics"
 	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/ratelimit"
+	"wifi-go-backend/internal/risk"
+	"wifi-go-backend/internal/secrets"
+	"wifi-go-backend/internal/store"
+	"wifi-go-backend/internal/utils"
 
 	"github.com/julienschmidt/httprouter"
+	"golang.org/x/oauth2"
 )
 
 // Handlers struct for dependency injection
 // (following "Let's Go Further" by Alex Edwards)
 type Handlers struct {
-	Cfg *config.Config
+	Cfg           *config.Config
+	Logger        *slog.Logger
+	Auth          *auth.Authenticator
+	CivicOAuth    *oauth2.Config
+	WiFi          store.WiFiRepository
+	CivicVerifier auth.TokenVerifier
+	Sessions      *auth.SessionManager
+	AuthSessions  store.AuthSessionStore
+	Users         store.UserStore
+	SavedWiFi     store.SavedWiFiStore
+	Stats         store.StatsStore
+	PasswordKeys  secrets.KeyProvider
+	Tickets       *auth.TicketIssuer
+	ConnectLog    store.ConnectLogStore
+	Audit         store.AuditStore
+	// Recommender is nil when FEATURE_GEMINI is off
+	Recommender *LocationRecommender
+	Ready       *Readiness
+	Ping        func(ctx context.Context) error
+	// Proxies decides which forwarding headers utils.ClientIP believes
+	Proxies *utils.TrustedProxies
+	// Risk is nil when RISK_ENABLED is off
+	Risk *risk.Engine
+	// Limiter is nil when RATE_LIMIT_ENABLED is off
+	Limiter    *ratelimit.Limiter
+	RateLimits RateLimits
+
+	// Minimum verification levels for contributing and revealing networks
+	ScanMinLevel    models.VerificationLevel
+	ConnectMinLevel models.VerificationLevel
+}
+
+// RateLimits are the policies SetupRouter attaches to routes.
+type RateLimits struct {
+	IP          ratelimit.Policy
+	ConnectUser ratelimit.Policy
+	ConnectIP   ratelimit.Policy
+	GeminiUser  ratelimit.Policy
+	GeminiIP    ratelimit.Policy
+}
+
+func NewHandlers(cfg *config.Config, logger *slog.Logger) (*Handlers, error) {
+	keys, err := civicKeySet(cfg)
+	if err != nil {
+		return nil, err
+	}
+	verifier := &auth.Verifier{
+		Issuer:    cfg.CivicIssuer,
+		Audience:  cfg.OAuthClientID,
+		Keys:      keys,
+		ClockSkew: cfg.AuthClockSkew,
+	}
+	sessions, err := db.GetAuthSessionCollection()
+	if err != nil {
+		return nil, err
+	}
+	users, err := db.GetUserCollection()
+	if err != nil {
+		return nil, err
+	}
+	sessionManager, err := newSessionManager(cfg, logger)
+	if err != nil {
+		return nil, err
+	}
+	scanLevel, err := models.ParseVerificationLevel(cfg.ScanMinLevel)
+	if err != nil {
+		return nil, fmt.Errorf("WIFI_SCAN_MIN_LEVEL: %w", err)
+	}
+	connectLevel, err := models.ParseVerificationLevel(cfg.ConnectMinLevel)
+	if err != nil {
+		return nil, fmt.Errorf("WIFI_CONNECT_MIN_LEVEL: %w", err)
+	}
+
+	savedWiFi, err := db.GetSavedWiFiCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	stats, err := db.GetStatsCollection()
+	if err != nil {
+		return nil, err
+	}
+	statsKeys, err := db.GetStatsIdempotencyCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	passwordKeys, err := passwordKeyProvider(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	connectLog, err := db.GetConnectLogCollection()
+	if err != nil {
+		return nil, err
+	}
+	tickets := &auth.TicketIssuer{
+		Signer:    sessionManager.Signer,
+		Keys:      sessionManager.Keys,
+		Audience:  cfg.TokenAudience + "/connect",
+		TTL:       cfg.ConnectTicketTTL,
+		ClockSkew: cfg.AuthClockSkew,
+	}
+
+	auditEvents, err := db.GetAuditCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	wifis, err := db.GetWiFiCollection()
+	if err != nil {
+		return nil, err
+	}
+
+	var riskEngine *risk.Engine
+	if cfg.RiskEnabled {
+		stepUp, err := models.ParseVerificationLevel(cfg.RiskStepUpLevel)
+		if err != nil {
+			return nil, fmt.Errorf("RISK_STEP_UP_LEVEL: %w", err)
+		}
+		riskEngine = &risk.Engine{
+			MaxSpeedKmh:   float64(cfg.RiskMaxSpeedKmh),
+			MaxAccuracyM:  float64(cfg.RiskMaxAccuracyM),
+			History:       cfg.RiskHistory,
+			NetworkWindow: cfg.RiskNetworkWindow,
+			MaxNetworks:   cfg.RiskMaxNetworks,
+			DenyScore:     cfg.RiskDenyScore,
+			StepUpScore:   cfg.RiskStepUpScore,
+			StepUpLevel:   stepUp,
+		}
+	}
+
+	proxies, err := utils.ParseTrustedProxies(cfg.TrustedProxies, cfg.TrustedProxyHops)
+	if err != nil {
+		return nil, fmt.Errorf("TRUSTED_PROXIES: %w", err)
+	}
+	limits, err := rateLimits(cfg)
+	if err != nil {
+		return nil, err
+	}
+	var limiter *ratelimit.Limiter
+	if cfg.RateLimitEnabled {
+		limiter = &ratelimit.Limiter{Store: store.NewMemoryRateLimitStore(), Logger: logger}
+		if cfg.RateLimitStore == "mongo" {
+			buckets, err := db.GetRateLimitCollection()
+			if err != nil {
+				return nil, err
+			}
+			limiter.Store = store.NewMongoRateLimitStore(buckets)
+		}
+	}
+
+	var recommender *LocationRecommender
+	if cfg.GeminiEnabled {
+		recommender, err = NewLocationRecommender(cfg.GeminiAPIKey, cfg.GeminiModel)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	userStore := store.NewMongoUserStore(users)
+	// Civic id_tokens are only exchanged at the callback; the API takes our
+	// own access tokens, which logout and refresh token reuse can revoke
+	authenticator := auth.NewAuthenticator(sessionManager)
+	authenticator.Users = userStore
+	return &Handlers{
+		Cfg:             cfg,
+		Logger:          logger,
+		Auth:            authenticator,
+		CivicOAuth:      auth.NewCivicOauthConfig(cfg),
+		WiFi:            store.NewMongoWiFiRepository(wifis),
+		CivicVerifier:   verifier,
+		Sessions:        sessionManager,
+		AuthSessions:    store.NewMongoAuthSessionStore(sessions),
+		Users:           userStore,
+		SavedWiFi:       store.NewMongoSavedWiFiStore(savedWiFi),
+		Stats:           store.NewMongoStatsStore(stats, statsKeys),
+		PasswordKeys:    passwordKeys,
+		Tickets:         tickets,
+		ConnectLog:      store.NewMongoConnectLogStore(connectLog),
+		Audit:           store.NewMongoAuditStore(auditEvents),
+		Recommender:     recommender,
+		Ready:           &Readiness{},
+		Ping:            db.Ping,
+		Proxies:         proxies,
+		Risk:            riskEngine,
+		Limiter:         limiter,
+		RateLimits:      limits,
+		ScanMinLevel:    scanLevel,
+		ConnectMinLevel: connectLevel,
+	}, nil
+}
+
+// logger returns the handlers' logger tagged with the request's ID.
+func (h *Handlers) logger(r *http.Request) *slog.Logger {
+	return h.Logger.With("request_id", logging.RequestID(r.Context()))
+}
+
+// Close releases clients held by the handlers. Call it once the HTTP server
+// has stopped serving requests.
+func (h *Handlers) Close() {
+	if h.Recommender != nil {
+		h.Recommender.Close()
+	}
+}
+
+func newSessionManager(cfg *config.Config, logger *slog.Logger) (*auth.SessionManager, error) {
+	var (
+		signer *auth.Signer
+		keys   auth.StaticKeySet
+		err    error
+	)
+	switch {
+	case len(cfg.SigningKeys) > 0:
+		signer, keys, err = auth.LoadSigningKeys(cfg.TokenIssuer, cfg.SigningKeys, cfg.ActiveKeyID)
+	case cfg.EphemeralSigningKey:
+		// Tokens signed with an ephemeral key die with the process and are
+		// rejected by every other replica.
+		logger.Warn("AUTH_EPHEMERAL_SIGNING_KEY set, generating a signing key for this process only")
+		signer, err = auth.GenerateSigner(cfg.TokenIssuer, "ephemeral")
+		if err == nil {
+			keys = signer.KeySet()
+		}
+	default:
+		err = errors.New("AUTH_SIGNING_KEYS is not set")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	refreshTokens, err := db.GetRefreshTokenCollection()
+	if err != nil {
+		return nil, err
+	}
+	revoked, err := db.GetRevokedTokenCollection()
+	if err != nil {
+		return nil, err
+	}
+	return &auth.SessionManager{
+		Signer:        signer,
+		Keys:          keys,
+		Audience:      cfg.TokenAudience,
+		AccessTTL:     cfg.AccessTokenTTL,
+		RefreshTTL:    cfg.RefreshTokenTTL,
+		ClockSkew:     cfg.AuthClockSkew,
+		RefreshTokens: store.NewMongoRefreshTokenStore(refreshTokens),
+		Revoked:       store.NewMongoRevocationStore(revoked),
+	}, nil
+}
+
+// passwordKeyProvider loads the master key used to encrypt WiFi passwords.
+// There is deliberately no fallback: a generated key would make stored
+// passwords unreadable after a restart.
+func passwordKeyProvider(cfg *config.Config) (secrets.KeyProvider, error) {
+	if cfg.PasswordKeyFile == "" {
+		return nil, errors.New("WIFI_PASSWORD_KEY_FILE is required")
+	}
+	return secrets.LoadLocalKeyFile(cfg.PasswordKeyFile, cfg.PasswordRetiredKeyFiles...)
+}
+
+func rateLimits(cfg *config.Config) (RateLimits, error) {
+	var limits RateLimits
+	for _, p := range []struct {
+		env, name, spec string
+		dst             *ratelimit.Policy
+	}{
+		{"RATE_LIMIT_IP", "ip", cfg.RateLimitIP, &limits.IP},
+		{"RATE_LIMIT_CONNECT_USER", "connect_user", cfg.RateLimitConnectUser, &limits.ConnectUser},
+		{"RATE_LIMIT_CONNECT_IP", "connect_ip", cfg.RateLimitConnectIP, &limits.ConnectIP},
+		{"RATE_LIMIT_GEMINI_USER", "gemini_user", cfg.RateLimitGeminiUser, &limits.GeminiUser},
+		{"RATE_LIMIT_GEMINI_IP", "gemini_ip", cfg.RateLimitGeminiIP, &limits.GeminiIP},
+	} {
+		policy, err := ratelimit.ParsePolicy(p.name, p.spec)
+		if err != nil {
+			return RateLimits{}, fmt.Errorf("%s: %w", p.env, err)
+		}
+		*p.dst = policy
+	}
+	return limits, nil
 }
 
-func NewHandlers(cfg *config.Config) *Handlers {
-	return &Handlers{Cfg: cfg}
+// civicKeySet prefers a local JWKS file so the service can run offline.
+func civicKeySet(cfg *config.Config) (auth.KeySet, error) {
+	if cfg.CivicJWKSFile != "" {
+		return auth.LoadJWKSFile(cfg.CivicJWKSFile)
+	}
+	return auth.NewRemoteKeySet(cfg.CivicJWKSURL), nil
 }
 
 func (h *Handlers) CivicAuth(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// Generate PKCE code verifier and challenge (optional, recommended for mobile)
-	verifier, err := auth.GenerateCodeVerifier()
+	// Where the client wants to land after login (e.g. an app deep link)
+	redirectTo := r.URL.Query().Get("redirect_to")
+	if redirectTo != "" && !h.redirectAllowed(redirectTo) {
+		problem.Write(w, r, problem.RedirectNotAllowed, "redirect_to is not allowed")
+		return
+	}
+
+	sess := &models.AuthSession{RedirectTo: redirectTo}
+	oauthURL, err := h.startCivicFlow(r.Context(), sess, auth.CivicScopes)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to generate code verifier"))
+		problem.Write(w, r, problem.Internal, "Failed to start login")
 		return
 	}
+	h.setLoginStateCookie(w, sess.State, int(h.Cfg.AuthSessionTTL.Seconds()))
+
+	// Redirect to Civic OAuth
+	http.Redirect(w, r, oauthURL, http.StatusFound)
+}
+
+// startCivicFlow records sess (state, PKCE verifier and expiry are filled in)
+// and returns the Civic authorization URL requesting scopes.
+func (h *Handlers) startCivicFlow(ctx context.Context, sess *models.AuthSession, scopes []string) (string, error) {
+	// Generate PKCE code verifier and challenge
+	verifier, err := auth.GenerateCodeVerifier()
+	if err != nil {
+		return "", err
+	}
 	challenge := auth.GenerateCodeChallenge(verifier)
 
-	// (Optional) Store code_verifier in session/cookie or pass to client for later use in callback
-	// For demo, we pass it as a query param (not secure for production)
+	state, err := auth.GenerateState()
+	if err != nil {
+		return "", err
+	}
 
-	// Build the Civic OAuth URL
-	params := r.URL.Query()
-	params.Set("response_type", "code")
-	params.Set("client_id", auth.CivicOauthConfig.ClientID)
-	params.Set("redirect_uri", auth.CivicOauthConfig.RedirectURL)
-	params.Set("scope", "openid")
-	params.Set("code_challenge", challenge)
-	params.Set("code_challenge_method", "S256")
-	// (Optional) Add state param for CSRF protection
-	// params.Set("state", "random_state")
-
-	oauthURL := auth.CivicOauthConfig.Endpoint.AuthURL + "?" + params.Encode()
+	// Keep the verifier server-side; only the state travels through Civic
+	now := time.Now()
+	sess.State = state
+	sess.CodeVerifier = verifier
+	sess.CreatedAt = now
+	sess.ExpiresAt = now.Add(h.Cfg.AuthSessionTTL)
+	if err := h.AuthSessions.Create(ctx, sess); err != nil {
+		return "", err
+	}
 
-	// Redirect to Civic OAuth
-	http.Redirect(w, r, oauthURL, http.StatusFound)
+	// Build the Civic OAuth URL
+	oauthCfg := *h.CivicOAuth
+	oauthCfg.Scopes = scopes
+	return oauthCfg.AuthCodeURL(state,
+		oauth2.SetAuthURLParam("code_challenge", challenge),
+		oauth2.SetAuthURLParam("code_challenge_method", "S256"),
+	), nil
 }
 
-func (h *Handlers) AuthMe(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// TODO: Return current user info
+// redirectAllowed reports whether target is one of AUTH_ALLOWED_REDIRECTS or
+// lies below one. Scheme and host must be equal and the path must match on
+// a "/" boundary, so "https://app.example.com.evil" or
+// "https://app.example.com@evil" never pass for "https://app.example.com".
+func (h *Handlers) redirectAllowed(target string) bool {
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || u.User != nil || u.Fragment != "" {
+		return false
+	}
+	for _, raw := range h.Cfg.AuthAllowedRedirects {
+		allowed, err := url.Parse(raw)
+		if err != nil || allowed.Host == "" {
+			continue
+		}
+		if strings.EqualFold(u.Scheme, allowed.Scheme) &&
+			strings.EqualFold(u.Host, allowed.Host) &&
+			pathWithin(u.Path, allowed.Path) {
+			return true
+		}
+	}
+	return false
 }
 
-func (h *Handlers) AuthUpgrade(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// TODO: Upgrade verification level
+// pathWithin reports whether p is base or below it. Dot segments are
+// resolved first so "/cb/../admin" does not count as below "/cb".
+func pathWithin(p, base string) bool {
+	base = strings.TrimSuffix(base, "/")
+	if base == "" {
+		return true
+	}
+	if p != "" {
+		p = path.Clean(p)
+	}
+	return p == base || strings.HasPrefix(p, base+"/")
 }
 
-func (h *Handlers) WiFiSaved(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// TODO: Get saved networks
+// loginStateCookie ties a Civic login to the browser that started it, so a
+// callback carrying a state minted for someone else is refused (login CSRF).
+const loginStateCookie = "civic_login_state"
+
+func (h *Handlers) setLoginStateCookie(w http.ResponseWriter, state string, maxAge int) {
+	http.SetCookie(w, &http.Cookie{
+		Name:     loginStateCookie,
+		Value:    state,
+		Path:     "/api/auth/civic",
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   strings.HasPrefix(h.Cfg.OAuthRedirectURL, "https://"),
+		// Lax still sends it on the top-level redirect back from Civic
+		SameSite: http.SameSiteLaxMode,
+	})
 }
 
-func (h *Handlers) StatsGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// TODO: Get user statistics
+func (h *Handlers) AuthMe(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	user, err := h.Users.FindByID(r.Context(), auth.SubjectFromContext(r.Context()))
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.UserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(user)
 }
 
-func (h *Handlers) StatsPatch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	// TODO: Update statistics
+// AuthUpgrade handles POST /api/auth/upgrade
+// Expects JSON body: { "level": "civic_id_verified", "redirect_to": "..." }
+// Returns the Civic URL to open; the callback records the upgraded level.
+func (h *Handlers) AuthUpgrade(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req struct {
+		Level      models.VerificationLevel `json:"level"`
+		RedirectTo string                   `json:"redirect_to"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.InvalidBody, "Invalid request body")
+		return
+	}
+	// Only Civic-backed levels can be reached by re-running the flow;
+	// trusted contributor is earned, not requested.
+	if req.Level != models.LevelEmailVerified && req.Level != models.LevelCivicIDVerified {
+		problem.Validation(w, r, problem.FieldError{Field: "level", Message: "must be email_verified or civic_id_verified"})
+		return
+	}
+	if req.RedirectTo != "" && !h.redirectAllowed(req.RedirectTo) {
+		problem.Write(w, r, problem.RedirectNotAllowed, "redirect_to is not allowed")
+		return
+	}
+
+	userID := auth.SubjectFromContext(r.Context())
+	user, err := h.Users.FindByID(r.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.UserNotFound, "User not found")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load user")
+		return
+	}
+	if user.VerificationLevel >= req.Level {
+		problem.Write(w, r, problem.AlreadyVerified, "Already at or above the requested level")
+		return
+	}
+
+	oauthURL, err := h.startCivicFlow(r.Context(), &models.AuthSession{
+		RedirectTo:  req.RedirectTo,
+		UserID:      userID,
+		TargetLevel: req.Level,
+	}, h.Cfg.CivicUpgradeScopes)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to start upgrade")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"authorization_url": oauthURL,
+		"current_level":     user.VerificationLevel,
+		"target_level":      req.Level,
+	})
 }
 
 func (h *Handlers) CivicCallback(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
 	ctx := r.Context()
 
+	if errParam := r.URL.Query().Get("error"); errParam != "" {
+		problem.Write(w, r, problem.LoginFailed, "Civic login failed: "+errParam)
+		return
+	}
+
 	code := r.URL.Query().Get("code")
 	if code == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("No code parameter provided"))
+		problem.Write(w, r, problem.InvalidLoginState, "No code parameter provided")
+		return
+	}
+
+	// The state must match a flow we started and can only be used once
+	state := r.URL.Query().Get("state")
+	if state == "" {
+		problem.Write(w, r, problem.InvalidLoginState, "No state parameter provided")
 		return
 	}
+	sess, err := h.AuthSessions.Consume(ctx, state)
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.InvalidLoginState, "Invalid or expired state")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load login session")
+		return
+	}
+	// A login must finish in the browser that started it. Upgrades are
+	// started by an authenticated API call rather than a browser, and are
+	// bound to that account by the subject check below instead.
+	if sess.UserID == "" {
+		cookie, err := r.Cookie(loginStateCookie)
+		h.setLoginStateCookie(w, "", -1)
+		if err != nil || subtle.ConstantTimeCompare([]byte(cookie.Value), []byte(state)) != 1 {
+			problem.Write(w, r, problem.InvalidLoginState, "Login was not started from this browser")
+			return
+		}
+	}
 
-	token, err := auth.CivicOauthConfig.Exchange(ctx, code)
+	token, err := h.CivicOAuth.Exchange(ctx, code,
+		oauth2.SetAuthURLParam("code_verifier", sess.CodeVerifier),
+	)
 	if err != nil {
-		http.Error(w, "Token exchange failed", http.StatusInternalServerError)
+		h.logger(r).Warn("civic token exchange failed", "err", err)
+		problem.Write(w, r, problem.UpstreamAuthFailed, "Token exchange failed")
 		return
 	}
 
 	idToken, _ := token.Extra("id_token").(string)
+	if idToken == "" {
+		problem.Write(w, r, problem.UpstreamAuthFailed, "Token exchange returned no id_token")
+		return
+	}
+	claims, err := h.CivicVerifier.Verify(ctx, idToken)
+	if err != nil {
+		problem.Write(w, r, problem.InvalidIDToken, "Invalid id_token")
+		return
+	}
+
+	// An upgrade must come back as the same person who started it
+	if sess.UserID != "" && sess.UserID != claims.Subject {
+		problem.Write(w, r, problem.AccountMismatch, "Upgrade completed by a different account")
+		return
+	}
+
+	login := userFromClaims(claims)
+	if sess.UserID != "" && sess.TargetLevel == models.LevelCivicIDVerified && grantedScopes(token, h.Cfg.CivicUpgradeScopes) {
+		login.VerificationLevel = models.LevelCivicIDVerified
+	}
+	if _, err := h.Users.UpsertLogin(ctx, login); err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to provision user")
+		return
+	}
+
+	// Hand out our own tokens; Civic's stay on the server
+	pair, err := h.Sessions.Issue(ctx, claims.Subject)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to issue session")
+		return
+	}
 
-	response := map[string]interface{}{
-		"access_token": token.AccessToken,
-		"id_token":     idToken,
-		"expiry":       token.Expiry.Format("2006-01-02T15:04:05Z07:00"),
+	if sess.RedirectTo != "" {
+		redirectWithTokens(w, r, sess.RedirectTo, pair)
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	_ = json.NewEncoder(w).Encode(response)
+	_ = json.NewEncoder(w).Encode(pair)
 }
 
-// GeminiRecommendHandler handles /api/gemini/recommend requests
-func GeminiRecommendHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	startLatStr := r.URL.Query().Get("start_lat")
-	startLngStr := r.URL.Query().Get("start_lng")
-	endLatStr := r.URL.Query().Get("end_lat")
-	endLngStr := r.URL.Query().Get("end_lng")
-	if startLatStr == "" || startLngStr == "" || endLatStr == "" || endLngStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Missing required query parameters: start_lat, start_lng, end_lat, end_lng"))
+// AuthRefresh handles POST /api/auth/refresh
+// Expects JSON body: { "refresh_token": "..." }
+func (h *Handlers) AuthRefresh(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil || req.RefreshToken == "" {
+		problem.Validation(w, r, problem.FieldError{Field: "refresh_token", Message: "is required"})
 		return
 	}
-	startLat, err1 := strconv.ParseFloat(startLatStr, 64)
-	startLng, err2 := strconv.ParseFloat(startLngStr, 64)
-	endLat, err3 := strconv.ParseFloat(endLatStr, 64)
-	endLng, err4 := strconv.ParseFloat(endLngStr, 64)
-	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid coordinate values"))
+
+	pair, err := h.Sessions.Refresh(r.Context(), req.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		problem.Write(w, r, problem.InvalidRefreshToken, "Invalid refresh token")
 		return
 	}
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	recommender, err := NewLocationRecommender(apiKey)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to create Gemini recommender"))
+		problem.Write(w, r, problem.Internal, "Failed to refresh session")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(pair)
+}
+
+// AuthLogout handles POST /api/auth/logout
+// Revokes the caller's access token and, when given, its refresh token family.
+// Expects optional JSON body: { "refresh_token": "..." }
+func (h *Handlers) AuthLogout(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			problem.Write(w, r, problem.InvalidBody, "Invalid request body")
+			return
+		}
+	}
+
+	claims, _ := auth.ClaimsFromContext(r.Context())
+	err := h.Sessions.Logout(r.Context(), claims, req.RefreshToken)
+	if errors.Is(err, auth.ErrInvalidRefreshToken) {
+		problem.Write(w, r, problem.Forbidden, "Refresh token belongs to another user")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to log out")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func userFromClaims(c *auth.Claims) *models.User {
+	level := models.LevelAnonymous
+	if c.EmailVerified {
+		level = models.LevelEmailVerified
+	}
+	return &models.User{
+		ID:                c.Subject,
+		Email:             c.Email,
+		Name:              c.Name,
+		VerificationLevel: level,
+	}
+}
+
+// grantedScopes reports whether Civic granted every scope in want. A token
+// response without "scope" is no evidence of a grant, so the level it would
+// unlock is not recorded.
+func grantedScopes(token *oauth2.Token, want []string) bool {
+	scope, _ := token.Extra("scope").(string)
+	granted := map[string]bool{}
+	for _, s := range strings.Fields(scope) {
+		granted[s] = true
+	}
+	for _, s := range want {
+		if !granted[s] {
+			return false
+		}
+	}
+	return true
+}
+
+// redirectWithTokens sends the client back to its redirect target with the
+// tokens in the URL fragment, so they are not logged by intermediaries.
+func redirectWithTokens(w http.ResponseWriter, r *http.Request, target string, pair *auth.TokenPair) {
+	fragment := url.Values{}
+	fragment.Set("access_token", pair.AccessToken)
+	fragment.Set("refresh_token", pair.RefreshToken)
+	fragment.Set("token_type", pair.TokenType)
+	fragment.Set("expires_in", strconv.FormatInt(pair.ExpiresIn, 10))
+	http.Redirect(w, r, target+"#"+fragment.Encode(), http.StatusFound)
+}
+
+// floatParams parses the named query parameters as numbers, reporting every
+// missing or malformed one.
+func floatParams(r *http.Request, names ...string) ([]float64, []problem.FieldError) {
+	values := make([]float64, len(names))
+	var errs []problem.FieldError
+	for i, name := range names {
+		raw := r.URL.Query().Get(name)
+		if raw == "" {
+			errs = append(errs, problem.FieldError{Field: name, Message: "is required"})
+			continue
+		}
+		v, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			errs = append(errs, problem.FieldError{Field: name, Message: "must be a number"})
+			continue
+		}
+		values[i] = v
+	}
+	return values, errs
+}
+
+// latLngErrors reports coordinates off the globe; NaN fails both checks.
+func latLngErrors(latField, lngField string, lat, lng float64) []problem.FieldError {
+	var errs []problem.FieldError
+	if !(lat >= -90 && lat <= 90) {
+		errs = append(errs, problem.FieldError{Field: latField, Message: "must be between -90 and 90"})
+	}
+	if !(lng >= -180 && lng <= 180) {
+		errs = append(errs, problem.FieldError{Field: lngField, Message: "must be between -180 and 180"})
+	}
+	return errs
+}
+
+// GeminiRecommendHandler handles /api/gemini/recommend requests
+func (h *Handlers) GeminiRecommendHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	coords, errs := floatParams(r, "start_lat", "start_lng", "end_lat", "end_lng")
+	errs = append(errs, latLngErrors("start_lat", "start_lng", coords[0], coords[1])...)
+	errs = append(errs, latLngErrors("end_lat", "end_lng", coords[2], coords[3])...)
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs...)
+		return
+	}
+	startLat, startLng, endLat, endLng := coords[0], coords[1], coords[2], coords[3]
+	recommender := h.Recommender
+	if recommender == nil {
+		problem.Write(w, r, problem.AIUnavailable, "Gemini recommendations are disabled")
 		return
 	}
-	defer recommender.Close()
 	ctx := r.Context()
 	jsonStops, err := recommender.FindStopsBetween(ctx, startLat, startLng, endLat, endLng)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Gemini recommendation failed: " + err.Error()))
+		// Gemini errors can be verbose and leak prompt details; log them instead
+		h.logger(r).Error("gemini recommendation failed", "err", err)
+		problem.Write(w, r, problem.AIFailed, "Recommendation service failed, try again later")
 		return
 	}
 	w.Header().Set("Content-Type", "application/json")
@@ -143,33 +749,20 @@ func GeminiRecommendHandler(w http.ResponseWriter, r *http.Request, _ httprouter
 
 // GeminiRecommendStopsWiFiHandler handles /api/gemini/recommendstopswifi requests
 // It calls the recommender, then for each stop, lists all nearby WiFi networks
-func GeminiRecommendStopsWiFiHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
-	startLatStr := r.URL.Query().Get("start_lat")
-	startLngStr := r.URL.Query().Get("start_lng")
-	endLatStr := r.URL.Query().Get("end_lat")
-	endLngStr := r.URL.Query().Get("end_lng")
-	if startLatStr == "" || startLngStr == "" || endLatStr == "" || endLngStr == "" {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Missing required query parameters: start_lat, start_lng, end_lat, end_lng"))
+func (h *Handlers) GeminiRecommendStopsWiFiHandler(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	coords, errs := floatParams(r, "start_lat", "start_lng", "end_lat", "end_lng")
+	errs = append(errs, latLngErrors("start_lat", "start_lng", coords[0], coords[1])...)
+	errs = append(errs, latLngErrors("end_lat", "end_lng", coords[2], coords[3])...)
+	if len(errs) > 0 {
+		problem.Validation(w, r, errs...)
 		return
 	}
-	startLat, err1 := strconv.ParseFloat(startLatStr, 64)
-	startLng, err2 := strconv.ParseFloat(startLngStr, 64)
-	endLat, err3 := strconv.ParseFloat(endLatStr, 64)
-	endLng, err4 := strconv.ParseFloat(endLngStr, 64)
-	if err1 != nil || err2 != nil || err3 != nil || err4 != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Invalid coordinate values"))
+	startLat, startLng, endLat, endLng := coords[0], coords[1], coords[2], coords[3]
+	recommender := h.Recommender
+	if recommender == nil {
+		problem.Write(w, r, problem.AIUnavailable, "Gemini recommendations are disabled")
 		return
 	}
-	apiKey := os.Getenv("GEMINI_API_KEY")
-	recommender, err := NewLocationRecommender(apiKey)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Failed to create Gemini recommender"))
-		return
-	}
-	defer recommender.Close()
 	ctx := r.Context()
 	stopsResp, err := recommender.FindStops(ctx, RecommendationRequest{
 		StartCoordinate: Coordinate{Latitude: startLat, Longitude: startLng},
@@ -178,8 +771,9 @@ func GeminiRecommendStopsWiFiHandler(w http.ResponseWriter, r *http.Request, _ h
 		MaxStops:        5,
 	})
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		w.Write([]byte("Gemini recommendation failed: " + err.Error()))
+		// Gemini errors can be verbose and leak prompt details; log them instead
+		h.logger(r).Error("gemini recommendation failed", "err", err)
+		problem.Write(w, r, problem.AIFailed, "Recommendation service failed, try again later")
 		return
 	}
 
@@ -189,39 +783,15 @@ func GeminiRecommendStopsWiFiHandler(w http.ResponseWriter, r *http.Request, _ h
 	}
 	var results []WiFiWithStop
 	for _, stop := range stopsResp.Stops {
-		coll, err := db.GetWiFiCollection()
-		if err != nil {
-			continue
-		}
 		const radiusKm = 1.0
-		const earthRadiusKm = 6371.0
-		filter := map[string]interface{}{
-			"location": map[string]interface{}{
-				"$geoWithin": map[string]interface{}{
-					"$centerSphere": []interface{}{
-						[]float64{stop.Longitude, stop.Latitude},
-						radiusKm / earthRadiusKm,
-					},
-				},
-			},
-		}
-		cur, err := coll.Find(ctx, filter)
+		found, err := h.WiFi.FindWithin(ctx, stop.Longitude, stop.Latitude, radiusKm)
 		if err != nil {
 			continue
 		}
 		var wifis []map[string]interface{}
-		for cur.Next(ctx) {
-			var wifi models.WiFi
-			if err := cur.Decode(&wifi); err == nil {
-				wifis = append(wifis, map[string]interface{}{
-					"id":          wifi.ID,
-					"ssid":        wifi.SSID,
-					"location":    wifi.Location,
-					"description": wifi.Description,
-				})
-			}
+		for i := range found {
+			wifis = append(wifis, wifiSummary(&found[i]))
 		}
-		cur.Close(ctx)
 		results = append(results, WiFiWithStop{
 			Stop:  stop,
 			WiFis: wifis,
@@ -234,31 +804,122 @@ func GeminiRecommendStopsWiFiHandler(w http.ResponseWriter, r *http.Request, _ h
 	})
 }
 
-func SetupRouter(cfg *config.Config) http.Handler {
-	h := NewHandlers(cfg)
-	router := httprouter.New()
+// instrumentedRouter records metrics for every route registered through it,
+// labelled with the route pattern rather than the raw path, and reports the
+// pattern to the access log. Routes also share its rate limits.
+type instrumentedRouter struct {
+	*httprouter.Router
+	limiter *ratelimit.Limiter
+	limits  []ratelimit.Rule
+}
+
+func (r instrumentedRouter) Handle(method, path string, handle httprouter.Handle) {
+	instrumented := metrics.InstrumentHandle(method, path, r.limiter.Limit(handle, r.limits...))
+	r.Router.Handle(method, path, func(w http.ResponseWriter, req *http.Request, ps httprouter.Params) {
+		logging.SetRoute(req.Context(), path)
+		instrumented(w, req, ps)
+	})
+}
+
+func (r instrumentedRouter) GET(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodGet, path, handle)
+}
+
+func (r instrumentedRouter) POST(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodPost, path, handle)
+}
+
+func (r instrumentedRouter) PATCH(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodPatch, path, handle)
+}
+
+func (r instrumentedRouter) DELETE(path string, handle httprouter.Handle) {
+	r.Handle(http.MethodDelete, path, handle)
+}
+
+func SetupRouter(h *Handlers) http.Handler {
+	base := httprouter.New()
+	router := instrumentedRouter{
+		Router:  base,
+		limiter: h.Limiter,
+		limits:  []ratelimit.Rule{{Policy: h.RateLimits.IP, Key: ratelimit.ByIP}},
+	}
+	connectLimit := func(next httprouter.Handle) httprouter.Handle {
+		return h.Limiter.Limit(next,
+			ratelimit.Rule{Policy: h.RateLimits.ConnectUser, Key: ratelimit.ByUser},
+			ratelimit.Rule{Policy: h.RateLimits.ConnectIP, Key: ratelimit.ByIP})
+	}
+	// The AI routes are public; signing in gives the caller their own budget
+	geminiLimit := func(next httprouter.Handle) httprouter.Handle {
+		return h.Auth.OptionalAuth(h.Limiter.Limit(next,
+			ratelimit.Rule{Policy: h.RateLimits.GeminiUser, Key: ratelimit.ByUser},
+			ratelimit.Rule{Policy: h.RateLimits.GeminiIP, Key: ratelimit.ByIP}))
+	}
+
+	// httprouter cannot hold /api/wifi/:id beside the static /api/wifi/...
+	// routes, so the network resource lives on a second router that serves
+	// whatever the first one does not match
+	items := httprouter.New()
+	itemRouter := instrumentedRouter{Router: items, limiter: router.limiter, limits: router.limits}
+	base.NotFound = items
+
+	// Unmatched routes and panics answer with the same problem format as
+	// the handlers
+	items.NotFound = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.NotFound, "No route matches "+r.URL.Path)
+	})
+	methodNotAllowed := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		problem.Write(w, r, problem.MethodNotAllowed, r.Method+" is not allowed on "+r.URL.Path)
+	})
+	base.MethodNotAllowed = methodNotAllowed
+	items.MethodNotAllowed = methodNotAllowed
+	panicHandler := func(w http.ResponseWriter, r *http.Request, v interface{}) {
+		h.logger(r).Error("handler panicked", "panic", v, "stack", string(debug.Stack()))
+		problem.Write(w, r, problem.Internal, "Internal server error")
+	}
+	base.PanicHandler = panicHandler
+	items.PanicHandler = panicHandler
+
+	// --- Health and Metrics Endpoints ---
+	// Registered on the base router so probes and scrapes stay out of the
+	// request metrics
+	base.GET("/healthz", h.Healthz)
+	base.GET("/readyz", h.Readyz)
+	base.GET("/version", h.Version)
+	base.Handler(http.MethodGet, "/metrics", metrics.Handler())
 
 	// --- Authentication Endpoints ---
-	// router.POST("/api/auth/civic", h.CivicAuth)
-	router.GET("/api/auth/me", h.AuthMe)
-	router.POST("/api/auth/upgrade", h.AuthUpgrade)
-	// router.GET("/api/auth/civic/callback", h.CivicCallback)
+	router.GET("/api/auth/civic", h.CivicAuth)
+	router.GET("/api/auth/me", h.Auth.RequireAuthRouter(h.AuthMe))
+	router.POST("/api/auth/upgrade", h.Auth.RequireAuthRouter(h.AuthUpgrade))
+	router.GET("/api/auth/civic/callback", h.CivicCallback)
+	router.POST("/api/auth/refresh", h.AuthRefresh)
+	router.POST("/api/auth/logout", h.Auth.RequireAuthRouter(h.AuthLogout))
 
 	// --- WiFi Management Endpoints ---
 	// router.POST("/api/wifi/scan", auth.RequireAuthRouter(h.WiFiScan))
-	router.POST("/api/wifi/scan", auth.RequireAuthRouter(h.WiFiScan))
-	router.POST("/api/wifi/connect", auth.RequireAuthRouter(h.WiFiConnect))
+	router.POST("/api/wifi/scan", h.Auth.RequireLevel(h.ScanMinLevel, h.WiFiScan))
+	router.POST("/api/wifi/connect", h.Auth.RequireLevel(h.ConnectMinLevel, connectLimit(h.WiFiConnect)))
+	router.POST("/api/wifi/connect/ticket/redeem", h.ConnectTicketRedeem)
 	router.GET("/api/wifi/nearby", h.WiFiNearby)
-	router.GET("/api/wifi/saved", auth.RequireAuthRouter(h.WiFiSaved))
+	router.GET("/api/wifi/saved", h.Auth.RequireAuthRouter(h.WiFiSaved))
+	router.POST("/api/wifi/saved/:id", h.Auth.RequireAuthRouter(h.WiFiSave))
+	router.DELETE("/api/wifi/saved/:id", h.Auth.RequireAuthRouter(h.WiFiUnsave))
+	itemRouter.GET("/api/wifi/:id", h.Auth.OptionalAuth(h.WiFiGet))
+	itemRouter.PATCH("/api/wifi/:id", h.Auth.RequireAuthRouter(h.WiFiPatch))
+	itemRouter.DELETE("/api/wifi/:id", h.Auth.RequireAuthRouter(h.WiFiDelete))
 
 	// --- Statistics Endpoints ---
-	router.GET("/api/stats", auth.RequireAuthRouter(h.StatsGet))
-	router.PATCH("/api/stats", auth.RequireAuthRouter(h.StatsPatch))
+	router.GET("/api/stats", h.Auth.RequireAuthRouter(h.StatsGet))
+	router.PATCH("/api/stats", h.Auth.RequireAuthRouter(h.StatsPatch))
 	router.POST("/api/wifi/nearby/stops", h.NearbyWiFiForStopsHandler)
 
+	// --- Admin Endpoints ---
+	router.GET("/api/admin/audit", h.Auth.RequireRole(models.RoleAdmin, h.AdminAudit))
+
 	// --- Gemini Recommender Endpoint ---
-	router.GET("/api/gemini/recommendstops", GeminiRecommendHandler)
-	router.GET("/api/gemini/recommendstopswifi", GeminiRecommendStopsWiFiHandler)
+	router.GET("/api/gemini/recommendstops", geminiLimit(h.GeminiRecommendHandler))
+	router.GET("/api/gemini/recommendstopswifi", geminiLimit(h.GeminiRecommendStopsWiFiHandler))
 
-	return router
+	return h.Proxies.Middleware(logging.Middleware(h.Logger, base))
 }
@@ -0,0 +1,121 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/store"
+	"wifi-go-backend/internal/utils"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// WiFiSaved handles GET /api/wifi/saved
+// Optional query params latitude/longitude add the current distance (km).
+func (h *Handlers) WiFiSaved(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	q := r.URL.Query()
+	withDistance := q.Get("latitude") != "" || q.Get("longitude") != ""
+	var lat, lng float64
+	if withDistance {
+		coords, errs := floatParams(r, "latitude", "longitude")
+		errs = append(errs, latLngErrors("latitude", "longitude", coords[0], coords[1])...)
+		if len(errs) > 0 {
+			problem.Validation(w, r, errs...)
+			return
+		}
+		lat, lng = coords[0], coords[1]
+	}
+
+	ctx := r.Context()
+	saved, err := h.SavedWiFi.List(ctx, auth.SubjectFromContext(ctx))
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load saved networks")
+		return
+	}
+
+	results := []map[string]interface{}{}
+	if len(saved) > 0 {
+		ids := make([]primitive.ObjectID, len(saved))
+		for i, s := range saved {
+			ids[i] = s.WiFiID
+		}
+		wifis, err := h.WiFi.FindByIDs(ctx, ids)
+		if err != nil {
+			problem.Write(w, r, problem.Internal, "Failed to load saved networks")
+			return
+		}
+		byID := map[primitive.ObjectID]models.WiFi{}
+		for _, wifi := range wifis {
+			byID[wifi.ID] = wifi
+		}
+
+		// Keep the bookmark order; networks removed since saving are skipped
+		for _, s := range saved {
+			wifi, ok := byID[s.WiFiID]
+			if !ok {
+				continue
+			}
+			item := wifiSummary(&wifi)
+			item["saved_at"] = s.SavedAt
+			if withDistance && len(wifi.Location.Coordinates) == 2 {
+				item["distance"] = utils.Haversine(lat, lng, wifi.Location.Coordinates[1], wifi.Location.Coordinates[0])
+			}
+			results = append(results, item)
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(results)
+}
+
+// WiFiSave handles POST /api/wifi/saved/:id
+func (h *Handlers) WiFiSave(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	objID, err := primitive.ObjectIDFromHex(ps.ByName("id"))
+	if err != nil {
+		problem.Write(w, r, problem.InvalidWiFiID, "Invalid wifi_id")
+		return
+	}
+
+	if _, err := h.WiFi.FindByID(r.Context(), objID); err != nil {
+		if errors.Is(err, store.ErrNotFound) {
+			problem.Write(w, r, problem.WiFiNotFound, "WiFi not found")
+			return
+		}
+		problem.Write(w, r, problem.Internal, "Failed to check WiFi")
+		return
+	}
+
+	saved, err := h.SavedWiFi.Save(r.Context(), auth.SubjectFromContext(r.Context()), objID)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to save WiFi")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(saved)
+}
+
+// WiFiUnsave handles DELETE /api/wifi/saved/:id
+func (h *Handlers) WiFiUnsave(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	objID, err := primitive.ObjectIDFromHex(ps.ByName("id"))
+	if err != nil {
+		problem.Write(w, r, problem.InvalidWiFiID, "Invalid wifi_id")
+		return
+	}
+
+	err = h.SavedWiFi.Remove(r.Context(), auth.SubjectFromContext(r.Context()), objID)
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.NotSaved, "WiFi is not saved")
+		return
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to remove saved WiFi")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,71 @@
+package routes
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func savedIDs(t *testing.T, h *Handlers, userID string) []string {
+	t.Helper()
+	rec := serve(h.WiFiSaved, http.MethodGet, "/api/wifi/saved", "", userID)
+	expectStatus(t, rec, http.StatusOK)
+	var items []map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&items); err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item["id"].(string))
+	}
+	return ids
+}
+
+func TestSavedWiFi(t *testing.T) {
+	h := newTestHandlers(t)
+	a := addTestWiFi(t, h, "a", "secret", 13.4, 52.5)
+	b := addTestWiFi(t, h, "b", "secret", 13.5, 52.5)
+	param := func(id string) httprouter.Param { return httprouter.Param{Key: "id", Value: id} }
+
+	for _, id := range []string{a.ID.Hex(), b.ID.Hex(), a.ID.Hex()} {
+		expectStatus(t, serve(h.WiFiSave, http.MethodPost, "/api/wifi/saved/x", "", "u1", param(id)), http.StatusCreated)
+	}
+	if got := savedIDs(t, h, "u1"); len(got) != 2 {
+		t.Fatalf("saved = %v, want both networks once", got)
+	}
+	if got := savedIDs(t, h, "u2"); len(got) != 0 {
+		t.Errorf("another user's saved = %v, want none", got)
+	}
+
+	// Distance is only added when a position is given
+	rec := serve(h.WiFiSaved, http.MethodGet, "/api/wifi/saved?latitude=52.5&longitude=13.4", "", "u1")
+	expectStatus(t, rec, http.StatusOK)
+	var items []map[string]interface{}
+	json.NewDecoder(rec.Body).Decode(&items)
+	for _, item := range items {
+		if _, ok := item["distance"]; !ok {
+			t.Errorf("saved item %v has no distance", item["id"])
+		}
+	}
+
+	expectStatus(t, serve(h.WiFiUnsave, http.MethodDelete, "/api/wifi/saved/x", "", "u1", param(a.ID.Hex())), http.StatusNoContent)
+	expectStatus(t, serve(h.WiFiUnsave, http.MethodDelete, "/api/wifi/saved/x", "", "u1", param(a.ID.Hex())), http.StatusNotFound)
+	if got := savedIDs(t, h, "u1"); len(got) != 1 || got[0] != b.ID.Hex() {
+		t.Errorf("saved after removing a = %v, want [%s]", got, b.ID.Hex())
+	}
+}
+
+func TestWiFiSaveRejectsUnknownNetworks(t *testing.T) {
+	h := newTestHandlers(t)
+	for id, want := range map[string]int{
+		"nope":                     http.StatusBadRequest,
+		"000000000000000000000000": http.StatusNotFound,
+	} {
+		rec := serve(h.WiFiSave, http.MethodPost, "/api/wifi/saved/x", "", "u1", httprouter.Param{Key: "id", Value: id})
+		if rec.Code != want {
+			t.Errorf("save %s: status = %d, want %d", id, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package routes
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/store"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// Upper bounds for a single client-reported event
+const (
+	maxReportedBytes   = 100 << 30 // 100 GiB
+	maxReportedSeconds = 24 * 60 * 60
+)
+
+func statsResponse(s *models.UserStats) map[string]interface{} {
+	return map[string]interface{}{
+		"networks_contributed": s.NetworksContributed,
+		"successful_connects":  s.SuccessfulConnects,
+		"bytes_transferred":    s.BytesTransferred,
+		"session_seconds":      s.SessionSeconds,
+		"data_saved_bytes":     s.DataSavedBytes(),
+		"cities_covered":       s.CitiesCovered(),
+		"current_streak":       s.CurrentStreak,
+		"longest_streak":       s.LongestStreak,
+		"last_active_day":      s.LastActiveDay,
+	}
+}
+
+// StatsGet handles GET /api/stats
+func (h *Handlers) StatsGet(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	stats, err := h.Stats.Get(r.Context(), auth.SubjectFromContext(r.Context()))
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load statistics")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(statsResponse(stats))
+}
+
+// StatsPatch handles PATCH /api/stats
+// Expects JSON body: { "idempotency_key": "...", "bytes_transferred": 1024, "session_duration_seconds": 60 }
+// The key may also be sent as an Idempotency-Key header; replays are not
+// counted again.
+func (h *Handlers) StatsPatch(w http.ResponseWriter, r *http.Request, _ httprouter.Params) {
+	var req struct {
+		IdempotencyKey         string `json:"idempotency_key"`
+		BytesTransferred       int64  `json:"bytes_transferred"`
+		SessionDurationSeconds int64  `json:"session_duration_seconds"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Write(w, r, problem.InvalidBody, "Invalid request body")
+		return
+	}
+	if req.IdempotencyKey == "" {
+		req.IdempotencyKey = r.Header.Get("Idempotency-Key")
+	}
+	if req.IdempotencyKey == "" || len(req.IdempotencyKey) > 128 {
+		problem.Validation(w, r, problem.FieldError{Field: "idempotency_key", Message: "is required (max 128 characters)"})
+		return
+	}
+	if req.BytesTransferred < 0 || req.BytesTransferred > maxReportedBytes {
+		problem.Validation(w, r, problem.FieldError{
+			Field:   "bytes_transferred",
+			Message: fmt.Sprintf("must be between 0 and %d", int64(maxReportedBytes)),
+		})
+		return
+	}
+	if req.SessionDurationSeconds < 0 || req.SessionDurationSeconds > maxReportedSeconds {
+		problem.Validation(w, r, problem.FieldError{
+			Field:   "session_duration_seconds",
+			Message: fmt.Sprintf("must be between 0 and %d", maxReportedSeconds),
+		})
+		return
+	}
+	if req.BytesTransferred == 0 && req.SessionDurationSeconds == 0 {
+		problem.Validation(w, r, problem.FieldError{Field: "bytes_transferred", Message: "bytes_transferred or session_duration_seconds must be set"})
+		return
+	}
+
+	ctx := r.Context()
+	userID := auth.SubjectFromContext(ctx)
+	applied, err := h.Stats.RecordOnce(ctx, userID, req.IdempotencyKey, store.StatsEvent{
+		BytesTransferred: req.BytesTransferred,
+		SessionSeconds:   req.SessionDurationSeconds,
+		At:               time.Now(),
+	})
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to update statistics")
+		return
+	}
+	stats, err := h.Stats.Get(ctx, userID)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load statistics")
+		return
+	}
+
+	resp := statsResponse(stats)
+	resp["applied"] = applied
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// recordStats applies a server-side event. Failures are logged rather than
+// failing the request that triggered them.
+func (h *Handlers) recordStats(r *http.Request, e store.StatsEvent) {
+	h.recordStatsFor(r, auth.SubjectFromContext(r.Context()), e)
+}
+
+// recordStatsFor is recordStats for requests made on a user's behalf
+// without their login, such as ticket redemption.
+func (h *Handlers) recordStatsFor(r *http.Request, userID string, e store.StatsEvent) {
+	e.At = time.Now()
+	if err := h.Stats.Record(r.Context(), userID, e); err != nil {
+		h.logger(r).Error("failed to record statistics", "err", err)
+	}
+}
+
+// connectStats is the event for a successful connect to wifi.
+func connectStats(wifi *models.WiFi) store.StatsEvent {
+	e := store.StatsEvent{SuccessfulConnects: 1}
+	if len(wifi.Location.Coordinates) == 2 {
+		e.CityCell = cityCell(wifi.Location.Coordinates[1], wifi.Location.Coordinates[0])
+	}
+	return e
+}
+
+// cityCell buckets a position into a ~11 km grid cell, used as a proxy for
+// the city it is in.
+func cityCell(lat, lng float64) string {
+	return fmt.Sprintf("%.1f,%.1f", lat, lng)
+}
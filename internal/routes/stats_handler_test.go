@@ -0,0 +1,137 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// addTestWiFi stores an open network at lng, lat and returns it.
+func addTestWiFi(t *testing.T, h *Handlers, ssid, password string, lng, lat float64) *models.WiFi {
+	t.Helper()
+	wifi := &models.WiFi{
+		ID:          primitive.NewObjectID(),
+		SSID:        ssid,
+		Password:    password,
+		Description: "test network",
+		Location:    models.Location{Type: "Point", Coordinates: []float64{lng, lat}},
+		CreatedBy:   "owner",
+	}
+	if err := h.WiFi.Insert(context.Background(), wifi); err != nil {
+		t.Fatal(err)
+	}
+	return wifi
+}
+
+func userStats(t *testing.T, h *Handlers, userID string) *models.UserStats {
+	t.Helper()
+	stats, err := h.Stats.Get(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return stats
+}
+
+func successfulConnects(t *testing.T, h *Handlers, userID string) int64 {
+	t.Helper()
+	return userStats(t, h, userID).SuccessfulConnects
+}
+
+func TestConnectCountsPasswordRevealsOnce(t *testing.T) {
+	h := newTestHandlers(t)
+	wifi := addTestWiFi(t, h, "cafe", "secret", 13.4, 52.5)
+
+	far := `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": 48.1, "longitude": 11.5}`
+	expectStatus(t, serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", far, "u1"), http.StatusForbidden)
+	if n := successfulConnects(t, h, "u1"); n != 0 {
+		t.Fatalf("successful_connects = %d after a refused connect, want 0", n)
+	}
+
+	near := `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": 52.5, "longitude": 13.4}`
+	expectStatus(t, serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", near, "u1"), http.StatusOK)
+	if n := successfulConnects(t, h, "u1"); n != 1 {
+		t.Errorf("successful_connects = %d after a reveal, want 1", n)
+	}
+}
+
+func TestConnectCountsTicketsWhenRedeemed(t *testing.T) {
+	h := newTestHandlers(t)
+	wifi := addTestWiFi(t, h, "cafe", "secret", 13.4, 52.5)
+
+	body := `{"wifi_id": "` + wifi.ID.Hex() + `", "latitude": 52.5, "longitude": 13.4, "mode": "ticket"}`
+	rec := serve(h.WiFiConnect, http.MethodPost, "/api/wifi/connect", body, "u1")
+	expectStatus(t, rec, http.StatusCreated)
+	if n := successfulConnects(t, h, "u1"); n != 0 {
+		t.Fatalf("successful_connects = %d for an unredeemed ticket, want 0", n)
+	}
+
+	var issued struct {
+		Ticket string `json:"ticket"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&issued); err != nil {
+		t.Fatal(err)
+	}
+	redeem := `{"ticket": "` + issued.Ticket + `"}`
+	expectStatus(t, serve(h.ConnectTicketRedeem, http.MethodPost, "/api/wifi/connect/ticket/redeem", redeem, ""), http.StatusOK)
+	if n := successfulConnects(t, h, "u1"); n != 1 {
+		t.Errorf("successful_connects = %d after redeeming, want 1", n)
+	}
+
+	expectStatus(t, serve(h.ConnectTicketRedeem, http.MethodPost, "/api/wifi/connect/ticket/redeem", redeem, ""), http.StatusGone)
+	if n := successfulConnects(t, h, "u1"); n != 1 {
+		t.Errorf("successful_connects = %d after a replay, want 1", n)
+	}
+}
+
+func TestStatsPatchIsIdempotent(t *testing.T) {
+	h := newTestHandlers(t)
+	body := `{"idempotency_key": "k1", "bytes_transferred": 1024, "session_duration_seconds": 60}`
+
+	var resp struct {
+		Applied          bool  `json:"applied"`
+		BytesTransferred int64 `json:"bytes_transferred"`
+	}
+	for i, wantApplied := range []bool{true, false} {
+		rec := serve(h.StatsPatch, http.MethodPatch, "/api/stats", body, "u1")
+		expectStatus(t, rec, http.StatusOK)
+		if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+			t.Fatal(err)
+		}
+		if resp.Applied != wantApplied || resp.BytesTransferred != 1024 {
+			t.Errorf("PATCH #%d = applied %v, %d bytes; want %v, 1024", i+1, resp.Applied, resp.BytesTransferred, wantApplied)
+		}
+	}
+
+	rec := serve(h.StatsGet, http.MethodGet, "/api/stats", "", "u1")
+	expectStatus(t, rec, http.StatusOK)
+	var got map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+		t.Fatal(err)
+	}
+	if got["bytes_transferred"] != float64(1024) || got["session_seconds"] != float64(60) {
+		t.Errorf("GET /api/stats = %v, want the event counted once", got)
+	}
+	if other := userStats(t, h, "u2"); other.BytesTransferred != 0 {
+		t.Errorf("another user's bytes_transferred = %d, want 0", other.BytesTransferred)
+	}
+}
+
+func TestStatsPatchRejectsInvalidEvents(t *testing.T) {
+	h := newTestHandlers(t)
+	for name, body := range map[string]string{
+		"no key":         `{"bytes_transferred": 1}`,
+		"negative bytes": `{"idempotency_key": "k", "bytes_transferred": -1}`,
+		"too long":       `{"idempotency_key": "k", "session_duration_seconds": 86401}`,
+		"empty":          `{"idempotency_key": "k"}`,
+	} {
+		rec := serve(h.StatsPatch, http.MethodPatch, "/api/stats", body, "u1")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("%s: status = %d, want 400", name, rec.Code)
+		}
+	}
+}
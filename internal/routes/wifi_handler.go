@@ -0,0 +1,248 @@
+package routes
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/problem"
+	"wifi-go-backend/internal/secrets"
+	"wifi-go-backend/internal/store"
+
+	"github.com/julienschmidt/httprouter"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// maxWiFiPatchBytes bounds a PATCH body; a full network is well under it.
+const maxWiFiPatchBytes = 64 << 10
+
+// wifiETag is a network's entity tag: its revision, quoted.
+func wifiETag(wifi *models.WiFi) string {
+	return `"` + strconv.FormatInt(wifi.Revision, 10) + `"`
+}
+
+// etagMatches reports whether an If-Match or If-None-Match header value
+// lists etag or is "*". Weak tags never match, as If-Match requires a
+// strong comparison.
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || tag == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// wifiDetail is a network as GET /api/wifi/:id returns it. The contributor
+// is only named to callers who may manage the network.
+func wifiDetail(wifi *models.WiFi, withOwner bool) map[string]interface{} {
+	item := wifiSummary(wifi)
+	item["revision"] = wifi.Revision
+	if withOwner && wifi.CreatedBy != "" {
+		item["created_by"] = wifi.CreatedBy
+	}
+	if !wifi.CreatedAt.IsZero() {
+		item["created_at"] = wifi.CreatedAt
+		item["updated_at"] = wifi.UpdatedAt
+	}
+	return item
+}
+
+// loadWiFi resolves the :id parameter. On failure it writes the response and
+// returns nil.
+func (h *Handlers) loadWiFi(w http.ResponseWriter, r *http.Request, ps httprouter.Params) *models.WiFi {
+	id, err := primitive.ObjectIDFromHex(ps.ByName("id"))
+	if err != nil {
+		problem.Write(w, r, problem.InvalidWiFiID, "Invalid WiFi ID")
+		return nil
+	}
+	wifi, err := h.WiFi.FindByID(r.Context(), id)
+	if errors.Is(err, store.ErrNotFound) {
+		problem.Write(w, r, problem.WiFiNotFound, "WiFi not found")
+		return nil
+	}
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load WiFi")
+		return nil
+	}
+	return wifi
+}
+
+// authorizeWiFiChange checks that the caller may change wifi and that the
+// request's If-Match header names its current revision. On failure it
+// writes the response and returns false.
+func (h *Handlers) authorizeWiFiChange(w http.ResponseWriter, r *http.Request, wifi *models.WiFi) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" {
+		problem.Write(w, r, problem.PreconditionRequired, "Send If-Match with the network's ETag")
+		return false
+	}
+
+	allowed, err := h.canManageWiFi(r, wifi)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load user")
+		return false
+	}
+	if !allowed {
+		problem.Write(w, r, problem.Forbidden, "Only the contributor or a moderator can change this network")
+		return false
+	}
+
+	if !etagMatches(ifMatch, wifiETag(wifi)) {
+		w.Header().Set("ETag", wifiETag(wifi))
+		problem.Write(w, r, problem.PreconditionFailed, "The network has changed; fetch it again and retry")
+		return false
+	}
+	return true
+}
+
+// canManageWiFi reports whether the caller contributed wifi or is a
+// moderator. Anonymous callers may not.
+func (h *Handlers) canManageWiFi(r *http.Request, wifi *models.WiFi) (bool, error) {
+	userID := auth.SubjectFromContext(r.Context())
+	if userID == "" {
+		return false, nil
+	}
+	if wifi.CreatedBy == userID {
+		return true, nil
+	}
+	user, err := h.Users.FindByID(r.Context(), userID)
+	if errors.Is(err, store.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.HasRole(models.RoleModerator), nil
+}
+
+// WiFiGet handles GET /api/wifi/:id
+// Returns the network with an ETag for conditional updates; answers 304 to
+// a matching If-None-Match. Signing in is optional; created_by is only
+// included for the contributor and moderators.
+func (h *Handlers) WiFiGet(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	wifi := h.loadWiFi(w, r, ps)
+	if wifi == nil {
+		return
+	}
+	withOwner, err := h.canManageWiFi(r, wifi)
+	if err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to load user")
+		return
+	}
+	etag := wifiETag(wifi)
+	w.Header().Set("ETag", etag)
+	// The body depends on who asks
+	w.Header().Set("Vary", "Authorization")
+	if inm := r.Header.Get("If-None-Match"); inm != "" && etagMatches(inm, etag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(wifiDetail(wifi, withOwner))
+}
+
+// WiFiPatch handles PATCH /api/wifi/:id
+// Requires If-Match. The body holds the fields to change, e.g.
+// { "password": "new secret", "description": "...", "location": { "coordinates": [lng, lat] } };
+// fields not sent are left as they are, and "password": "" removes the
+// password of a network that became open.
+func (h *Handlers) WiFiPatch(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	wifi := h.loadWiFi(w, r, ps)
+	if wifi == nil || !h.authorizeWiFiChange(w, r, wifi) {
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxWiFiPatchBytes))
+	if err != nil {
+		problem.Write(w, r, problem.InvalidBody, "Invalid request body")
+		return
+	}
+	var rotate struct {
+		Password *string `json:"password"`
+	}
+	// Decoding over a copy applies the patch; only the password needs to be
+	// told apart from an absent field
+	patched := *wifi
+	if json.Unmarshal(body, &patched) != nil || json.Unmarshal(body, &rotate) != nil {
+		problem.Write(w, r, problem.InvalidBody, "Invalid request body")
+		return
+	}
+	// Ownership and bookkeeping are not the client's to change
+	patched.ID = wifi.ID
+	patched.CreatedBy = wifi.CreatedBy
+	patched.CreatedAt = wifi.CreatedAt
+	patched.Revision = wifi.Revision
+	patched.Password = wifi.Password
+	if errs := validateWiFi(&patched); len(errs) > 0 {
+		problem.Validation(w, r, errs...)
+		return
+	}
+
+	if rotate.Password != nil {
+		patched.Password = *rotate.Password
+		patched.PasswordEnc = nil
+	}
+	if err := secrets.EncryptWiFiPassword(r.Context(), h.PasswordKeys, &patched); err != nil {
+		problem.Write(w, r, problem.Internal, "Failed to encrypt WiFi password")
+		return
+	}
+	patched.UpdatedAt = time.Now().UTC()
+
+	err = h.WiFi.Update(r.Context(), &patched)
+	switch {
+	case errors.Is(err, store.ErrConflict):
+		problem.Write(w, r, problem.PreconditionFailed, "The network has changed; fetch it again and retry")
+		return
+	case errors.Is(err, store.ErrNotFound):
+		problem.Write(w, r, problem.WiFiNotFound, "WiFi not found")
+		return
+	case errors.Is(err, store.ErrDuplicate):
+		problem.Write(w, r, problem.DuplicateNetwork, "WiFi with this SSID already exists at this address")
+		return
+	case err != nil:
+		problem.Write(w, r, problem.Internal, "Failed to update WiFi")
+		return
+	}
+	if err := h.audit(r, models.AuditWiFiUpdate, patched.ID, nil); err != nil {
+		h.logger(r).Error("failed to write audit event", "err", err, "wifi_id", patched.ID.Hex())
+	}
+
+	w.Header().Set("ETag", wifiETag(&patched))
+	w.Header().Set("Content-Type", "application/json")
+	// Only the contributor or a moderator gets this far
+	json.NewEncoder(w).Encode(wifiDetail(&patched, true))
+}
+
+// WiFiDelete handles DELETE /api/wifi/:id
+// Requires If-Match. Bookmarks of the network drop out of saved lists.
+func (h *Handlers) WiFiDelete(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	wifi := h.loadWiFi(w, r, ps)
+	if wifi == nil || !h.authorizeWiFiChange(w, r, wifi) {
+		return
+	}
+
+	err := h.WiFi.Delete(r.Context(), wifi.ID, wifi.Revision)
+	switch {
+	case errors.Is(err, store.ErrConflict):
+		problem.Write(w, r, problem.PreconditionFailed, "The network has changed; fetch it again and retry")
+		return
+	case errors.Is(err, store.ErrNotFound):
+		problem.Write(w, r, problem.WiFiNotFound, "WiFi not found")
+		return
+	case err != nil:
+		problem.Write(w, r, problem.Internal, "Failed to delete WiFi")
+		return
+	}
+	if err := h.audit(r, models.AuditWiFiDelete, wifi.ID, wifi.Location.Coordinates); err != nil {
+		h.logger(r).Error("failed to write audit event", "err", err, "wifi_id", wifi.ID.Hex())
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
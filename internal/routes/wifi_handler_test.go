@@ -0,0 +1,123 @@
+package routes
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"wifi-go-backend/internal/auth"
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/store"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+// patchWiFi sends PATCH /api/wifi/:id as userID with If-Match set to the
+// network's current ETag.
+func patchWiFi(h *Handlers, id, etag, body, userID string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodPatch, "/api/wifi/"+id, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("If-Match", etag)
+	req = req.WithContext(auth.WithClaims(req.Context(), &auth.Claims{Subject: userID}))
+	rec := httptest.NewRecorder()
+	h.WiFiPatch(rec, req, httprouter.Params{{Key: "id", Value: id}})
+	return rec
+}
+
+func TestWiFiPatchRejectsCoordinatesOffTheGlobe(t *testing.T) {
+	h := newTestHandlers(t)
+	wifi := addTestWiFi(t, h, "cafe", "secret", 13.4, 52.5)
+	id := wifi.ID.Hex()
+
+	for _, coords := range []string{"[500, 2]", "[13.4, 91]", "[-181, 0]", "[0, -90.5]"} {
+		rec := patchWiFi(h, id, wifiETag(wifi), `{"location": {"coordinates": `+coords+`}}`, "owner")
+		if rec.Code != http.StatusBadRequest {
+			t.Errorf("PATCH to %s: status = %d, want 400; body: %s", coords, rec.Code, rec.Body)
+		}
+	}
+	rec := patchWiFi(h, id, wifiETag(wifi), `{"location": {"coordinates": [180, -90]}}`, "owner")
+	expectStatus(t, rec, http.StatusOK)
+}
+
+// roleUsers gives users roles, which the memory store does not keep.
+type roleUsers struct {
+	store.UserStore
+	roles map[string]string
+}
+
+func (u roleUsers) FindByID(ctx context.Context, id string) (*models.User, error) {
+	role, ok := u.roles[id]
+	if !ok {
+		return u.UserStore.FindByID(ctx, id)
+	}
+	return &models.User{ID: id, Role: role}, nil
+}
+
+func TestWiFiGetShowsContributorToOwnerAndModerators(t *testing.T) {
+	h := newTestHandlers(t)
+	h.Users = roleUsers{UserStore: h.Users, roles: map[string]string{"mod": models.RoleModerator, "admin": models.RoleAdmin}}
+	wifi := addTestWiFi(t, h, "cafe", "secret", 13.4, 52.5)
+	id := httprouter.Param{Key: "id", Value: wifi.ID.Hex()}
+
+	for caller, want := range map[string]bool{"": false, "stranger": false, "owner": true, "mod": true, "admin": true} {
+		rec := serve(h.WiFiGet, http.MethodGet, "/api/wifi/"+wifi.ID.Hex(), "", caller, id)
+		expectStatus(t, rec, http.StatusOK)
+		var got map[string]interface{}
+		if err := json.NewDecoder(rec.Body).Decode(&got); err != nil {
+			t.Fatal(err)
+		}
+		if _, shown := got["created_by"]; shown != want {
+			t.Errorf("caller %q: created_by shown = %v, want %v", caller, shown, want)
+		}
+		if _, ok := got["password"]; ok {
+			t.Errorf("caller %q: password included", caller)
+		}
+	}
+
+	// Through OptionalAuth a valid token identifies the caller, and one that
+	// fails verification is refused rather than served anonymously
+	get := auth.NewAuthenticator(h.Sessions).OptionalAuth(h.WiFiGet)
+	ctx := context.Background()
+	pair, err := h.Sessions.Issue(ctx, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revoked, err := h.Sessions.Issue(ctx, "owner")
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims, err := h.Sessions.Verify(ctx, revoked.AccessToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := h.Sessions.Logout(ctx, claims, ""); err != nil {
+		t.Fatal(err)
+	}
+	for name, tt := range map[string]struct {
+		header string
+		want   int
+	}{
+		"valid":     {"Bearer " + pair.AccessToken, http.StatusOK},
+		"revoked":   {"Bearer " + revoked.AccessToken, http.StatusUnauthorized},
+		"malformed": {"Bearer not-a-token", http.StatusUnauthorized},
+		"no bearer": {"Basic b3duZXI6cHc=", http.StatusUnauthorized},
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/api/wifi/"+wifi.ID.Hex(), nil)
+		req.Header.Set("Authorization", tt.header)
+		rec := httptest.NewRecorder()
+		get(rec, req, httprouter.Params{id})
+		if rec.Code != tt.want {
+			t.Errorf("%s token: status = %d, want %d", name, rec.Code, tt.want)
+			continue
+		}
+		if tt.want == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+			t.Errorf("%s token: no WWW-Authenticate challenge", name)
+		}
+		if tt.want == http.StatusOK && !strings.Contains(rec.Body.String(), `"created_by"`) {
+			t.Errorf("%s token: created_by hidden from the owner: %s", name, rec.Body)
+		}
+	}
+}
@@ -0,0 +1,98 @@
+// Package secrets implements envelope encryption for values stored at rest.
+// Each value gets its own AES-256-GCM data key; the data key is wrapped by a
+// KeyProvider so master keys never touch the database and can be rotated
+// without re-encrypting every record.
+package secrets
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"errors"
+	"fmt"
+
+	"wifi-go-backend/internal/models"
+)
+
+const algAES256GCM = "AES-256-GCM"
+
+var ErrDecrypt = errors.New("failed to decrypt value")
+
+// KeyProvider wraps and unwraps data keys with a master key it controls.
+type KeyProvider interface {
+	// Wrap encrypts dek with the active master key and returns its ID.
+	Wrap(ctx context.Context, dek []byte) (wrapped []byte, keyID string, err error)
+	Unwrap(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// Seal encrypts plaintext under a fresh data key. aad binds the ciphertext to
+// its context (e.g. the record ID) so it cannot be moved to another record.
+func Seal(ctx context.Context, p KeyProvider, plaintext, aad []byte) (*models.EncryptedValue, error) {
+	dek := make([]byte, 32)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, err
+	}
+	nonce, ciphertext, err := gcmSeal(dek, plaintext, aad)
+	if err != nil {
+		return nil, err
+	}
+	wrapped, keyID, err := p.Wrap(ctx, dek)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+	return &models.EncryptedValue{
+		Alg:        algAES256GCM,
+		KeyID:      keyID,
+		WrappedKey: wrapped,
+		Nonce:      nonce,
+		Ciphertext: ciphertext,
+	}, nil
+}
+
+// Open reverses Seal; aad must match the value used when sealing.
+func Open(ctx context.Context, p KeyProvider, v *models.EncryptedValue, aad []byte) ([]byte, error) {
+	if v.Alg != algAES256GCM {
+		return nil, fmt.Errorf("unsupported algorithm %q", v.Alg)
+	}
+	dek, err := p.Unwrap(ctx, v.KeyID, v.WrappedKey)
+	if err != nil {
+		return nil, err
+	}
+	return gcmOpen(dek, v.Nonce, v.Ciphertext, aad)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func gcmSeal(key, plaintext, aad []byte) (nonce, ciphertext []byte, err error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, nil, err
+	}
+	nonce = make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return nonce, gcm.Seal(nil, nonce, plaintext, aad), nil
+}
+
+func gcmOpen(key, nonce, ciphertext, aad []byte) ([]byte, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, ErrDecrypt
+	}
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, aad)
+	if err != nil {
+		return nil, ErrDecrypt
+	}
+	return plaintext, nil
+}
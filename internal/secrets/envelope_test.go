@@ -0,0 +1,229 @@
+package secrets
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testKey(b byte) []byte {
+	return bytes.Repeat([]byte{b}, 32)
+}
+
+func testProvider(t *testing.T, key []byte, retired ...[]byte) *LocalKeyProvider {
+	t.Helper()
+	p, err := NewLocalKeyProvider(key, retired...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestSealOpenRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	p := testProvider(t, testKey(1))
+	aad := []byte("record-1")
+
+	v, err := Seal(ctx, p, []byte("hunter2"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(v.Ciphertext, []byte("hunter2")) {
+		t.Error("ciphertext contains the plaintext")
+	}
+	got, err := Open(ctx, p, v, aad)
+	if err != nil {
+		t.Fatalf("Open() error = %v", err)
+	}
+	if string(got) != "hunter2" {
+		t.Errorf("Open() = %q, want hunter2", got)
+	}
+
+	// Every value gets its own data key and nonce
+	again, err := Seal(ctx, p, []byte("hunter2"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(v.WrappedKey, again.WrappedKey) || bytes.Equal(v.Nonce, again.Nonce) {
+		t.Error("two seals share a data key or nonce")
+	}
+}
+
+func TestOpenRejectsWrongAAD(t *testing.T) {
+	ctx := context.Background()
+	p := testProvider(t, testKey(1))
+	v, err := Seal(ctx, p, []byte("hunter2"), []byte("record-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(ctx, p, v, []byte("record-2")); !errors.Is(err, ErrDecrypt) {
+		t.Errorf("Open() with another record's AAD error = %v, want ErrDecrypt", err)
+	}
+}
+
+func TestWiFiPasswordCannotMoveBetweenRecords(t *testing.T) {
+	ctx := context.Background()
+	p := testProvider(t, testKey(1))
+	a := &models.WiFi{ID: primitive.NewObjectID(), Password: "secret-a"}
+	b := &models.WiFi{ID: primitive.NewObjectID(), Password: "secret-b"}
+	for _, w := range []*models.WiFi{a, b} {
+		if err := EncryptWiFiPassword(ctx, p, w); err != nil {
+			t.Fatal(err)
+		}
+		if w.Password != "" || w.PasswordEnc == nil {
+			t.Fatalf("EncryptWiFiPassword left Password = %q, PasswordEnc = %v", w.Password, w.PasswordEnc)
+		}
+	}
+
+	got, err := DecryptWiFiPassword(ctx, p, a)
+	if err != nil || got != "secret-a" {
+		t.Fatalf("DecryptWiFiPassword(a) = %q, %v; want secret-a", got, err)
+	}
+
+	// Swap the ciphertexts: each record's ID no longer matches its AAD
+	a.PasswordEnc, b.PasswordEnc = b.PasswordEnc, a.PasswordEnc
+	for name, w := range map[string]*models.WiFi{"a": a, "b": b} {
+		if _, err := DecryptWiFiPassword(ctx, p, w); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("DecryptWiFiPassword(%s) after a swap error = %v, want ErrDecrypt", name, err)
+		}
+	}
+}
+
+func TestOpenRejectsTampering(t *testing.T) {
+	ctx := context.Background()
+	p := testProvider(t, testKey(1))
+	aad := []byte("record-1")
+
+	tests := map[string]func(v *models.EncryptedValue){
+		"ciphertext":  func(v *models.EncryptedValue) { v.Ciphertext[0] ^= 1 },
+		"tag":         func(v *models.EncryptedValue) { v.Ciphertext[len(v.Ciphertext)-1] ^= 1 },
+		"truncated":   func(v *models.EncryptedValue) { v.Ciphertext = v.Ciphertext[:len(v.Ciphertext)-1] },
+		"nonce":       func(v *models.EncryptedValue) { v.Nonce[0] ^= 1 },
+		"short nonce": func(v *models.EncryptedValue) { v.Nonce = v.Nonce[:4] },
+		"wrapped key": func(v *models.EncryptedValue) { v.WrappedKey[len(v.WrappedKey)-1] ^= 1 },
+		"short wrapped key": func(v *models.EncryptedValue) {
+			v.WrappedKey = v.WrappedKey[:8]
+		},
+	}
+	for name, tamper := range tests {
+		v, err := Seal(ctx, p, []byte("hunter2"), aad)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tamper(v)
+		if _, err := Open(ctx, p, v, aad); !errors.Is(err, ErrDecrypt) {
+			t.Errorf("%s: Open() error = %v, want ErrDecrypt", name, err)
+		}
+	}
+}
+
+func TestOpenRejectsUnknownAlgorithm(t *testing.T) {
+	ctx := context.Background()
+	p := testProvider(t, testKey(1))
+	v, err := Seal(ctx, p, []byte("hunter2"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Alg = "none"
+	if _, err := Open(ctx, p, v, nil); err == nil {
+		t.Error("Open() accepted an unknown algorithm")
+	}
+}
+
+func TestKeyRotation(t *testing.T) {
+	ctx := context.Background()
+	oldKey, newKey := testKey(1), testKey(2)
+	aad := []byte("record-1")
+
+	v, err := Seal(ctx, testProvider(t, oldKey), []byte("hunter2"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The old key is retired but still unwraps what it sealed
+	rotated := testProvider(t, newKey, oldKey)
+	got, err := Open(ctx, rotated, v, aad)
+	if err != nil || string(got) != "hunter2" {
+		t.Fatalf("Open() with a retired key = %q, %v; want hunter2", got, err)
+	}
+
+	// New values are sealed with the new key
+	fresh, err := Seal(ctx, rotated, []byte("hunter2"), aad)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fresh.KeyID == v.KeyID {
+		t.Errorf("KeyID = %q after rotation, want the new key", fresh.KeyID)
+	}
+	if _, err := Open(ctx, testProvider(t, newKey), fresh, aad); err != nil {
+		t.Errorf("Open() with only the new key error = %v", err)
+	}
+
+	// Once the old key is dropped, its values can no longer be opened
+	if _, err := Open(ctx, testProvider(t, newKey), v, aad); err == nil {
+		t.Error("Open() succeeded without the key that sealed the value")
+	}
+}
+
+func TestNewLocalKeyProviderRejectsShortKeys(t *testing.T) {
+	if _, err := NewLocalKeyProvider(make([]byte, 16)); err == nil {
+		t.Error("NewLocalKeyProvider accepted a 16-byte key")
+	}
+	if _, err := NewLocalKeyProvider(testKey(1), make([]byte, 31)); err == nil {
+		t.Error("NewLocalKeyProvider accepted a 31-byte retired key")
+	}
+}
+
+func TestLoadLocalKeyFile(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, key []byte) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	active := write("active.key", testKey(2))
+	retired := write("retired.key", testKey(1))
+
+	p, err := LoadLocalKeyFile(active, retired)
+	if err != nil {
+		t.Fatalf("LoadLocalKeyFile() error = %v", err)
+	}
+	ctx := context.Background()
+	v, err := Seal(ctx, testProvider(t, testKey(1)), []byte("hunter2"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Open(ctx, p, v, nil); err != nil {
+		t.Errorf("Open() with the retired key file error = %v", err)
+	}
+
+	notBase64 := filepath.Join(dir, "bad.key")
+	os.WriteFile(notBase64, []byte("not base64!"), 0o600)
+	if _, err := LoadLocalKeyFile(notBase64); err == nil {
+		t.Error("LoadLocalKeyFile accepted a file that is not base64")
+	}
+}
+
+func TestDecryptWiFiPasswordPassesThroughPlaintext(t *testing.T) {
+	p := testProvider(t, testKey(1))
+	w := &models.WiFi{ID: primitive.NewObjectID(), Password: "legacy"}
+	got, err := DecryptWiFiPassword(context.Background(), p, w)
+	if err != nil || got != "legacy" {
+		t.Errorf("DecryptWiFiPassword() = %q, %v; want the unmigrated plaintext", got, err)
+	}
+
+	open := &models.WiFi{ID: primitive.NewObjectID()}
+	if err := EncryptWiFiPassword(context.Background(), p, open); err != nil || open.PasswordEnc != nil {
+		t.Errorf("EncryptWiFiPassword on an open network = %v, %v; want no ciphertext", open.PasswordEnc, err)
+	}
+}
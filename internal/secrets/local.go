@@ -0,0 +1,81 @@
+package secrets
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// LocalKeyProvider wraps data keys with a master key held in process,
+// loaded from a key file. Intended for development and tests; production
+// deployments can plug in a KMS-backed KeyProvider instead.
+type LocalKeyProvider struct {
+	activeID string
+	keys     map[string][]byte
+}
+
+// NewLocalKeyProvider uses key (32 bytes) as the active master key. Extra
+// keys remain usable for unwrapping, which allows rotating the active key.
+func NewLocalKeyProvider(key []byte, retired ...[]byte) (*LocalKeyProvider, error) {
+	p := &LocalKeyProvider{keys: map[string][]byte{}}
+	for i, k := range append([][]byte{key}, retired...) {
+		if len(k) != 32 {
+			return nil, errors.New("master key must be 32 bytes")
+		}
+		id := localKeyID(k)
+		p.keys[id] = k
+		if i == 0 {
+			p.activeID = id
+		}
+	}
+	return p, nil
+}
+
+// LoadLocalKeyFile reads a base64-encoded 32-byte master key, e.g. created
+// with `head -c 32 /dev/urandom | base64 > wifi.key`.
+func LoadLocalKeyFile(path string, retiredPaths ...string) (*LocalKeyProvider, error) {
+	var keys [][]byte
+	for _, p := range append([]string{path}, retiredPaths...) {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read key file: %w", err)
+		}
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil {
+			return nil, fmt.Errorf("key file %s is not base64: %w", p, err)
+		}
+		keys = append(keys, key)
+	}
+	return NewLocalKeyProvider(keys[0], keys[1:]...)
+}
+
+// A key's ID is derived from the key itself so it needs no extra config.
+func localKeyID(key []byte) string {
+	sum := sha256.Sum256(key)
+	return "local:" + hex.EncodeToString(sum[:8])
+}
+
+func (p *LocalKeyProvider) Wrap(_ context.Context, dek []byte) ([]byte, string, error) {
+	nonce, ciphertext, err := gcmSeal(p.keys[p.activeID], dek, []byte(p.activeID))
+	if err != nil {
+		return nil, "", err
+	}
+	return append(nonce, ciphertext...), p.activeID, nil
+}
+
+func (p *LocalKeyProvider) Unwrap(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	key, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("unknown master key %q", keyID)
+	}
+	const nonceSize = 12
+	if len(wrapped) < nonceSize {
+		return nil, ErrDecrypt
+	}
+	return gcmOpen(key, wrapped[:nonceSize], wrapped[nonceSize:], []byte(keyID))
+}
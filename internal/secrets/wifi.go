@@ -0,0 +1,36 @@
+package secrets
+
+import (
+	"context"
+
+	"wifi-go-backend/internal/models"
+)
+
+// EncryptWiFiPassword moves wifi.Password into wifi.PasswordEnc. The record ID
+// is used as AAD, so wifi.ID must be set first. Open networks (no password)
+// are left as they are.
+func EncryptWiFiPassword(ctx context.Context, p KeyProvider, wifi *models.WiFi) error {
+	if wifi.Password == "" {
+		return nil
+	}
+	enc, err := Seal(ctx, p, []byte(wifi.Password), wifi.ID[:])
+	if err != nil {
+		return err
+	}
+	wifi.PasswordEnc = enc
+	wifi.Password = ""
+	return nil
+}
+
+// DecryptWiFiPassword returns the network's password. Records that have not
+// been migrated yet still carry it in plaintext.
+func DecryptWiFiPassword(ctx context.Context, p KeyProvider, wifi *models.WiFi) (string, error) {
+	if wifi.PasswordEnc == nil {
+		return wifi.Password, nil
+	}
+	plaintext, err := Open(ctx, p, wifi.PasswordEnc, wifi.ID[:])
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
@@ -0,0 +1,127 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// AuditFilter selects audit events. Zero fields don't filter. Results are
+// newest first; pass the last event's ID as Before to get the next page.
+type AuditFilter struct {
+	Actor  string
+	Action string
+	WiFiID primitive.ObjectID
+	From   time.Time
+	To     time.Time
+	Before primitive.ObjectID
+	Limit  int
+}
+
+// AuditStore is append-only by design: there is no update or delete.
+type AuditStore interface {
+	Append(ctx context.Context, e *models.AuditEvent) error
+	List(ctx context.Context, f AuditFilter) ([]models.AuditEvent, error)
+}
+
+type MongoAuditStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAuditStore(coll *mongo.Collection) *MongoAuditStore {
+	return &MongoAuditStore{coll: coll}
+}
+
+func (s *MongoAuditStore) Append(ctx context.Context, e *models.AuditEvent) error {
+	if e.ID.IsZero() {
+		e.ID = primitive.NewObjectID()
+	}
+	_, err := s.coll.InsertOne(ctx, e)
+	return err
+}
+
+func (s *MongoAuditStore) List(ctx context.Context, f AuditFilter) ([]models.AuditEvent, error) {
+	filter := bson.M{}
+	if f.Actor != "" {
+		filter["actor"] = f.Actor
+	}
+	if f.Action != "" {
+		filter["action"] = f.Action
+	}
+	if !f.WiFiID.IsZero() {
+		filter["wifi_id"] = f.WiFiID
+	}
+	ts := bson.M{}
+	if !f.From.IsZero() {
+		ts["$gte"] = f.From
+	}
+	if !f.To.IsZero() {
+		ts["$lt"] = f.To
+	}
+	if len(ts) > 0 {
+		filter["timestamp"] = ts
+	}
+	if !f.Before.IsZero() {
+		filter["_id"] = bson.M{"$lt": f.Before}
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "_id", Value: -1}}).SetLimit(int64(f.Limit))
+	cur, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var events []models.AuditEvent
+	if err := cur.All(ctx, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+type MemoryAuditStore struct {
+	mu     sync.Mutex
+	events []models.AuditEvent
+}
+
+func NewMemoryAuditStore() *MemoryAuditStore {
+	return &MemoryAuditStore{}
+}
+
+func (s *MemoryAuditStore) Append(_ context.Context, e *models.AuditEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e.ID.IsZero() {
+		e.ID = primitive.NewObjectID()
+	}
+	s.events = append(s.events, *e)
+	return nil
+}
+
+func (s *MemoryAuditStore) List(_ context.Context, f AuditFilter) ([]models.AuditEvent, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.AuditEvent
+	for _, e := range s.events {
+		switch {
+		case f.Actor != "" && e.Actor != f.Actor,
+			f.Action != "" && e.Action != f.Action,
+			!f.WiFiID.IsZero() && e.WiFiID != f.WiFiID,
+			!f.From.IsZero() && e.Timestamp.Before(f.From),
+			!f.To.IsZero() && !e.Timestamp.Before(f.To),
+			!f.Before.IsZero() && e.ID.Hex() >= f.Before.Hex():
+			continue
+		}
+		out = append(out, e)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID.Hex() > out[j].ID.Hex() })
+	if f.Limit > 0 && len(out) > f.Limit {
+		out = out[:f.Limit]
+	}
+	return out, nil
+}
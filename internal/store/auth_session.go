@@ -0,0 +1,83 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// AuthSessionStore records OAuth state + PKCE verifier between the start of
+// the Civic flow and its callback. Consume is single-use: a state can only be
+// redeemed once, and never after it has expired.
+type AuthSessionStore interface {
+	Create(ctx context.Context, s *models.AuthSession) error
+	Consume(ctx context.Context, state string) (*models.AuthSession, error)
+}
+
+// MongoAuthSessionStore expects a TTL index on expires_at (see
+// db.EnsureIndexes) to clean up abandoned flows.
+type MongoAuthSessionStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoAuthSessionStore(coll *mongo.Collection) *MongoAuthSessionStore {
+	return &MongoAuthSessionStore{coll: coll}
+}
+
+func (s *MongoAuthSessionStore) Create(ctx context.Context, sess *models.AuthSession) error {
+	_, err := s.coll.InsertOne(ctx, sess)
+	return err
+}
+
+func (s *MongoAuthSessionStore) Consume(ctx context.Context, state string) (*models.AuthSession, error) {
+	// The TTL monitor only runs periodically, so expiry is checked here too.
+	filter := bson.M{"_id": state, "expires_at": bson.M{"$gt": time.Now()}}
+	var sess models.AuthSession
+	err := s.coll.FindOneAndDelete(ctx, filter).Decode(&sess)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &sess, nil
+}
+
+type MemoryAuthSessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]models.AuthSession
+}
+
+func NewMemoryAuthSessionStore() *MemoryAuthSessionStore {
+	return &MemoryAuthSessionStore{sessions: map[string]models.AuthSession{}}
+}
+
+func (s *MemoryAuthSessionStore) Create(_ context.Context, sess *models.AuthSession) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.sessions[sess.State]; ok {
+		return errors.New("auth session already exists")
+	}
+	s.sessions[sess.State] = *sess
+	return nil
+}
+
+func (s *MemoryAuthSessionStore) Consume(_ context.Context, state string) (*models.AuthSession, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[state]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	delete(s.sessions, state)
+	if !time.Now().Before(sess.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	return &sess, nil
+}
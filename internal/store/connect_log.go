@@ -0,0 +1,132 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrAlreadyRedeemed = errors.New("already redeemed")
+
+// maxConnectHistory bounds what Since returns.
+const maxConnectHistory = 200
+
+type ConnectLogStore interface {
+	Record(ctx context.Context, e *models.ConnectIssuance) error
+	// Since returns the user's issuances at or after since, newest first.
+	Since(ctx context.Context, userID string, since time.Time) ([]models.ConnectIssuance, error)
+	// Redeem marks an unexpired ticket as used; a ticket redeems only once.
+	Redeem(ctx context.Context, id string) (*models.ConnectIssuance, error)
+}
+
+type MongoConnectLogStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoConnectLogStore(coll *mongo.Collection) *MongoConnectLogStore {
+	return &MongoConnectLogStore{coll: coll}
+}
+
+func (s *MongoConnectLogStore) Record(ctx context.Context, e *models.ConnectIssuance) error {
+	_, err := s.coll.InsertOne(ctx, e)
+	return err
+}
+
+func (s *MongoConnectLogStore) Since(ctx context.Context, userID string, since time.Time) ([]models.ConnectIssuance, error) {
+	filter := bson.M{"user_id": userID, "issued_at": bson.M{"$gte": since}}
+	opts := options.Find().SetSort(bson.D{{Key: "issued_at", Value: -1}}).SetLimit(maxConnectHistory)
+	cur, err := s.coll.Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+	var out []models.ConnectIssuance
+	err = cur.All(ctx, &out)
+	return out, err
+}
+
+func (s *MongoConnectLogStore) Redeem(ctx context.Context, id string) (*models.ConnectIssuance, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id":         id,
+		"mode":        models.ConnectModeTicket,
+		"redeemed_at": bson.M{"$exists": false},
+		"expires_at":  bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"redeemed_at": now}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var e models.ConnectIssuance
+	err := s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&e)
+	if err == nil {
+		return &e, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	n, err := s.coll.CountDocuments(ctx, bson.M{"_id": id, "redeemed_at": bson.M{"$exists": true}})
+	if err != nil {
+		return nil, err
+	}
+	if n > 0 {
+		return nil, ErrAlreadyRedeemed
+	}
+	return nil, ErrNotFound
+}
+
+type MemoryConnectLogStore struct {
+	mu      sync.Mutex
+	entries map[string]models.ConnectIssuance
+}
+
+func NewMemoryConnectLogStore() *MemoryConnectLogStore {
+	return &MemoryConnectLogStore{entries: map[string]models.ConnectIssuance{}}
+}
+
+func (s *MemoryConnectLogStore) Record(_ context.Context, e *models.ConnectIssuance) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries[e.ID] = *e
+	return nil
+}
+
+func (s *MemoryConnectLogStore) Since(_ context.Context, userID string, since time.Time) ([]models.ConnectIssuance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.ConnectIssuance
+	for _, e := range s.entries {
+		if e.UserID == userID && !e.IssuedAt.Before(since) {
+			out = append(out, e)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].IssuedAt.After(out[j].IssuedAt) })
+	if len(out) > maxConnectHistory {
+		out = out[:maxConnectHistory]
+	}
+	return out, nil
+}
+
+func (s *MemoryConnectLogStore) Redeem(_ context.Context, id string) (*models.ConnectIssuance, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[id]
+	if !ok || e.Mode != models.ConnectModeTicket {
+		return nil, ErrNotFound
+	}
+	if e.RedeemedAt != nil {
+		return nil, ErrAlreadyRedeemed
+	}
+	now := time.Now().UTC()
+	if !now.Before(e.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	e.RedeemedAt = &now
+	s.entries[id] = e
+	return &e, nil
+}
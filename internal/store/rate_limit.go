@@ -0,0 +1,121 @@
+package store
+
+import (
+	"context"
+	"math"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RateLimitStore keeps token buckets. A bucket holds up to capacity tokens
+// and refills at capacity tokens per interval; a missing bucket is full.
+type RateLimitStore interface {
+	// Take refills the bucket for key and removes one token if there is
+	// one. It reports whether a token was taken and how many are left.
+	Take(ctx context.Context, key string, capacity int, interval time.Duration) (allowed bool, remaining float64, err error)
+}
+
+// MongoRateLimitStore updates each bucket with a single pipeline update, so
+// replicas sharing the collection share the limits. Buckets are timed with
+// the server's clock and expire once they would be full again.
+type MongoRateLimitStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoRateLimitStore(coll *mongo.Collection) *MongoRateLimitStore {
+	return &MongoRateLimitStore{coll: coll}
+}
+
+func (s *MongoRateLimitStore) Take(ctx context.Context, key string, capacity int, interval time.Duration) (bool, float64, error) {
+	perMs := float64(capacity) / float64(interval.Milliseconds())
+	elapsedMs := bson.M{"$max": bson.A{0, bson.M{"$subtract": bson.A{"$$NOW", bson.M{"$ifNull": bson.A{"$updated_at", "$$NOW"}}}}}}
+	hasToken := bson.M{"$gte": bson.A{"$tokens", 1}}
+	update := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"tokens": bson.M{"$min": bson.A{capacity, bson.M{"$add": bson.A{
+				bson.M{"$ifNull": bson.A{"$tokens", capacity}},
+				bson.M{"$multiply": bson.A{elapsedMs, perMs}},
+			}}}},
+			"updated_at": "$$NOW",
+			"expires_at": bson.M{"$add": bson.A{"$$NOW", interval.Milliseconds()}},
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"allowed": hasToken,
+			"tokens":  bson.M{"$cond": bson.A{hasToken, bson.M{"$subtract": bson.A{"$tokens", 1}}, "$tokens"}},
+		}}},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+
+	var bucket struct {
+		Tokens  float64 `bson:"tokens"`
+		Allowed bool    `bson:"allowed"`
+	}
+	err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&bucket)
+	if mongo.IsDuplicateKeyError(err) {
+		// Lost the race to create the bucket; it exists now
+		err = s.coll.FindOneAndUpdate(ctx, bson.M{"_id": key}, update, opts).Decode(&bucket)
+	}
+	if err != nil {
+		return false, 0, err
+	}
+	return bucket.Allowed, bucket.Tokens, nil
+}
+
+type memoryBucket struct {
+	tokens  float64
+	updated time.Time
+	full    time.Time // when the bucket will have refilled completely
+}
+
+// MemoryRateLimitStore keeps buckets in process; each replica enforces its
+// own limits.
+type MemoryRateLimitStore struct {
+	mu      sync.Mutex
+	buckets map[string]*memoryBucket
+	takes   int
+
+	// Now is overridable for tests; defaults to time.Now.
+	Now func() time.Time
+}
+
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{buckets: map[string]*memoryBucket{}}
+}
+
+func (s *MemoryRateLimitStore) Take(_ context.Context, key string, capacity int, interval time.Duration) (bool, float64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	if s.Now != nil {
+		now = s.Now()
+	}
+	s.takes++
+	if s.takes%1024 == 0 {
+		// Full buckets carry no state, so drop them
+		for k, b := range s.buckets {
+			if !now.Before(b.full) {
+				delete(s.buckets, k)
+			}
+		}
+	}
+
+	limit := float64(capacity)
+	perSecond := limit / interval.Seconds()
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: limit, updated: now}
+		s.buckets[key] = b
+	}
+	b.tokens = math.Min(limit, b.tokens+now.Sub(b.updated).Seconds()*perSecond)
+	b.updated = now
+	allowed := b.tokens >= 1
+	if allowed {
+		b.tokens--
+	}
+	b.full = now.Add(time.Duration((limit - b.tokens) / perSecond * float64(time.Second)))
+	return allowed, b.tokens, nil
+}
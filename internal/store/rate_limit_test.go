@@ -0,0 +1,93 @@
+package store
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"testing"
+	"time"
+
+	"wifi-go-backend/internal/db"
+)
+
+// fakeClock is a settable time source for the memory store.
+type fakeClock struct{ now time.Time }
+
+func (c *fakeClock) Now() time.Time          { return c.now }
+func (c *fakeClock) Advance(d time.Duration) { c.now = c.now.Add(d) }
+
+// testBucket checks the token bucket contract every RateLimitStore keeps:
+// a burst of capacity, refusal when empty, then refill at capacity per
+// interval. wait lets time pass.
+func testBucket(t *testing.T, s RateLimitStore, key string, wait func(time.Duration)) {
+	t.Helper()
+	ctx := context.Background()
+	take := func() (bool, float64) {
+		t.Helper()
+		allowed, remaining, err := s.Take(ctx, key, 3, time.Second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return allowed, remaining
+	}
+	for i := 2; i >= 0; i-- {
+		if allowed, remaining := take(); !allowed || math.Floor(remaining) != float64(i) {
+			t.Fatalf("take %d = %v, %v remaining; want allowed with %d", 3-i, allowed, remaining, i)
+		}
+	}
+	if allowed, _ := take(); allowed {
+		t.Fatal("take from an empty bucket was allowed")
+	}
+	// A third of the interval refills one token
+	wait(400 * time.Millisecond)
+	if allowed, remaining := take(); !allowed || remaining >= 1 {
+		t.Fatalf("take after refill = %v, %v remaining; want allowed with none left", allowed, remaining)
+	}
+	if allowed, _ := take(); allowed {
+		t.Fatal("take beyond the refill was allowed")
+	}
+	// Refill stops at capacity
+	wait(5 * time.Second)
+	if _, remaining := take(); math.Floor(remaining) != 2 {
+		t.Fatalf("remaining after a long wait = %v, want 2", remaining)
+	}
+}
+
+func TestMemoryRateLimitStore(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewMemoryRateLimitStore()
+	s.Now = clock.Now
+	testBucket(t, s, "a", clock.Advance)
+
+	// Buckets are independent
+	if allowed, remaining, _ := s.Take(context.Background(), "b", 3, time.Second); !allowed || remaining != 2 {
+		t.Errorf("take from a new bucket = %v, %v remaining; want allowed with 2", allowed, remaining)
+	}
+}
+
+func TestMemoryRateLimitStoreDropsFullBuckets(t *testing.T) {
+	clock := &fakeClock{now: time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)}
+	s := NewMemoryRateLimitStore()
+	s.Now = clock.Now
+	for i := 0; i < 1023; i++ {
+		s.Take(context.Background(), fmt.Sprint(i), 10, time.Minute)
+	}
+	clock.Advance(time.Minute)
+	s.Take(context.Background(), "last", 10, time.Minute)
+	if len(s.buckets) != 1 {
+		t.Errorf("%d buckets kept, want only the one just used", len(s.buckets))
+	}
+}
+
+// TestMongoRateLimitStore needs a MongoDB server: set MONGO_TEST_URI to run
+// it. Buckets are timed by the server, so the waits are real.
+func TestMongoRateLimitStore(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	connectTestDatabase(t, ctx)
+	coll, err := db.GetRateLimitCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	testBucket(t, NewMongoRateLimitStore(coll), "a", time.Sleep)
+}
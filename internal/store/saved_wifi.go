@@ -0,0 +1,123 @@
+package store
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// SavedWiFiStore keeps per-user bookmarks of networks. Save is idempotent.
+type SavedWiFiStore interface {
+	Save(ctx context.Context, userID string, wifiID primitive.ObjectID) (*models.SavedWiFi, error)
+	Remove(ctx context.Context, userID string, wifiID primitive.ObjectID) error
+	// List returns the user's bookmarks, most recently saved first.
+	List(ctx context.Context, userID string) ([]models.SavedWiFi, error)
+}
+
+// MongoSavedWiFiStore relies on the unique (user_id, wifi_id) index created
+// by db.EnsureIndexes.
+type MongoSavedWiFiStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoSavedWiFiStore(coll *mongo.Collection) *MongoSavedWiFiStore {
+	return &MongoSavedWiFiStore{coll: coll}
+}
+
+func (s *MongoSavedWiFiStore) Save(ctx context.Context, userID string, wifiID primitive.ObjectID) (*models.SavedWiFi, error) {
+	filter := bson.M{"user_id": userID, "wifi_id": wifiID}
+	update := bson.M{"$setOnInsert": bson.M{"saved_at": time.Now().UTC()}}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var saved models.SavedWiFi
+	err := s.coll.FindOneAndUpdate(ctx, filter, update, opts).Decode(&saved)
+	if mongo.IsDuplicateKeyError(err) {
+		// Lost a race with a concurrent save of the same bookmark
+		err = s.coll.FindOne(ctx, filter).Decode(&saved)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &saved, nil
+}
+
+func (s *MongoSavedWiFiStore) Remove(ctx context.Context, userID string, wifiID primitive.ObjectID) error {
+	res, err := s.coll.DeleteOne(ctx, bson.M{"user_id": userID, "wifi_id": wifiID})
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *MongoSavedWiFiStore) List(ctx context.Context, userID string) ([]models.SavedWiFi, error) {
+	opts := options.Find().SetSort(bson.D{{Key: "saved_at", Value: -1}})
+	cur, err := s.coll.Find(ctx, bson.M{"user_id": userID}, opts)
+	if err != nil {
+		return nil, err
+	}
+	var saved []models.SavedWiFi
+	if err := cur.All(ctx, &saved); err != nil {
+		return nil, err
+	}
+	return saved, nil
+}
+
+type savedKey struct {
+	userID string
+	wifiID primitive.ObjectID
+}
+
+type MemorySavedWiFiStore struct {
+	mu    sync.Mutex
+	saved map[savedKey]models.SavedWiFi
+}
+
+func NewMemorySavedWiFiStore() *MemorySavedWiFiStore {
+	return &MemorySavedWiFiStore{saved: map[savedKey]models.SavedWiFi{}}
+}
+
+func (s *MemorySavedWiFiStore) Save(_ context.Context, userID string, wifiID primitive.ObjectID) (*models.SavedWiFi, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := savedKey{userID, wifiID}
+	saved, ok := s.saved[key]
+	if !ok {
+		saved = models.SavedWiFi{UserID: userID, WiFiID: wifiID, SavedAt: time.Now().UTC()}
+		s.saved[key] = saved
+	}
+	return &saved, nil
+}
+
+func (s *MemorySavedWiFiStore) Remove(_ context.Context, userID string, wifiID primitive.ObjectID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := savedKey{userID, wifiID}
+	if _, ok := s.saved[key]; !ok {
+		return ErrNotFound
+	}
+	delete(s.saved, key)
+	return nil
+}
+
+func (s *MemorySavedWiFiStore) List(_ context.Context, userID string) ([]models.SavedWiFi, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var out []models.SavedWiFi
+	for key, saved := range s.saved {
+		if key.userID == userID {
+			out = append(out, saved)
+		}
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].SavedAt.After(out[j].SavedAt) })
+	return out, nil
+}
@@ -0,0 +1,186 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// StatsEvent is a delta applied to a user's statistics. Any activity also
+// counts towards the daily streak.
+type StatsEvent struct {
+	NetworksContributed int64
+	SuccessfulConnects  int64
+	BytesTransferred    int64
+	SessionSeconds      int64
+	CityCell            string // optional
+	At                  time.Time
+}
+
+type StatsStore interface {
+	// Get returns the user's stats, or zero stats if nothing was recorded.
+	Get(ctx context.Context, userID string) (*models.UserStats, error)
+	Record(ctx context.Context, userID string, e StatsEvent) error
+	// RecordOnce applies e unless key was already used by this user, so
+	// client retries don't double count. It reports whether e was applied.
+	RecordOnce(ctx context.Context, userID, key string, e StatsEvent) (bool, error)
+}
+
+func day(t time.Time) string {
+	return t.UTC().Format("2006-01-02")
+}
+
+type MongoStatsStore struct {
+	coll *mongo.Collection
+	keys *mongo.Collection
+	// KeyTTL is how long idempotency keys are remembered.
+	KeyTTL time.Duration
+}
+
+func NewMongoStatsStore(coll, keys *mongo.Collection) *MongoStatsStore {
+	return &MongoStatsStore{coll: coll, keys: keys, KeyTTL: 24 * time.Hour}
+}
+
+func (s *MongoStatsStore) Get(ctx context.Context, userID string) (*models.UserStats, error) {
+	stats := models.UserStats{UserID: userID}
+	err := s.coll.FindOne(ctx, bson.M{"_id": userID}).Decode(&stats)
+	if err != nil && !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	return &stats, nil
+}
+
+func (s *MongoStatsStore) Record(ctx context.Context, userID string, e StatsEvent) error {
+	today := day(e.At)
+	yesterday := day(e.At.AddDate(0, 0, -1))
+	add := func(field string, n int64) bson.M {
+		return bson.M{"$add": bson.A{bson.M{"$ifNull": bson.A{"$" + field, 0}}, n}}
+	}
+	cells := bson.A{}
+	if e.CityCell != "" {
+		cells = append(cells, e.CityCell)
+	}
+
+	// A pipeline update so the streak can be derived from the stored
+	// last_active_day atomically, without a read-modify-write.
+	pipeline := mongo.Pipeline{
+		{{Key: "$set", Value: bson.M{
+			"networks_contributed": add("networks_contributed", e.NetworksContributed),
+			"successful_connects":  add("successful_connects", e.SuccessfulConnects),
+			"bytes_transferred":    add("bytes_transferred", e.BytesTransferred),
+			"session_seconds":      add("session_seconds", e.SessionSeconds),
+			"city_cells":           bson.M{"$setUnion": bson.A{bson.M{"$ifNull": bson.A{"$city_cells", bson.A{}}}, cells}},
+			"current_streak": bson.M{"$switch": bson.M{
+				"branches": bson.A{
+					bson.M{"case": bson.M{"$eq": bson.A{"$last_active_day", today}}, "then": "$current_streak"},
+					bson.M{"case": bson.M{"$eq": bson.A{"$last_active_day", yesterday}}, "then": add("current_streak", 1)},
+				},
+				"default": 1,
+			}},
+			"updated_at": e.At.UTC(),
+		}}},
+		{{Key: "$set", Value: bson.M{
+			"longest_streak":  bson.M{"$max": bson.A{bson.M{"$ifNull": bson.A{"$longest_streak", 0}}, "$current_streak"}},
+			"last_active_day": today,
+		}}},
+	}
+	_, err := s.coll.UpdateOne(ctx, bson.M{"_id": userID}, pipeline, options.Update().SetUpsert(true))
+	return err
+}
+
+func (s *MongoStatsStore) RecordOnce(ctx context.Context, userID, key string, e StatsEvent) (bool, error) {
+	id := userID + ":" + key
+	now := time.Now().UTC()
+	_, err := s.keys.InsertOne(ctx, bson.M{"_id": id, "created_at": now, "expires_at": now.Add(s.KeyTTL)})
+	if mongo.IsDuplicateKeyError(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if err := s.Record(ctx, userID, e); err != nil {
+		// Free the key so the client's retry can still be applied
+		s.keys.DeleteOne(ctx, bson.M{"_id": id})
+		return false, err
+	}
+	return true, nil
+}
+
+type MemoryStatsStore struct {
+	mu    sync.Mutex
+	stats map[string]models.UserStats
+	keys  map[string]bool
+}
+
+func NewMemoryStatsStore() *MemoryStatsStore {
+	return &MemoryStatsStore{stats: map[string]models.UserStats{}, keys: map[string]bool{}}
+}
+
+func (s *MemoryStatsStore) Get(_ context.Context, userID string) (*models.UserStats, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stats, ok := s.stats[userID]
+	if !ok {
+		stats = models.UserStats{UserID: userID}
+	}
+	return &stats, nil
+}
+
+func (s *MemoryStatsStore) Record(_ context.Context, userID string, e StatsEvent) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.record(userID, e)
+	return nil
+}
+
+func (s *MemoryStatsStore) RecordOnce(_ context.Context, userID, key string, e StatsEvent) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := userID + ":" + key
+	if s.keys[id] {
+		return false, nil
+	}
+	s.keys[id] = true
+	s.record(userID, e)
+	return true, nil
+}
+
+func (s *MemoryStatsStore) record(userID string, e StatsEvent) {
+	stats := s.stats[userID]
+	stats.UserID = userID
+	stats.NetworksContributed += e.NetworksContributed
+	stats.SuccessfulConnects += e.SuccessfulConnects
+	stats.BytesTransferred += e.BytesTransferred
+	stats.SessionSeconds += e.SessionSeconds
+	if e.CityCell != "" {
+		found := false
+		for _, c := range stats.CityCells {
+			found = found || c == e.CityCell
+		}
+		if !found {
+			stats.CityCells = append(stats.CityCells, e.CityCell)
+		}
+	}
+
+	today := day(e.At)
+	switch stats.LastActiveDay {
+	case today:
+	case day(e.At.AddDate(0, 0, -1)):
+		stats.CurrentStreak++
+	default:
+		stats.CurrentStreak = 1
+	}
+	if stats.CurrentStreak > stats.LongestStreak {
+		stats.LongestStreak = stats.CurrentStreak
+	}
+	stats.LastActiveDay = today
+	stats.UpdatedAt = e.At.UTC()
+	s.stats[userID] = stats
+}
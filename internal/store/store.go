@@ -0,0 +1,12 @@
+// Package store holds persistence for the backend's collections. Each store
+// is an interface with a Mongo implementation and an in-memory one for tests.
+package store
+
+import "errors"
+
+var (
+	ErrNotFound  = errors.New("not found")
+	ErrDuplicate = errors.New("duplicate")
+	// ErrConflict means the record changed since the caller read it.
+	ErrConflict = errors.New("revision conflict")
+)
@@ -0,0 +1,190 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// ErrTokenReused is returned when a refresh token that was already rotated
+// is presented again.
+var ErrTokenReused = errors.New("refresh token already used")
+
+type RefreshTokenStore interface {
+	Create(ctx context.Context, t *models.RefreshToken) error
+	FindByID(ctx context.Context, id string) (*models.RefreshToken, error)
+	// MarkUsed atomically consumes an unused, unexpired token.
+	MarkUsed(ctx context.Context, id string) (*models.RefreshToken, error)
+	RevokeFamily(ctx context.Context, familyID string) error
+}
+
+// RevocationStore is the list of access tokens revoked before their expiry.
+type RevocationStore interface {
+	Revoke(ctx context.Context, jti string, expiresAt time.Time) error
+	IsRevoked(ctx context.Context, jti string) (bool, error)
+}
+
+type MongoRefreshTokenStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoRefreshTokenStore(coll *mongo.Collection) *MongoRefreshTokenStore {
+	return &MongoRefreshTokenStore{coll: coll}
+}
+
+func (s *MongoRefreshTokenStore) Create(ctx context.Context, t *models.RefreshToken) error {
+	_, err := s.coll.InsertOne(ctx, t)
+	return err
+}
+
+func (s *MongoRefreshTokenStore) FindByID(ctx context.Context, id string) (*models.RefreshToken, error) {
+	var t models.RefreshToken
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&t)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+func (s *MongoRefreshTokenStore) MarkUsed(ctx context.Context, id string) (*models.RefreshToken, error) {
+	now := time.Now().UTC()
+	filter := bson.M{
+		"_id":        id,
+		"used_at":    bson.M{"$exists": false},
+		"expires_at": bson.M{"$gt": now},
+	}
+	update := bson.M{"$set": bson.M{"used_at": now}}
+	var t models.RefreshToken
+	err := s.coll.FindOneAndUpdate(ctx, filter, update, options.FindOneAndUpdate()).Decode(&t)
+	if err == nil {
+		return &t, nil
+	}
+	if !errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, err
+	}
+	// Distinguish a replayed token from an unknown or expired one.
+	existing, err := s.FindByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if existing.UsedAt != nil {
+		return existing, ErrTokenReused
+	}
+	return nil, ErrNotFound
+}
+
+func (s *MongoRefreshTokenStore) RevokeFamily(ctx context.Context, familyID string) error {
+	_, err := s.coll.DeleteMany(ctx, bson.M{"family_id": familyID})
+	return err
+}
+
+type MongoRevocationStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoRevocationStore(coll *mongo.Collection) *MongoRevocationStore {
+	return &MongoRevocationStore{coll: coll}
+}
+
+func (s *MongoRevocationStore) Revoke(ctx context.Context, jti string, expiresAt time.Time) error {
+	_, err := s.coll.UpdateOne(ctx,
+		bson.M{"_id": jti},
+		bson.M{"$set": bson.M{"expires_at": expiresAt}},
+		options.Update().SetUpsert(true),
+	)
+	return err
+}
+
+func (s *MongoRevocationStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	n, err := s.coll.CountDocuments(ctx, bson.M{"_id": jti}, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+type MemoryRefreshTokenStore struct {
+	mu     sync.Mutex
+	tokens map[string]models.RefreshToken
+}
+
+func NewMemoryRefreshTokenStore() *MemoryRefreshTokenStore {
+	return &MemoryRefreshTokenStore{tokens: map[string]models.RefreshToken{}}
+}
+
+func (s *MemoryRefreshTokenStore) Create(_ context.Context, t *models.RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.tokens[t.ID] = *t
+	return nil
+}
+
+func (s *MemoryRefreshTokenStore) FindByID(_ context.Context, id string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &t, nil
+}
+
+func (s *MemoryRefreshTokenStore) MarkUsed(_ context.Context, id string) (*models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tokens[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if t.UsedAt != nil {
+		return &t, ErrTokenReused
+	}
+	now := time.Now().UTC()
+	if !now.Before(t.ExpiresAt) {
+		return nil, ErrNotFound
+	}
+	t.UsedAt = &now
+	s.tokens[id] = t
+	return &t, nil
+}
+
+func (s *MemoryRefreshTokenStore) RevokeFamily(_ context.Context, familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id, t := range s.tokens {
+		if t.FamilyID == familyID {
+			delete(s.tokens, id)
+		}
+	}
+	return nil
+}
+
+type MemoryRevocationStore struct {
+	mu      sync.Mutex
+	revoked map[string]time.Time
+}
+
+func NewMemoryRevocationStore() *MemoryRevocationStore {
+	return &MemoryRevocationStore{revoked: map[string]time.Time{}}
+}
+
+func (s *MemoryRevocationStore) Revoke(_ context.Context, jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *MemoryRevocationStore) IsRevoked(_ context.Context, jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// UserStore provisions and reads users keyed by OIDC subject.
+type UserStore interface {
+	// UpsertLogin records a successful login, creating the user on first
+	// sight. Profile fields are refreshed when u carries them, so a login
+	// with fewer claims keeps the ones on record, and the verification level
+	// is raised to u.VerificationLevel if that is higher; it never goes down.
+	UpsertLogin(ctx context.Context, u *models.User) (*models.User, error)
+	FindByID(ctx context.Context, id string) (*models.User, error)
+	// RaiseLevel sets the user's level to at least level.
+	RaiseLevel(ctx context.Context, id string, level models.VerificationLevel) (*models.User, error)
+}
+
+type MongoUserStore struct {
+	coll *mongo.Collection
+}
+
+func NewMongoUserStore(coll *mongo.Collection) *MongoUserStore {
+	return &MongoUserStore{coll: coll}
+}
+
+func (s *MongoUserStore) UpsertLogin(ctx context.Context, u *models.User) (*models.User, error) {
+	now := time.Now().UTC()
+	set := bson.M{"last_login_at": now}
+	if u.Email != "" {
+		set["email"] = u.Email
+	}
+	if u.Name != "" {
+		set["name"] = u.Name
+	}
+	update := bson.M{
+		"$set": set,
+		"$setOnInsert": bson.M{
+			"created_at": now,
+		},
+		"$max": bson.M{
+			"verification_level": u.VerificationLevel,
+		},
+	}
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	var out models.User
+	if err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": u.ID}, update, opts).Decode(&out); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+func (s *MongoUserStore) FindByID(ctx context.Context, id string) (*models.User, error) {
+	var u models.User
+	err := s.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+func (s *MongoUserStore) RaiseLevel(ctx context.Context, id string, level models.VerificationLevel) (*models.User, error) {
+	update := bson.M{"$max": bson.M{"verification_level": level}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	var u models.User
+	err := s.coll.FindOneAndUpdate(ctx, bson.M{"_id": id}, update, opts).Decode(&u)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
+
+type MemoryUserStore struct {
+	mu    sync.Mutex
+	users map[string]models.User
+}
+
+func NewMemoryUserStore() *MemoryUserStore {
+	return &MemoryUserStore{users: map[string]models.User{}}
+}
+
+func (s *MemoryUserStore) UpsertLogin(_ context.Context, u *models.User) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now().UTC()
+	existing, ok := s.users[u.ID]
+	if !ok {
+		existing = models.User{ID: u.ID, CreatedAt: now}
+	}
+	if u.VerificationLevel > existing.VerificationLevel {
+		existing.VerificationLevel = u.VerificationLevel
+	}
+	if u.Email != "" {
+		existing.Email = u.Email
+	}
+	if u.Name != "" {
+		existing.Name = u.Name
+	}
+	existing.LastLoginAt = now
+	s.users[u.ID] = existing
+	return &existing, nil
+}
+
+func (s *MemoryUserStore) FindByID(_ context.Context, id string) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &u, nil
+}
+
+func (s *MemoryUserStore) RaiseLevel(_ context.Context, id string, level models.VerificationLevel) (*models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, ok := s.users[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	if level > u.VerificationLevel {
+		u.VerificationLevel = level
+		s.users[id] = u
+	}
+	return &u, nil
+}
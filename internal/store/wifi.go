@@ -0,0 +1,352 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"wifi-go-backend/internal/models"
+	"wifi-go-backend/internal/utils"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// $centerSphere radii are radians, which FindWithin derives with
+// earthRadiusKm. $nearSphere distances on GeoJSON points are meters on
+// MongoDB's own sphere of nearSphereRadiusKm.
+const (
+	earthRadiusKm      = 6371.0
+	nearSphereRadiusKm = 6378.1
+)
+
+// WiFiRepository is the persistence boundary for WiFi networks. Positions
+// are (longitude, latitude) like the stored GeoJSON; radii are in km.
+type WiFiRepository interface {
+	// Insert stores wifi as revision 1, assigning an ID if it has none. It
+	// returns ErrDuplicate if a network with the same SSID and address exists.
+	Insert(ctx context.Context, wifi *models.WiFi) error
+	FindByID(ctx context.Context, id primitive.ObjectID) (*models.WiFi, error)
+	// FindByIDs returns the networks that still exist, in no particular order.
+	FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.WiFi, error)
+	// FindNear returns up to limit networks within maxKm, nearest first.
+	FindNear(ctx context.Context, lng, lat, maxKm float64, limit int) ([]models.WiFi, error)
+	// FindWithin returns every network within radiusKm, unordered.
+	FindWithin(ctx context.Context, lng, lat, radiusKm float64) ([]models.WiFi, error)
+	// Update replaces the stored network with the same ID if it is still at
+	// wifi.Revision, and advances wifi.Revision. It returns ErrConflict if
+	// the network has changed since, and like Insert ErrDuplicate on an SSID
+	// and address clash.
+	Update(ctx context.Context, wifi *models.WiFi) error
+	// Delete removes the network if it is still at revision, returning
+	// ErrConflict otherwise.
+	Delete(ctx context.Context, id primitive.ObjectID, revision int64) error
+	ExistsBySSIDAndAddress(ctx context.Context, ssid, address string) (bool, error)
+}
+
+type MongoWiFiRepository struct {
+	coll *mongo.Collection
+}
+
+func NewMongoWiFiRepository(coll *mongo.Collection) *MongoWiFiRepository {
+	return &MongoWiFiRepository{coll: coll}
+}
+
+func (r *MongoWiFiRepository) Insert(ctx context.Context, wifi *models.WiFi) error {
+	if wifi.ID.IsZero() {
+		wifi.ID = primitive.NewObjectID()
+	}
+	wifi.Revision = 1
+	// The unique ssid+address index makes this the authoritative check
+	_, err := r.coll.InsertOne(ctx, wifi)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (r *MongoWiFiRepository) FindByID(ctx context.Context, id primitive.ObjectID) (*models.WiFi, error) {
+	var wifi models.WiFi
+	err := r.coll.FindOne(ctx, bson.M{"_id": id}).Decode(&wifi)
+	if errors.Is(err, mongo.ErrNoDocuments) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &wifi, nil
+}
+
+func (r *MongoWiFiRepository) FindByIDs(ctx context.Context, ids []primitive.ObjectID) ([]models.WiFi, error) {
+	return r.find(ctx, bson.M{"_id": bson.M{"$in": ids}})
+}
+
+func (r *MongoWiFiRepository) FindNear(ctx context.Context, lng, lat, maxKm float64, limit int) ([]models.WiFi, error) {
+	// $nearSphere on a GeoJSON point takes meters and sorts by distance
+	filter := bson.M{
+		"location": bson.M{
+			"$nearSphere": bson.M{
+				"$geometry":    bson.M{"type": "Point", "coordinates": bson.A{lng, lat}},
+				"$maxDistance": maxKm * 1000,
+			},
+		},
+	}
+	return r.find(ctx, filter, options.Find().SetLimit(int64(limit)))
+}
+
+func (r *MongoWiFiRepository) FindWithin(ctx context.Context, lng, lat, radiusKm float64) ([]models.WiFi, error) {
+	// MongoDB $geoWithin with $centerSphere expects [lng, lat] and radians
+	filter := bson.M{
+		"location": bson.M{
+			"$geoWithin": bson.M{
+				"$centerSphere": bson.A{bson.A{lng, lat}, radiusKm / earthRadiusKm},
+			},
+		},
+	}
+	return r.find(ctx, filter)
+}
+
+func (r *MongoWiFiRepository) Update(ctx context.Context, wifi *models.WiFi) error {
+	next := *wifi
+	next.Revision++
+	res, err := r.coll.ReplaceOne(ctx, revisionFilter(wifi.ID, wifi.Revision), &next)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	if err != nil {
+		return err
+	}
+	if res.MatchedCount == 0 {
+		return r.missOrConflict(ctx, wifi.ID)
+	}
+	wifi.Revision = next.Revision
+	return nil
+}
+
+func (r *MongoWiFiRepository) Delete(ctx context.Context, id primitive.ObjectID, revision int64) error {
+	res, err := r.coll.DeleteOne(ctx, revisionFilter(id, revision))
+	if err != nil {
+		return err
+	}
+	if res.DeletedCount == 0 {
+		return r.missOrConflict(ctx, id)
+	}
+	return nil
+}
+
+// revisionFilter matches the network at revision. Networks stored before
+// revisions were tracked have no field and count as revision 0.
+func revisionFilter(id primitive.ObjectID, revision int64) bson.M {
+	if revision == 0 {
+		return bson.M{"_id": id, "revision": bson.M{"$in": bson.A{0, nil}}}
+	}
+	return bson.M{"_id": id, "revision": revision}
+}
+
+// missOrConflict explains why a revision-checked write matched nothing.
+func (r *MongoWiFiRepository) missOrConflict(ctx context.Context, id primitive.ObjectID) error {
+	n, err := r.coll.CountDocuments(ctx, bson.M{"_id": id})
+	if err != nil {
+		return err
+	}
+	if n > 0 {
+		return ErrConflict
+	}
+	return ErrNotFound
+}
+
+func (r *MongoWiFiRepository) ExistsBySSIDAndAddress(ctx context.Context, ssid, address string) (bool, error) {
+	filter := bson.M{"ssid": ssid, "location.address": address}
+	n, err := r.coll.CountDocuments(ctx, filter, options.Count().SetLimit(1))
+	return n > 0, err
+}
+
+func (r *MongoWiFiRepository) find(ctx context.Context, filter bson.M, opts ...*options.FindOptions) ([]models.WiFi, error) {
+	cur, err := r.coll.Find(ctx, filter, opts...)
+	if err != nil {
+		return nil, err
+	}
+	defer cur.Close(ctx)
+	var out []models.WiFi
+	for cur.Next(ctx) {
+		var wifi models.WiFi
+		// Skip documents that no longer match the model rather than failing
+		// the whole query
+		if err := cur.Decode(&wifi); err != nil {
+			continue
+		}
+		out = append(out, wifi)
+	}
+	return out, cur.Err()
+}
+
+// MemoryWiFiRepository keeps networks in a map and answers geospatial
+// queries with the haversine distance, matching Mongo's spherical semantics.
+// Like the 2dsphere index, it refuses locations that are not a valid point.
+type MemoryWiFiRepository struct {
+	mu    sync.Mutex
+	wifis map[primitive.ObjectID]models.WiFi
+}
+
+func NewMemoryWiFiRepository() *MemoryWiFiRepository {
+	return &MemoryWiFiRepository{wifis: map[primitive.ObjectID]models.WiFi{}}
+}
+
+func (r *MemoryWiFiRepository) Insert(_ context.Context, wifi *models.WiFi) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if wifi.ID.IsZero() {
+		wifi.ID = primitive.NewObjectID()
+	}
+	if !validPoint(wifi.Location) {
+		return errInvalidPoint
+	}
+	if _, ok := r.wifis[wifi.ID]; ok || r.clashes(wifi) {
+		return ErrDuplicate
+	}
+	wifi.Revision = 1
+	r.wifis[wifi.ID] = *wifi
+	return nil
+}
+
+func (r *MemoryWiFiRepository) FindByID(_ context.Context, id primitive.ObjectID) (*models.WiFi, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	wifi, ok := r.wifis[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return &wifi, nil
+}
+
+func (r *MemoryWiFiRepository) FindByIDs(_ context.Context, ids []primitive.ObjectID) ([]models.WiFi, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []models.WiFi
+	for _, id := range ids {
+		if wifi, ok := r.wifis[id]; ok {
+			out = append(out, wifi)
+		}
+	}
+	return out, nil
+}
+
+func (r *MemoryWiFiRepository) FindNear(_ context.Context, lng, lat, maxKm float64, limit int) ([]models.WiFi, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	type hit struct {
+		wifi models.WiFi
+		dist float64
+	}
+	var hits []hit
+	for _, wifi := range r.wifis {
+		if d, ok := distanceKm(wifi, lng, lat); ok {
+			if d *= nearSphereRadiusKm / earthRadiusKm; d <= maxKm {
+				hits = append(hits, hit{wifi, d})
+			}
+		}
+	}
+	sort.Slice(hits, func(i, j int) bool { return hits[i].dist < hits[j].dist })
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	out := make([]models.WiFi, len(hits))
+	for i, h := range hits {
+		out[i] = h.wifi
+	}
+	return out, nil
+}
+
+func (r *MemoryWiFiRepository) FindWithin(_ context.Context, lng, lat, radiusKm float64) ([]models.WiFi, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var out []models.WiFi
+	for _, wifi := range r.wifis {
+		if d, ok := distanceKm(wifi, lng, lat); ok && d <= radiusKm {
+			out = append(out, wifi)
+		}
+	}
+	return out, nil
+}
+
+func (r *MemoryWiFiRepository) Update(_ context.Context, wifi *models.WiFi) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.wifis[wifi.ID]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Revision != wifi.Revision {
+		return ErrConflict
+	}
+	if !validPoint(wifi.Location) {
+		return errInvalidPoint
+	}
+	if r.clashes(wifi) {
+		return ErrDuplicate
+	}
+	wifi.Revision++
+	r.wifis[wifi.ID] = *wifi
+	return nil
+}
+
+func (r *MemoryWiFiRepository) Delete(_ context.Context, id primitive.ObjectID, revision int64) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	stored, ok := r.wifis[id]
+	if !ok {
+		return ErrNotFound
+	}
+	if stored.Revision != revision {
+		return ErrConflict
+	}
+	delete(r.wifis, id)
+	return nil
+}
+
+func (r *MemoryWiFiRepository) ExistsBySSIDAndAddress(_ context.Context, ssid, address string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, wifi := range r.wifis {
+		if wifi.SSID == ssid && wifi.Location.Address == address {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// clashes mirrors the unique ssid+address index: it reports whether another
+// network has the same SSID and address as wifi.
+func (r *MemoryWiFiRepository) clashes(wifi *models.WiFi) bool {
+	for id, other := range r.wifis {
+		if id != wifi.ID && other.SSID == wifi.SSID && other.Location.Address == wifi.Location.Address {
+			return true
+		}
+	}
+	return false
+}
+
+// distanceKm is the distance from (lng, lat) to wifi; networks without a
+// valid location never match a geospatial query.
+func distanceKm(wifi models.WiFi, lng, lat float64) (float64, bool) {
+	if len(wifi.Location.Coordinates) != 2 {
+		return 0, false
+	}
+	return utils.Haversine(lat, lng, wifi.Location.Coordinates[1], wifi.Location.Coordinates[0]), true
+}
+
+// errInvalidPoint stands in for the write error the 2dsphere index raises.
+var errInvalidPoint = errors.New("location is not a valid GeoJSON point")
+
+// validPoint reports whether loc is a GeoJSON point the 2dsphere index
+// accepts.
+func validPoint(loc models.Location) bool {
+	if loc.Type != "Point" || len(loc.Coordinates) != 2 {
+		return false
+	}
+	lng, lat := loc.Coordinates[0], loc.Coordinates[1]
+	return lng >= -180 && lng <= 180 && lat >= -90 && lat <= 90
+}
@@ -0,0 +1,390 @@
+package store
+
+import (
+	"context"
+	"errors"
+	"math"
+	"os"
+	"sort"
+	"testing"
+	"time"
+
+	"wifi-go-backend/config"
+	"wifi-go-backend/internal/db"
+	"wifi-go-backend/internal/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// offset returns the point km away from (lng, lat) on bearing (degrees from
+// north), on the sphere FindWithin measures with.
+func offset(lng, lat, km, bearing float64) (float64, float64) {
+	rad := math.Pi / 180
+	d := km / earthRadiusKm
+	φ1, λ1, θ := lat*rad, lng*rad, bearing*rad
+	φ2 := math.Asin(math.Sin(φ1)*math.Cos(d) + math.Cos(φ1)*math.Sin(d)*math.Cos(θ))
+	λ2 := λ1 + math.Atan2(math.Sin(θ)*math.Sin(d)*math.Cos(φ1), math.Cos(d)-math.Sin(φ1)*math.Sin(φ2))
+	lng2 := math.Mod(λ2/rad+540, 360) - 180
+	return lng2, φ2 / rad
+}
+
+func point(lng, lat float64) models.Location {
+	return models.Location{Type: "Point", Coordinates: []float64{lng, lat}}
+}
+
+// geoFixture is a named network placed relative to an origin.
+type geoFixture struct {
+	name     string
+	location models.Location
+}
+
+// geoFixtures surround a few origins with networks just inside and just
+// outside 1 km, including across the antimeridian and near a pole, where
+// planar approximations go wrong.
+func geoFixtures() []geoFixture {
+	var out []geoFixture
+	add := func(name string, lng, lat float64) {
+		out = append(out, geoFixture{name, point(lng, lat)})
+	}
+	for _, o := range []struct {
+		name     string
+		lng, lat float64
+	}{
+		{"berlin", 13.4, 52.5},
+		{"dateline", 179.9995, 0},
+		{"arctic", 0, 89.999},
+	} {
+		for _, b := range []float64{0, 90, 180, 270} {
+			for _, km := range []float64{0.2, 0.99, 1.01, 3} {
+				lng, lat := offset(o.lng, o.lat, km, b)
+				add(o.name, lng, lat)
+			}
+		}
+	}
+	add("across dateline", -179.9995, 0)
+	add("across pole", 180, 89.999)
+	return out
+}
+
+func queryOrigins() [][2]float64 {
+	return [][2]float64{{13.4, 52.5}, {179.9995, 0}, {-179.9995, 0}, {0, 89.999}, {-100, -45}}
+}
+
+func seed(t *testing.T, repo WiFiRepository, fixtures []geoFixture) map[primitive.ObjectID]string {
+	t.Helper()
+	names := map[primitive.ObjectID]string{}
+	for i, f := range fixtures {
+		wifi := &models.WiFi{SSID: f.name, Location: f.location}
+		wifi.Location.Address = string(rune('a'+i%26)) + string(rune('a'+i/26))
+		if err := repo.Insert(context.Background(), wifi); err != nil {
+			t.Fatalf("Insert(%s) error = %v", f.name, err)
+		}
+		names[wifi.ID] = f.name
+	}
+	return names
+}
+
+func ids(wifis []models.WiFi) []primitive.ObjectID {
+	out := make([]primitive.ObjectID, len(wifis))
+	for i, w := range wifis {
+		out[i] = w.ID
+	}
+	return out
+}
+
+func sortedIDs(wifis []models.WiFi) []string {
+	out := make([]string, len(wifis))
+	for i, w := range wifis {
+		out[i] = w.ID.Hex()
+	}
+	sort.Strings(out)
+	return out
+}
+
+func TestMemoryFindWithinRadius(t *testing.T) {
+	repo := NewMemoryWiFiRepository()
+	ctx := context.Background()
+	var inside, outside []primitive.ObjectID
+	for _, b := range []float64{0, 45, 90, 135, 180, 225, 270, 315} {
+		for _, km := range []float64{0.1, 0.999} {
+			lng, lat := offset(13.4, 52.5, km, b)
+			w := &models.WiFi{SSID: "in", Location: point(lng, lat)}
+			w.Location.Address = primitive.NewObjectID().Hex()
+			if err := repo.Insert(ctx, w); err != nil {
+				t.Fatal(err)
+			}
+			inside = append(inside, w.ID)
+		}
+		lng, lat := offset(13.4, 52.5, 1.001, b)
+		w := &models.WiFi{SSID: "out", Location: point(lng, lat)}
+		w.Location.Address = primitive.NewObjectID().Hex()
+		if err := repo.Insert(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+		outside = append(outside, w.ID)
+	}
+
+	found, err := repo.FindWithin(ctx, 13.4, 52.5, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := map[primitive.ObjectID]bool{}
+	for _, id := range ids(found) {
+		got[id] = true
+	}
+	for _, id := range inside {
+		if !got[id] {
+			t.Errorf("FindWithin missed a network inside 1 km")
+		}
+	}
+	for _, id := range outside {
+		if got[id] {
+			t.Errorf("FindWithin returned a network 1.001 km away")
+		}
+	}
+}
+
+func TestMemoryFindWithinWrapsTheSphere(t *testing.T) {
+	repo := NewMemoryWiFiRepository()
+	ctx := context.Background()
+	east := &models.WiFi{SSID: "east", Location: point(179.9995, 0)}
+	west := &models.WiFi{SSID: "west", Location: point(-179.9995, 0)}
+	polar := &models.WiFi{SSID: "polar", Location: point(180, 89.999)}
+	for _, w := range []*models.WiFi{east, west, polar} {
+		if err := repo.Insert(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// 0.001 degrees of longitude at the equator is about 111 m
+	found, _ := repo.FindWithin(ctx, 179.9995, 0, 0.2)
+	if len(found) != 2 {
+		t.Errorf("FindWithin across the antimeridian found %d networks, want 2", len(found))
+	}
+	// On the other side of the pole, but only ~220 m away
+	found, _ = repo.FindWithin(ctx, 0, 89.999, 0.3)
+	if len(found) != 1 || found[0].ID != polar.ID {
+		t.Errorf("FindWithin across the pole found %v, want the polar network", sortedIDs(found))
+	}
+}
+
+func TestMemoryFindNearUsesMongoMeters(t *testing.T) {
+	repo := NewMemoryWiFiRepository()
+	ctx := context.Background()
+	var want []primitive.ObjectID
+	for i, km := range []float64{0.8, 0.1, 0.5, 0.3} {
+		lng, lat := offset(13.4, 52.5, km, float64(i*90))
+		w := &models.WiFi{SSID: "near", Location: point(lng, lat)}
+		w.Location.Address = primitive.NewObjectID().Hex()
+		if err := repo.Insert(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+		want = append(want, w.ID)
+	}
+	// 0.9995 km on FindWithin's sphere is 1.0006 km on MongoDB's, so
+	// $nearSphere with a 1 km $maxDistance leaves it out
+	lng, lat := offset(13.4, 52.5, 0.9995, 45)
+	if err := repo.Insert(ctx, &models.WiFi{SSID: "edge", Location: point(lng, lat)}); err != nil {
+		t.Fatal(err)
+	}
+
+	found, err := repo.FindNear(ctx, 13.4, 52.5, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantOrder := []primitive.ObjectID{want[1], want[3], want[2], want[0]}
+	if got := ids(found); len(got) != len(wantOrder) {
+		t.Fatalf("FindNear found %d networks, want %d", len(got), len(wantOrder))
+	}
+	for i, id := range ids(found) {
+		if id != wantOrder[i] {
+			t.Errorf("FindNear[%d] is not the next nearest network", i)
+		}
+	}
+	if within, _ := repo.FindWithin(ctx, 13.4, 52.5, 1); len(within) != 5 {
+		t.Errorf("FindWithin found %d networks, want 5 including the edge one", len(within))
+	}
+
+	limited, _ := repo.FindNear(ctx, 13.4, 52.5, 1, 2)
+	if len(limited) != 2 || limited[0].ID != want[1] {
+		t.Errorf("FindNear with limit 2 = %v, want the two nearest", sortedIDs(limited))
+	}
+}
+
+func TestMemoryRejectsInvalidPoints(t *testing.T) {
+	repo := NewMemoryWiFiRepository()
+	ctx := context.Background()
+	for name, loc := range map[string]models.Location{
+		"longitude out of range": point(500, 2),
+		"latitude out of range":  point(2, 91),
+		"one coordinate":         {Type: "Point", Coordinates: []float64{1}},
+		"no type":                {Coordinates: []float64{1, 2}},
+	} {
+		if err := repo.Insert(ctx, &models.WiFi{SSID: name, Location: loc}); err == nil {
+			t.Errorf("Insert with %s succeeded", name)
+		}
+	}
+
+	w := &models.WiFi{SSID: "ok", Location: point(1, 2)}
+	if err := repo.Insert(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+	moved := *w
+	moved.Location = point(1, -95)
+	if err := repo.Update(ctx, &moved); err == nil {
+		t.Error("Update to an invalid point succeeded")
+	}
+}
+
+func TestMemoryUniqueSSIDAndAddress(t *testing.T) {
+	repo := NewMemoryWiFiRepository()
+	ctx := context.Background()
+	loc := point(1, 2)
+	loc.Address = "1 Main St"
+	if err := repo.Insert(ctx, &models.WiFi{SSID: "cafe", Location: loc}); err != nil {
+		t.Fatal(err)
+	}
+	if err := repo.Insert(ctx, &models.WiFi{SSID: "cafe", Location: loc}); !errors.Is(err, ErrDuplicate) {
+		t.Errorf("second Insert error = %v, want ErrDuplicate", err)
+	}
+	other := loc
+	other.Address = "2 Main St"
+	if err := repo.Insert(ctx, &models.WiFi{SSID: "cafe", Location: other}); err != nil {
+		t.Errorf("Insert at another address error = %v", err)
+	}
+	if ok, _ := repo.ExistsBySSIDAndAddress(ctx, "cafe", "1 Main St"); !ok {
+		t.Error("ExistsBySSIDAndAddress = false for a stored network")
+	}
+}
+
+func TestMemoryRevisions(t *testing.T) {
+	repo := NewMemoryWiFiRepository()
+	ctx := context.Background()
+	w := &models.WiFi{SSID: "cafe", Location: point(1, 2)}
+	if err := repo.Insert(ctx, w); err != nil {
+		t.Fatal(err)
+	}
+	if w.Revision != 1 {
+		t.Fatalf("Revision after Insert = %d, want 1", w.Revision)
+	}
+
+	stale := *w
+	w.Description = "updated"
+	if err := repo.Update(ctx, w); err != nil || w.Revision != 2 {
+		t.Fatalf("Update() = %v, revision %d; want nil, 2", err, w.Revision)
+	}
+	if err := repo.Update(ctx, &stale); !errors.Is(err, ErrConflict) {
+		t.Errorf("Update at a stale revision error = %v, want ErrConflict", err)
+	}
+	if err := repo.Delete(ctx, w.ID, 1); !errors.Is(err, ErrConflict) {
+		t.Errorf("Delete at a stale revision error = %v, want ErrConflict", err)
+	}
+	if err := repo.Delete(ctx, w.ID, 2); err != nil {
+		t.Errorf("Delete() error = %v", err)
+	}
+	if _, err := repo.FindByID(ctx, w.ID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("FindByID after Delete error = %v, want ErrNotFound", err)
+	}
+	if err := repo.Delete(ctx, w.ID, 2); !errors.Is(err, ErrNotFound) {
+		t.Errorf("second Delete error = %v, want ErrNotFound", err)
+	}
+}
+
+// connectTestDatabase connects db to a throwaway database on
+// MONGO_TEST_URI with the indexes in place, skipping the test when it is not
+// set. The database is dropped when the test ends.
+func connectTestDatabase(t *testing.T, ctx context.Context) {
+	t.Helper()
+	uri := os.Getenv("MONGO_TEST_URI")
+	if uri == "" {
+		t.Skip("MONGO_TEST_URI not set")
+	}
+	cfg := &config.Config{
+		MongoURI:            uri,
+		MongoDatabase:       "wifi_repo_test_" + primitive.NewObjectID().Hex(),
+		MongoConnectTimeout: 10 * time.Second,
+		MongoReadPreference: "primary",
+		MongoWriteConcern:   "majority",
+	}
+	if err := db.Connect(ctx, cfg); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Disconnect(context.Background()) })
+	database, err := db.GetDatabase()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.Drop(context.Background()) })
+	if err := db.EnsureIndexes(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// TestMemoryMatchesMongo runs the same geospatial queries against both
+// repositories. It needs a MongoDB server: set MONGO_TEST_URI to run it.
+func TestMemoryMatchesMongo(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	connectTestDatabase(t, ctx)
+	coll, err := db.GetWiFiCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	mongoRepo := NewMongoWiFiRepository(coll)
+	memRepo := NewMemoryWiFiRepository()
+	// Same IDs in both, so results can be compared directly
+	fixtures := geoFixtures()
+	names := seed(t, memRepo, fixtures)
+	for id := range names {
+		w, _ := memRepo.FindByID(ctx, id)
+		if err := mongoRepo.Insert(ctx, w); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, o := range queryOrigins() {
+		for _, km := range []float64{0.1, 0.5, 1, 2} {
+			want, err := mongoRepo.FindWithin(ctx, o[0], o[1], km)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got, _ := memRepo.FindWithin(ctx, o[0], o[1], km)
+			if a, b := sortedIDs(got), sortedIDs(want); !equalStrings(a, b) {
+				t.Errorf("FindWithin(%v, %v km): memory found %d, mongo %d", o, km, len(a), len(b))
+			}
+
+			wantNear, err := mongoRepo.FindNear(ctx, o[0], o[1], km, 5)
+			if err != nil {
+				t.Fatal(err)
+			}
+			gotNear, _ := memRepo.FindNear(ctx, o[0], o[1], km, 5)
+			if a, b := ids(gotNear), ids(wantNear); len(a) != len(b) {
+				t.Errorf("FindNear(%v, %v km): memory found %d, mongo %d", o, km, len(a), len(b))
+			} else {
+				for i := range a {
+					if a[i] != b[i] {
+						t.Errorf("FindNear(%v, %v km)[%d]: memory and mongo disagree on order", o, km, i)
+					}
+				}
+			}
+		}
+	}
+
+	bad := &models.WiFi{SSID: "bad", Location: point(500, 2)}
+	if err := mongoRepo.Insert(ctx, bad); err == nil {
+		t.Error("mongo accepted an invalid point; the memory repository should not refuse it")
+	}
+}
+
+func equalStrings(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
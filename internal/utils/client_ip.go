@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+type clientIPKey struct{}
+
+// TrustedProxies decides whose X-Forwarded-For and Forwarded headers are
+// believed. A hop is trusted when its address is in Nets or it is one of
+// the Hops nearest to the server, so behind a platform load balancer with
+// changing addresses Hops: 1 trusts exactly that balancer.
+type TrustedProxies struct {
+	Nets []*net.IPNet
+	Hops int
+}
+
+// ParseTrustedProxies reads CIDRs or bare IP addresses.
+func ParseTrustedProxies(cidrs []string, hops int) (*TrustedProxies, error) {
+	if hops < 0 {
+		return nil, fmt.Errorf("proxy hops must not be negative")
+	}
+	p := &TrustedProxies{Hops: hops}
+	for _, s := range cidrs {
+		s = strings.TrimSpace(s)
+		if ip := net.ParseIP(s); ip != nil {
+			bits := 8 * len(ip.To4())
+			if bits == 0 {
+				bits = 128
+			}
+			p.Nets = append(p.Nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not an IP address or CIDR", s)
+		}
+		p.Nets = append(p.Nets, n)
+	}
+	return p, nil
+}
+
+// Resolve returns the client's address: the peer, or when the peer is a
+// trusted proxy, the nearest untrusted address it forwarded. Addresses
+// left of that are supplied by the client and are ignored.
+func (p *TrustedProxies) Resolve(r *http.Request) string {
+	peer := remoteHost(r.RemoteAddr)
+	if p == nil || (p.Hops == 0 && len(p.Nets) == 0) {
+		return peer
+	}
+	chain := forwardedFor(r.Header)
+	client := peer
+	for hop := 0; p.trusted(client, hop); hop++ {
+		if len(chain) == 0 {
+			break
+		}
+		next := chain[len(chain)-1]
+		chain = chain[:len(chain)-1]
+		if net.ParseIP(next) == nil {
+			// "unknown" or an obfuscated identifier; the proxy that sent
+			// it is as close as we can get
+			break
+		}
+		client = next
+	}
+	return client
+}
+
+func (p *TrustedProxies) trusted(addr string, hop int) bool {
+	if hop < p.Hops {
+		return true
+	}
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+	for _, n := range p.Nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// ClientIP returns the caller's address without the port, as resolved by
+// TrustedProxies.Middleware; outside it, the peer's address.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey{}).(string); ok {
+		return ip
+	}
+	return remoteHost(r.RemoteAddr)
+}
+
+// Middleware resolves the client's address once per request for ClientIP.
+func (p *TrustedProxies) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), clientIPKey{}, p.Resolve(r))
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// forwardedFor lists the addresses in X-Forwarded-For, or failing that in
+// the for= parameters of Forwarded, client first.
+func forwardedFor(h http.Header) []string {
+	var out []string
+	if values := h.Values("X-Forwarded-For"); len(values) > 0 {
+		for _, v := range values {
+			for _, addr := range strings.Split(v, ",") {
+				out = append(out, strings.TrimSpace(addr))
+			}
+		}
+		return out
+	}
+	for _, v := range h.Values("Forwarded") {
+		for _, elem := range strings.Split(v, ",") {
+			for _, pair := range strings.Split(elem, ";") {
+				key, value, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if ok && strings.EqualFold(key, "for") {
+					out = append(out, forwardedNode(value))
+				}
+			}
+		}
+	}
+	return out
+}
+
+// forwardedNode strips the quotes, brackets and port from a Forwarded node
+// such as "[2001:db8::1]:4711".
+func forwardedNode(v string) string {
+	v = strings.Trim(v, `"`)
+	if strings.HasPrefix(v, "[") {
+		if end := strings.Index(v, "]"); end > 0 {
+			return v[1:end]
+		}
+	}
+	return remoteHost(v)
+}
+
+func remoteHost(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return addr
+	}
+	return host
+}
@@ -0,0 +1,79 @@
+package utils
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTrustedProxiesResolve(t *testing.T) {
+	cidrs, err := ParseTrustedProxies([]string{"10.0.0.0/8", "192.0.2.1"}, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	oneHop, _ := ParseTrustedProxies(nil, 1)
+
+	tests := []struct {
+		name    string
+		proxies *TrustedProxies
+		peer    string
+		headers map[string]string
+		want    string
+	}{
+		{"no proxies ignores headers", nil, "203.0.113.9:443", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.9"},
+		{"untrusted peer", cidrs, "203.0.113.9:443", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "203.0.113.9"},
+		{"trusted peer", cidrs, "10.1.2.3:443", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"spoofed entries left of the client", cidrs, "10.1.2.3:443", map[string]string{"X-Forwarded-For": "1.1.1.1, 198.51.100.1, 10.9.9.9"}, "198.51.100.1"},
+		{"single IP entry", cidrs, "192.0.2.1:443", map[string]string{"X-Forwarded-For": "198.51.100.1"}, "198.51.100.1"},
+		{"trusted peer without header", cidrs, "10.1.2.3:443", nil, "10.1.2.3"},
+		{"all hops trusted", cidrs, "10.1.2.3:443", map[string]string{"X-Forwarded-For": "10.4.4.4"}, "10.4.4.4"},
+		{"unknown node", cidrs, "10.1.2.3:443", map[string]string{"X-Forwarded-For": "198.51.100.1, unknown"}, "10.1.2.3"},
+		{"one hop", oneHop, "172.16.0.5:443", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.1"}, "198.51.100.1"},
+		{"one hop takes the nearest entry", oneHop, "172.16.0.5:443", map[string]string{"X-Forwarded-For": "6.6.6.6, 198.51.100.1, 172.16.0.4"}, "172.16.0.4"},
+		{"forwarded", cidrs, "10.1.2.3:443", map[string]string{"Forwarded": `for=198.51.100.1;proto=https, for="[2001:db8::1]:4711"`}, "2001:db8::1"},
+		{"forwarded with port", oneHop, "172.16.0.5:443", map[string]string{"Forwarded": `for="198.51.100.1:1234"`}, "198.51.100.1"},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.peer
+		for k, v := range tt.headers {
+			r.Header.Set(k, v)
+		}
+		if got := tt.proxies.Resolve(r); got != tt.want {
+			t.Errorf("%s: Resolve() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestClientIPUsesMiddleware(t *testing.T) {
+	proxies, _ := ParseTrustedProxies(nil, 1)
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = "172.16.0.5:443"
+	r.Header.Set("X-Forwarded-For", "198.51.100.1")
+	if got := ClientIP(r); got != "172.16.0.5" {
+		t.Errorf("ClientIP() outside the middleware = %q, want the peer", got)
+	}
+
+	var got string
+	proxies.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = ClientIP(r)
+	})).ServeHTTP(httptest.NewRecorder(), r)
+	if got != "198.51.100.1" {
+		t.Errorf("ClientIP() = %q, want the forwarded client", got)
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	for _, bad := range []string{"10.0.0.0/33", "proxy.internal", ""} {
+		if _, err := ParseTrustedProxies([]string{bad}, 0); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) succeeded", bad)
+		}
+	}
+	if _, err := ParseTrustedProxies(nil, -1); err == nil {
+		t.Error("ParseTrustedProxies accepted negative hops")
+	}
+	p, err := ParseTrustedProxies([]string{"2001:db8::1", "fd00::/8"}, 0)
+	if err != nil || len(p.Nets) != 2 {
+		t.Errorf("ParseTrustedProxies(IPv6) = %v, %v", p, err)
+	}
+}
@@ -1,3 +1,72 @@
 package utils
 
+import (
+	"math"
+	"net"
+	"strings"
+)
+
 // Utility functions
+
+// WiFiQRPayload builds the standard "WIFI:" provisioning string understood by
+// Android and iOS camera apps, e.g. WIFI:T:WPA;S:MyNet;P:secret;;
+// security is "WPA", "WEP" or "nopass"; an empty password implies nopass.
+// hidden marks a network that does not broadcast its SSID.
+func WiFiQRPayload(ssid, password, security string, hidden bool) string {
+	if password == "" {
+		security = "nopass"
+	}
+	if security == "" {
+		security = "WPA"
+	}
+	var b strings.Builder
+	b.WriteString("WIFI:T:" + security + ";S:" + escapeWiFiField(ssid) + ";")
+	if security != "nopass" {
+		b.WriteString("P:" + escapeWiFiField(password) + ";")
+	}
+	if hidden {
+		b.WriteString("H:true;")
+	}
+	b.WriteString(";")
+	return b.String()
+}
+
+// escapeWiFiField backslash-escapes the characters that are special in the
+// WIFI: format.
+func escapeWiFiField(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		`;`, `\;`,
+		`,`, `\,`,
+		`:`, `\:`,
+		`"`, `\"`,
+	).Replace(s)
+}
+
+// Haversine formula to calculate distance between two lat/lng points in km
+func Haversine(lat1, lng1, lat2, lng2 float64) float64 {
+	const R = 6371 // Earth radius in km
+	dLat := (lat2 - lat1) * math.Pi / 180.0
+	dLng := (lng2 - lng1) * math.Pi / 180.0
+	lat1R := lat1 * math.Pi / 180.0
+	lat2R := lat2 * math.Pi / 180.0
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Sin(dLng/2)*math.Sin(dLng/2)*math.Cos(lat1R)*math.Cos(lat2R)
+	c := 2 * math.Atan2(math.Sqrt(a), math.Sqrt(1-a))
+	return R * c
+}
+
+// NormalizeBSSID parses an access point's MAC address in any common
+// notation and returns it as lower-case aa:bb:cc:dd:ee:ff. The all-zero
+// address some platforms report when scanning is not permitted is rejected.
+func NormalizeBSSID(s string) (string, bool) {
+	mac, err := net.ParseMAC(strings.TrimSpace(s))
+	if err != nil || len(mac) != 6 {
+		return "", false
+	}
+	for _, b := range mac {
+		if b != 0 {
+			return mac.String(), true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,33 @@
+package utils
+
+import "testing"
+
+func TestWiFiQRPayload(t *testing.T) {
+	tests := []struct {
+		name     string
+		ssid     string
+		password string
+		security string
+		hidden   bool
+		want     string
+	}{
+		{"plain", "Cafe", "secret", "WPA", false, "WIFI:T:WPA;S:Cafe;P:secret;;"},
+		{"default security", "Cafe", "secret", "", false, "WIFI:T:WPA;S:Cafe;P:secret;;"},
+		{"wep", "Cafe", "secret", "WEP", false, "WIFI:T:WEP;S:Cafe;P:secret;;"},
+		{"open", "Cafe", "", "WPA", false, "WIFI:T:nopass;S:Cafe;;"},
+		{"hidden", "Cafe", "secret", "WPA", true, "WIFI:T:WPA;S:Cafe;P:secret;H:true;;"},
+		{"semicolon", "a;b", "c;d", "WPA", false, `WIFI:T:WPA;S:a\;b;P:c\;d;;`},
+		{"comma", "a,b", "c,d", "WPA", false, `WIFI:T:WPA;S:a\,b;P:c\,d;;`},
+		{"colon", "a:b", "c:d", "WPA", false, `WIFI:T:WPA;S:a\:b;P:c\:d;;`},
+		{"backslash", `a\b`, `c\d`, "WPA", false, `WIFI:T:WPA;S:a\\b;P:c\\d;;`},
+		{"quote", `"ab"`, `"cd"`, "WPA", false, `WIFI:T:WPA;S:\"ab\";P:\"cd\";;`},
+		// Each character is escaped once, so an escape in the input stays literal
+		{"escaped input", `a\;b`, `\:`, "WPA", false, `WIFI:T:WPA;S:a\\\;b;P:\\\:;;`},
+		{"unicode", "Café ☕", "pässwort", "WPA", false, "WIFI:T:WPA;S:Café ☕;P:pässwort;;"},
+	}
+	for _, tt := range tests {
+		if got := WiFiQRPayload(tt.ssid, tt.password, tt.security, tt.hidden); got != tt.want {
+			t.Errorf("%s: WiFiQRPayload = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}